@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/test-infra/prow/interrupts"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+const (
+	// adminResourceGroup and adminResourceName are the dedicated resource the
+	// admin endpoints authorize against, so on-call can be granted override
+	// powers through ordinary RBAC without cluster-admin.
+	adminResourceGroup = "ci.openshift.io"
+	adminResourceName  = "ci-scheduling-webhooks"
+)
+
+// adminServer exposes status and decision endpoints for operators, protected
+// by TokenReview authentication and SubjectAccessReview authorization.
+type adminServer struct {
+	logger         *logrus.Entry
+	client         kubernetes.Interface
+	prioritization *scheduling.Prioritization
+	avoidanceMode  string
+	savings        *savings
+	efficiency     *efficiencyAnalyzer
+	audit          *auditLog
+
+	// mutator powers the dry-run explain endpoint; nil when the admin server
+	// runs without the webhook in the same process.
+	mutator *podMutator
+}
+
+func (s *adminServer) serve(port int) {
+	mux := http.NewServeMux()
+	mux.Handle("/status", s.authenticated(s.handleStatus))
+	mux.Handle("/explain", s.authenticated(s.handleExplain))
+	server := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: mux}
+	s.logger.WithField("port", port).Info("Serving admin endpoints.")
+	interrupts.ListenAndServe(server, 0)
+}
+
+// authenticated wraps a handler with bearer token authentication via
+// TokenReview and authorization via SubjectAccessReview against the dedicated
+// admin resource.
+func (s *adminServer) authenticated(handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		token := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ")
+		if token == "" || token == request.Header.Get("Authorization") {
+			http.Error(writer, "a bearer token is required", http.StatusUnauthorized)
+			return
+		}
+		tokenReview, err := s.client.AuthenticationV1().TokenReviews().Create(request.Context(), &authenticationv1.TokenReview{
+			Spec: authenticationv1.TokenReviewSpec{Token: token},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to create TokenReview.")
+			http.Error(writer, "could not authenticate token", http.StatusInternalServerError)
+			return
+		}
+		if !tokenReview.Status.Authenticated {
+			http.Error(writer, "token did not authenticate", http.StatusUnauthorized)
+			return
+		}
+		var groups []string
+		groups = append(groups, tokenReview.Status.User.Groups...)
+		accessReview, err := s.client.AuthorizationV1().SubjectAccessReviews().Create(request.Context(), &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				User:   tokenReview.Status.User.Username,
+				UID:    tokenReview.Status.User.UID,
+				Groups: groups,
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Group:    adminResourceGroup,
+					Resource: adminResourceName,
+					Verb:     "get",
+				},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to create SubjectAccessReview.")
+			http.Error(writer, "could not authorize user", http.StatusInternalServerError)
+			return
+		}
+		if !accessReview.Status.Allowed {
+			http.Error(writer, fmt.Sprintf("%s may not get %s.%s", tokenReview.Status.User.Username, adminResourceName, adminResourceGroup), http.StatusForbidden)
+			return
+		}
+		handler(writer, request)
+	}
+}
+
+// classStatus describes the avoidance state and utilization of one pod class
+// for operators and for cross-farm aggregation.
+type classStatus struct {
+	PodClass            string   `json:"podClass"`
+	Nodes               int      `json:"nodes"`
+	Pods                int      `json:"pods"`
+	ScaleDownCandidates []string `json:"scaleDownCandidates,omitempty"`
+	PrecludedHostnames  []string `json:"precludedHostnames,omitempty"`
+}
+
+// webhookStatus is the document served on /status and consumed by the
+// aggregator.
+type webhookStatus struct {
+	AvoidanceMode      string              `json:"avoidanceMode"`
+	TotalCPUMilliSaved int64               `json:"totalCPUMilliSaved"`
+	Classes            []classStatus       `json:"classes"`
+	EfficiencyFindings []efficiencyFinding `json:"efficiencyFindings,omitempty"`
+}
+
+func (s *adminServer) handleStatus(writer http.ResponseWriter, request *http.Request) {
+	status := webhookStatus{
+		AvoidanceMode:      s.avoidanceMode,
+		TotalCPUMilliSaved: s.savings.totalCPUMilli(),
+		EfficiencyFindings: s.efficiency.currentFindings(),
+	}
+	for _, podClass := range scheduling.PodClasses {
+		entry, err := s.classStatus(request.Context(), podClass)
+		if err != nil {
+			s.logger.WithError(err).WithField("class", podClass).Error("Failed to determine class status.")
+			http.Error(writer, "could not determine class status", http.StatusInternalServerError)
+			return
+		}
+		status.Classes = append(status.Classes, entry)
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(writer).Encode(status); err != nil {
+		s.logger.WithError(err).Error("Failed to encode status response.")
+	}
+}
+
+// handleExplain returns the webhook's full reasoning for a recent pod:
+// matched rules, the class assigned, the shrink applied, and the preclusion
+// list used with its computation time.
+func (s *adminServer) handleExplain(writer http.ResponseWriter, request *http.Request) {
+	if request.Method == http.MethodPost {
+		s.handleDryRunExplain(writer, request)
+		return
+	}
+	namespace, name := request.URL.Query().Get("namespace"), request.URL.Query().Get("pod")
+	if namespace == "" || name == "" {
+		http.Error(writer, "namespace and pod query parameters are required", http.StatusBadRequest)
+		return
+	}
+	record, found := s.audit.lookup(namespace, name)
+	if !found {
+		http.Error(writer, "no recent mutation record for this pod; the audit window may have passed", http.StatusNotFound)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(writer).Encode(record); err != nil {
+		s.logger.WithError(err).Error("Failed to encode explain response.")
+	}
+}
+
+// dryRunExplanation is the response of the POST /explain dry run.
+type dryRunExplanation struct {
+	Class   string          `json:"class"`
+	Reasons []string        `json:"reasons"`
+	Patch   json.RawMessage `json:"patch,omitempty"`
+	Allowed bool            `json:"allowed"`
+	Message string          `json:"message,omitempty"`
+}
+
+// handleDryRunExplain accepts a pod manifest and returns the class, matched
+// rules, and the JSON patch that would be generated - without altering any
+// state - so job owners can debug why their pod got a particular node pool.
+func (s *adminServer) handleDryRunExplain(writer http.ResponseWriter, request *http.Request) {
+	if s.mutator == nil {
+		http.Error(writer, "dry-run explain requires the webhook to run in this process", http.StatusNotImplemented)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(request.Body, 4<<20))
+	if err != nil {
+		http.Error(writer, "could not read pod manifest", http.StatusBadRequest)
+		return
+	}
+	explanation, err := s.mutator.dryRun(request.Context(), body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("could not evaluate pod manifest: %v", err), http.StatusBadRequest)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(writer).Encode(explanation); err != nil {
+		s.logger.WithError(err).Error("Failed to encode dry-run explain response.")
+	}
+}
+
+func (s *adminServer) classStatus(ctx context.Context, podClass string) (classStatus, error) {
+	status := classStatus{PodClass: podClass}
+	utilization, err := s.prioritization.ClassNodeUtilization(ctx, podClass)
+	if err != nil {
+		return status, err
+	}
+	status.Nodes = len(utilization)
+	for _, entry := range utilization {
+		status.Pods += entry.PodCount
+	}
+	for _, candidate := range s.prioritization.ScaleDownCandidatesOf(podClass, utilization) {
+		status.ScaleDownCandidates = append(status.ScaleDownCandidates, candidate.Node.Name)
+	}
+	hostnames, err := s.prioritization.PrecludedHostnames(ctx, podClass)
+	if err != nil {
+		return status, err
+	}
+	status.PrecludedHostnames = hostnames
+	return status, nil
+}
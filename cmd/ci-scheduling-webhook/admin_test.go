@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestAdminAuthentication(t *testing.T) {
+	var testCases = []struct {
+		name          string
+		authorization string
+		authenticated bool
+		allowed       bool
+		expectedCode  int
+	}{
+		{
+			name:          "missing bearer token",
+			authorization: "",
+			expectedCode:  http.StatusUnauthorized,
+		},
+		{
+			name:          "token fails authentication",
+			authorization: "Bearer bad",
+			authenticated: false,
+			expectedCode:  http.StatusUnauthorized,
+		},
+		{
+			name:          "authenticated but not authorized",
+			authorization: "Bearer good",
+			authenticated: true,
+			allowed:       false,
+			expectedCode:  http.StatusForbidden,
+		},
+		{
+			name:          "authenticated and authorized",
+			authorization: "Bearer good",
+			authenticated: true,
+			allowed:       true,
+			expectedCode:  http.StatusOK,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := fakekube.NewSimpleClientset()
+			client.PrependReactor("create", "tokenreviews", func(clienttesting.Action) (bool, runtime.Object, error) {
+				return true, &authenticationv1.TokenReview{Status: authenticationv1.TokenReviewStatus{
+					Authenticated: testCase.authenticated,
+					User:          authenticationv1.UserInfo{Username: "oncall"},
+				}}, nil
+			})
+			var reviewedAttributes *authorizationv1.ResourceAttributes
+			client.PrependReactor("create", "subjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				review := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+				reviewedAttributes = review.Spec.ResourceAttributes
+				return true, &authorizationv1.SubjectAccessReview{Status: authorizationv1.SubjectAccessReviewStatus{
+					Allowed: testCase.allowed,
+				}}, nil
+			})
+			server := &adminServer{logger: logrus.WithField("test", t.Name()), client: client}
+			handler := server.authenticated(func(writer http.ResponseWriter, _ *http.Request) {
+				writer.WriteHeader(http.StatusOK)
+			})
+			request := httptest.NewRequest(http.MethodGet, "/status", nil)
+			if testCase.authorization != "" {
+				request.Header.Set("Authorization", testCase.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			handler(recorder, request)
+			if recorder.Code != testCase.expectedCode {
+				t.Errorf("%s: expected status %d, got %d: %s", testCase.name, testCase.expectedCode, recorder.Code, recorder.Body.String())
+			}
+			if testCase.expectedCode == http.StatusOK || testCase.expectedCode == http.StatusForbidden {
+				if reviewedAttributes == nil || reviewedAttributes.Resource != adminResourceName || reviewedAttributes.Group != adminResourceGroup {
+					t.Errorf("%s: access was not reviewed against the dedicated admin resource: %+v", testCase.name, reviewedAttributes)
+				}
+			}
+		})
+	}
+}
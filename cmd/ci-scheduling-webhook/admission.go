@@ -0,0 +1,1630 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	pjapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/version"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+	schedulingv1 "github.com/openshift/ci-tools/pkg/scheduling/config/v1"
+)
+
+// metadataOrgLabel and metadataRepoLabel are the ci-operator metadata labels
+// tenant overrides key on.
+const (
+	metadataOrgLabel  = "ci.openshift.io/metadata.org"
+	metadataRepoLabel = "ci.openshift.io/metadata.repo"
+)
+
+type podMutator struct {
+	logger  *logrus.Entry
+	decoder *admission.Decoder
+
+	prioritization *scheduling.Prioritization
+
+	// classifier decides which dedicated pool a pod belongs on.
+	classifier scheduling.Classifier
+
+	// avoidanceMode selects how scale-down candidates are kept free of new
+	// pods; in taint-only mode no hostname affinity is injected at admission.
+	avoidanceMode string
+
+	// tolerationMode selects how pods get onto tainted pools: the class
+	// toleration directly in pod.spec, or a per-class RuntimeClass reference.
+	tolerationMode string
+
+	// shrinkCPURequests maps a pod class to the factor by which CPU requests
+	// of its containers are multiplied at admission.
+	shrinkCPURequests map[string]float64
+
+	// patchGuards are the path patterns whose add/replace operations are
+	// preceded by JSON-Patch test guards.
+	patchGuards []*regexp.Regexp
+
+	// savings accumulates the resources this webhook has removed from
+	// admitted pods, for the status endpoint and cross-farm aggregation.
+	savings *savings
+
+	// classBandwidth maps a pod class to the bandwidth annotations injected
+	// into its pods.
+	classBandwidth map[string]bandwidthLimits
+
+	// classEmptyDirSizeLimit maps a pod class to the sizeLimit defaulted onto
+	// emptyDir volumes that declare none.
+	classEmptyDirSizeLimit map[string]resource.Quantity
+
+	// memoryScratchClasses are the pod classes allowed to opt into
+	// memory-backed scratch volumes.
+	memoryScratchClasses map[string]bool
+
+	// client resolves owner chains for ownership-based policies.
+	client ctrlruntimeclient.Client
+
+	// statefulSetPodPolicy controls how StatefulSet-owned pods in matched
+	// namespaces are handled; relocating them breaks their PV node affinity.
+	statefulSetPodPolicy string
+
+	// shrinkWindows override the base shrink factors during time windows,
+	// aggressive during peak contention hours and relaxed overnight.
+	shrinkWindows []shrinkWindow
+
+	// classTolerations are additional tolerations injected per pod class,
+	// e.g. a dedicated=ci taint used by the infra team, so dedicated capacity
+	// can be carved out without every job config needing toleration stanzas.
+	classTolerations map[string][]corev1.Toleration
+
+	// schedulingGateClasses are the pod classes whose pods are admitted with
+	// a scheduling gate, released by the gate controller once the class has
+	// headroom.
+	schedulingGateClasses map[string]bool
+
+	// imageUsage records admitted images per class for the pre-pull
+	// orchestrator; nil when pre-pulling is disabled.
+	imageUsage *imageUsage
+
+	// tenantPolicies resolves per-tenant scheduling overrides; nil when
+	// TenantSchedulingPolicy consumption is disabled.
+	tenantPolicies *tenantPolicyResolver
+
+	// classPolicies resolves PodClassificationPolicy matches; nil when
+	// policy consumption is disabled.
+	classPolicies *classificationPolicyResolver
+
+	// overflow routes test pods to the overflow pool while the primary test
+	// pool's backlog is past the threshold; nil when disabled.
+	overflow *overflowTracker
+
+	// prowJobNamespace is where owning ProwJob resources live when ProwJob
+	// lookup is enabled; empty disables the lookup.
+	prowJobNamespace string
+	// prowJobTypeClasses maps ProwJob types (presubmit, postsubmit,
+	// periodic, batch) to pod classes.
+	prowJobTypeClasses map[string]string
+
+	// audit retains recent mutation records for the explain endpoint; nil
+	// when auditing is disabled.
+	audit *auditLog
+
+	// hot supplies the reloadable configuration snapshot; when nil the
+	// statically configured fields apply.
+	hot *configWatcher
+
+	// stageBudget bounds every mutation stage that is not strictly required
+	// synchronously (preclusion lookup, capacity lookup); on expiry the stage
+	// falls back and the handler still responds promptly.
+	stageBudget time.Duration
+
+	// containerShrinkRules override the shrink factor for containers whose
+	// name matches, so well-known sidecars (sidecar, entrypoint) can be
+	// shrunk aggressively while the test container keeps more CPU.
+	containerShrinkRules []containerShrinkRule
+
+	// classDefaultRequests maps a pod class to the CPU and memory requests
+	// injected into containers that declare no resources at all, so every
+	// container on CI nodes is accounted for by the scheduler.
+	classDefaultRequests map[string]defaultRequests
+
+	// classEphemeralStorageRequest maps a pod class to the ephemeral-storage
+	// request defaulted onto containers that declare none, so image
+	// extraction and scratch writes of build pods are accounted to the
+	// scheduler instead of surprising the node.
+	classEphemeralStorageRequest map[string]resource.Quantity
+
+	// shrinkExemptNamespaces and shrinkExemptLabels exempt pods from request
+	// reduction (e.g. payload-testing namespaces that are already tuned)
+	// while routing mutations still apply, so routing and resource mutation
+	// are controlled independently. Namespace entries ending in * match as
+	// prefixes.
+	shrinkExemptNamespaces []string
+	shrinkExemptLabels     map[string]string
+
+	// buildSpreadClasses and maxBuildsPerNodePerRepo keep heavy builds of the
+	// same repo off one node, preventing the disk/IO contention behind random
+	// build timeouts.
+	buildSpreadClasses      map[string]bool
+	maxBuildsPerNodePerRepo int
+
+	// excludeControlPlane hard-excludes control-plane and infra nodes from
+	// every managed class, so a mislabeled node or missing taint can never
+	// result in CI builds landing on control-plane nodes.
+	excludeControlPlane bool
+
+	// spotNodeLabel and spotForbiddenClasses forbid interruption-sensitive
+	// classes from spot nodes: a preempted hour-long e2e run or release
+	// assembly costs far more than the spot discount saves.
+	spotNodeLabel        string
+	spotForbiddenClasses map[string]bool
+
+	// pinNamespaceZones pins every pod of a ci-op namespace to the zone
+	// recorded by the zone pinner.
+	pinNamespaceZones bool
+
+	// colocateNamespaces makes all pods of a ci-op namespace prefer the same
+	// node and zone, reducing cross-node image pulls and speeding multi-pod
+	// test workflows.
+	colocateNamespaces bool
+
+	// packingPreferenceNodes, when positive, biases pods toward that many of
+	// the most-packed eligible nodes through preferred affinity.
+	packingPreferenceNodes int
+
+	// classZoneSpread injects a zone topologySpreadConstraint with the given
+	// maxSkew per class, so the autoscaler expands evenly across zones and a
+	// single-AZ capacity shortage does not stall the whole class.
+	classZoneSpread map[string]int32
+
+	// classActiveDeadlineSeconds defaults a deadline per class onto pods
+	// that declare none, keeping leaked test pods from squatting on capacity
+	// forever. classTerminationGraceSeconds caps the grace period per class:
+	// the apiserver defaults the field to 30 before admission runs, so a
+	// nil check could never fire, and the point is to bound runaway teardown
+	// while leaving shorter declared values alone.
+	classActiveDeadlineSeconds   map[string]int64
+	classTerminationGraceSeconds map[string]int64
+
+	// classLimitPolicy controls what happens to CPU limits of shrunk
+	// containers per class: "keep" (default) leaves them, "proportional"
+	// scales them with the request, "strip" removes them.
+	classLimitPolicy map[string]string
+
+	// initShrinkFactor, when non-zero, replaces the class factor for init
+	// containers, which run sequentially and briefly; initCPUCapMilli
+	// additionally caps their CPU requests at a tiny fixed value.
+	initShrinkFactor float64
+	initCPUCapMilli  int64
+
+	// cpuRequestIncrementMilli rounds adjusted CPU requests up to multiples
+	// of this many millicores, so per-node packing is deterministic and
+	// stranded-capacity fragments are minimized; 0 disables rounding.
+	cpuRequestIncrementMilli int64
+
+	// requestBounds clamp container requests after shrinking: minimums keep
+	// starved containers runnable, maximums keep any one container from
+	// claiming a whole node.
+	requestBounds map[corev1.ResourceName]requestBound
+
+	// usageProfile supplies historical P95 usage for right-sizing; nil
+	// disables usage-based right-sizing.
+	usageProfile *usageProfiler
+
+	// oom feeds memory bumps for workloads with OOM history; nil disables
+	// the feedback loop.
+	oom *oomTracker
+
+	// retries feeds escalating resource boosts for recently failed jobs; nil
+	// disables the boosts.
+	retries *retryTracker
+
+	// dynamicShrink overlays throttling-feedback-adjusted shrink factors;
+	// nil disables the overlay.
+	dynamicShrink *dynamicShrink
+
+	// guaranteedQoSClasses are the pod classes whose containers get limits
+	// equal to requests, so the pods land in the Guaranteed QoS class and
+	// are last in line for eviction.
+	guaranteedQoSClasses map[string]bool
+
+	// stripCPULimitClasses are the pod classes whose containers have CPU
+	// limits removed: CFS throttling hurts test latency far more than the
+	// occasional noisy neighbor, and requests still drive packing.
+	stripCPULimitClasses map[string]bool
+
+	// classPriorityClasses assigns a PriorityClass per pod class to pods
+	// that declare none, e.g. mapping the presubmit tiers to different
+	// priorities.
+	classPriorityClasses map[string]string
+
+	// namespaceAllowlist, when non-empty, restricts mutation to the listed
+	// namespaces; namespaceDenylist always wins. Entries ending in * match
+	// as prefixes.
+	namespaceAllowlist []string
+	namespaceDenylist  []string
+
+	// now stands in for time.Now so window selection is testable.
+	now func() time.Time
+}
+
+// enforceGuaranteedQoS aligns limits with requests (and vice versa) for CPU
+// and memory on every container, so payload-critical pods land in the
+// Guaranteed QoS class and are last in line for eviction.
+func enforceGuaranteedQoS(pod *corev1.Pod) {
+	align := func(containers []corev1.Container) {
+		for i := range containers {
+			resources := &containers[i].Resources
+			for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+				request, hasRequest := resources.Requests[name]
+				limit, hasLimit := resources.Limits[name]
+				switch {
+				case hasRequest:
+					if resources.Limits == nil {
+						resources.Limits = corev1.ResourceList{}
+					}
+					resources.Limits[name] = request
+				case hasLimit:
+					if resources.Requests == nil {
+						resources.Requests = corev1.ResourceList{}
+					}
+					resources.Requests[name] = limit
+				}
+			}
+		}
+	}
+	align(pod.Spec.InitContainers)
+	align(pod.Spec.Containers)
+}
+
+// stripCPULimits removes CPU limits from every container, leaving other
+// limits untouched.
+func stripCPULimits(pod *corev1.Pod) {
+	strip := func(containers []corev1.Container) {
+		for i := range containers {
+			delete(containers[i].Resources.Limits, corev1.ResourceCPU)
+		}
+	}
+	strip(pod.Spec.InitContainers)
+	strip(pod.Spec.Containers)
+}
+
+// capInitContainerCPU caps init container CPU requests at a tiny fixed
+// value: init containers run sequentially and briefly, so even large ones
+// rarely need sustained CPU reservations.
+func capInitContainerCPU(pod *corev1.Pod, capMilli int64) {
+	for i := range pod.Spec.InitContainers {
+		requests := pod.Spec.InitContainers[i].Resources.Requests
+		current, has := requests[corev1.ResourceCPU]
+		if !has || current.MilliValue() <= capMilli {
+			continue
+		}
+		requests[corev1.ResourceCPU] = *resource.NewMilliQuantity(capMilli, resource.DecimalSI)
+	}
+}
+
+// roundCPURequests rounds every CPU request up to the next multiple of the
+// increment.
+func roundCPURequests(pod *corev1.Pod, incrementMilli int64) {
+	round := func(containers []corev1.Container) {
+		for i := range containers {
+			requests := containers[i].Resources.Requests
+			current, has := requests[corev1.ResourceCPU]
+			if !has {
+				continue
+			}
+			milli := current.MilliValue()
+			if remainder := milli % incrementMilli; remainder != 0 {
+				requests[corev1.ResourceCPU] = *resource.NewMilliQuantity(milli+incrementMilli-remainder, resource.DecimalSI)
+			}
+		}
+	}
+	round(pod.Spec.InitContainers)
+	round(pod.Spec.Containers)
+}
+
+// defaultRequests are the CPU and memory requests for containers that
+// declare nothing.
+type defaultRequests struct {
+	cpu    resource.Quantity
+	memory resource.Quantity
+}
+
+// addDefaultRequests injects requests into containers with entirely empty
+// requests; containers that declare anything are left alone.
+func addDefaultRequests(pod *corev1.Pod, defaults defaultRequests) {
+	inject := func(containers []corev1.Container) {
+		for i := range containers {
+			if len(containers[i].Resources.Requests) > 0 {
+				continue
+			}
+			containers[i].Resources.Requests = corev1.ResourceList{
+				corev1.ResourceCPU:    defaults.cpu,
+				corev1.ResourceMemory: defaults.memory,
+			}
+		}
+	}
+	inject(pod.Spec.InitContainers)
+	inject(pod.Spec.Containers)
+}
+
+// addEphemeralStorageRequests defaults an ephemeral-storage request onto
+// containers that declare none.
+func addEphemeralStorageRequests(pod *corev1.Pod, request resource.Quantity) {
+	inject := func(containers []corev1.Container) {
+		for i := range containers {
+			requests := containers[i].Resources.Requests
+			if requests == nil {
+				requests = corev1.ResourceList{}
+				containers[i].Resources.Requests = requests
+			}
+			if _, declared := requests[corev1.ResourceEphemeralStorage]; !declared {
+				requests[corev1.ResourceEphemeralStorage] = request
+			}
+		}
+	}
+	inject(pod.Spec.InitContainers)
+	inject(pod.Spec.Containers)
+}
+
+// requestBound is an optional minimum and maximum for one resource.
+type requestBound struct {
+	min *resource.Quantity
+	max *resource.Quantity
+}
+
+// clampRequests bounds the requests of every container that declares the
+// resource; containers without a request are left alone.
+func clampRequests(pod *corev1.Pod, bounds map[corev1.ResourceName]requestBound) {
+	clamp := func(containers []corev1.Container) {
+		for i := range containers {
+			requests := containers[i].Resources.Requests
+			for name, bound := range bounds {
+				current, has := requests[name]
+				if !has {
+					continue
+				}
+				if bound.min != nil && current.Cmp(*bound.min) < 0 {
+					requests[name] = *bound.min
+				}
+				if bound.max != nil && current.Cmp(*bound.max) > 0 {
+					requests[name] = *bound.max
+				}
+			}
+		}
+	}
+	clamp(pod.Spec.InitContainers)
+	clamp(pod.Spec.Containers)
+}
+
+func namespaceMatches(namespace string, entries []string) bool {
+	for _, entry := range entries {
+		if prefix, wildcard := strings.CutSuffix(entry, "*"); wildcard {
+			if strings.HasPrefix(namespace, prefix) {
+				return true
+			}
+		} else if namespace == entry {
+			return true
+		}
+	}
+	return false
+}
+
+// namespaceInScope reports whether pods of the namespace are mutated at all.
+func (m *podMutator) namespaceInScope(namespace string) bool {
+	if namespaceMatches(namespace, m.namespaceDenylist) {
+		return false
+	}
+	if len(m.namespaceAllowlist) > 0 {
+		return namespaceMatches(namespace, m.namespaceAllowlist)
+	}
+	return true
+}
+
+var mutationStageLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "ci_scheduling_webhook_mutation_stage_seconds",
+	Help:    "Latency of pod mutation stages, proving the per-stage budget holds.",
+	Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.2, 0.5, 1},
+}, []string{"stage", "outcome"})
+
+var unsupportedOSPods = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "ci_scheduling_webhook_unsupported_os_pods_total",
+	Help: "Pods excluded from CI-class mutation because they target a non-Linux operating system.",
+}, []string{"os"})
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(mutationStageLatency, unsupportedOSPods)
+}
+
+// runStage runs a non-essential mutation stage under the stage budget and
+// records its latency and outcome. A zero budget leaves the stage unbounded.
+func (m *podMutator) runStage(ctx context.Context, stage string, fn func(ctx context.Context) error) error {
+	if m.stageBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.stageBudget)
+		defer cancel()
+	}
+	started := time.Now()
+	err := fn(ctx)
+	outcome := "ok"
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		outcome = "timeout"
+	case err != nil:
+		outcome = "error"
+	}
+	mutationStageLatency.WithLabelValues(stage, outcome).Observe(time.Since(started).Seconds())
+	return err
+}
+
+// shrinkFactorAnnotation records the CPU shrink factor applied at admission
+// for later analysis.
+const shrinkFactorAnnotation = "ci-workload.openshift.io/applied-shrink-factor"
+
+const (
+	// classAnnotation records the computed pod class on the mutated pod.
+	classAnnotation = "ci-workload.openshift.io/class"
+	// matchedRuleAnnotation records the classification rule and override
+	// chain that produced the class.
+	matchedRuleAnnotation = "ci-workload.openshift.io/matched-rule"
+	// webhookVersionAnnotation records which webhook build made the decision.
+	webhookVersionAnnotation = "ci-workload.openshift.io/webhook-version"
+)
+
+// stampDecisionAnnotations records the scheduling decision on the pod object
+// itself, so SREs can explain from the pod alone why it was scheduled where
+// it was, even after the audit window has passed.
+func stampDecisionAnnotations(pod *corev1.Pod, record *mutationRecord) {
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[classAnnotation] = record.Class
+	pod.Annotations[matchedRuleAnnotation] = strings.Join(record.Reasons, "; ")
+	pod.Annotations[webhookVersionAnnotation] = version.Version
+}
+
+// shrinkWindow is a time-of-day override of a class's shrink factor. Times
+// are minutes from UTC midnight; windows may wrap around midnight.
+type shrinkWindow struct {
+	class      string
+	factor     float64
+	start, end int
+}
+
+func (w *shrinkWindow) contains(now time.Time) bool {
+	minute := now.UTC().Hour()*60 + now.UTC().Minute()
+	if w.start <= w.end {
+		return minute >= w.start && minute < w.end
+	}
+	return minute >= w.start || minute < w.end
+}
+
+func parseShrinkWindows(specs []string) ([]shrinkWindow, error) {
+	classes := scheduling.SetOf(scheduling.PodClasses)
+	var windows []shrinkWindow
+	for _, spec := range specs {
+		malformed := fmt.Errorf("--shrink-window must be in class=factor@HH:MM-HH:MM form, not %q", spec)
+		assignment, timespan, valid := strings.Cut(spec, "@")
+		if !valid {
+			return nil, malformed
+		}
+		class, rawFactor, valid := strings.Cut(assignment, "=")
+		if !valid || !classes[class] {
+			return nil, malformed
+		}
+		factor, err := strconv.ParseFloat(rawFactor, 64)
+		if err != nil || factor <= 0 || factor > 1.0 {
+			return nil, fmt.Errorf("--shrink-window factor must be in (0.0,1.0], not %q", rawFactor)
+		}
+		rawStart, rawEnd, valid := strings.Cut(timespan, "-")
+		if !valid {
+			return nil, malformed
+		}
+		window := shrinkWindow{class: class, factor: factor}
+		for _, bound := range []struct {
+			raw    string
+			minute *int
+		}{{rawStart, &window.start}, {rawEnd, &window.end}} {
+			parsed, err := time.Parse("15:04", bound.raw)
+			if err != nil {
+				return nil, malformed
+			}
+			*bound.minute = parsed.Hour()*60 + parsed.Minute()
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+// currentClassifier returns the classifier, overlaying the hot-reloadable
+// rules onto the statically configured one. Only the fields the
+// configuration document actually carries are replaced; every flag-configured
+// input (accelerators, thresholds, step/target rules, ...) stays intact.
+func (m *podMutator) currentClassifier() *scheduling.Classifier {
+	hot := m.hot.load()
+	if hot == nil {
+		return &m.classifier
+	}
+	merged := m.classifier
+	merged.ImageClassRules = hot.classifier.ImageClassRules
+	merged.IOIntensiveJobs = hot.classifier.IOIntensiveJobs
+	return &merged
+}
+
+// shrinkFactorFor returns the effective CPU shrink factor for a class, taking
+// any active time window override ahead of the base factor.
+func (m *podMutator) shrinkFactorFor(podClass string) (float64, bool) {
+	if factor, adjusted := m.dynamicShrink.factorFor(podClass); adjusted {
+		return factor, true
+	}
+	factors, windows := m.shrinkCPURequests, m.shrinkWindows
+	if hot := m.hot.load(); hot != nil {
+		factors, windows = hot.shrinkFactors, hot.shrinkWindows
+	}
+	now := time.Now()
+	if m.now != nil {
+		now = m.now()
+	}
+	for _, window := range windows {
+		if window.class == podClass && window.contains(now) {
+			return window.factor, true
+		}
+	}
+	factor, configured := factors[podClass]
+	return factor, configured
+}
+
+const (
+	// statefulSetPolicyLabelOnly labels StatefulSet-owned pods with their
+	// class but leaves scheduling untouched.
+	statefulSetPolicyLabelOnly = "label-only"
+	// statefulSetPolicyIgnore leaves StatefulSet-owned pods entirely alone.
+	statefulSetPolicyIgnore = "ignore"
+	// statefulSetPolicyFull treats StatefulSet-owned pods like any other.
+	statefulSetPolicyFull = "full"
+)
+
+// hasStatefulSetOwner resolves the pod's controller owner chain and reports
+// whether a StatefulSet owns it. Only typed kinds are followed, so every
+// read is served by the informer cache - unstructured Gets would go straight
+// to the API server on the mutation hot path - and unknown kinds terminate
+// the walk, since they cannot lead back to a StatefulSet anyway.
+func hasStatefulSetOwner(ctx context.Context, client ctrlruntimeclient.Client, namespace string, ownerReferences []metav1.OwnerReference) bool {
+	for depth := 0; depth < 3; depth++ {
+		controller := metav1.GetControllerOf(&metav1.ObjectMeta{OwnerReferences: ownerReferences})
+		if controller == nil {
+			return false
+		}
+		switch controller.Kind {
+		case "StatefulSet":
+			return true
+		case "ReplicaSet":
+			owner := &appsv1.ReplicaSet{}
+			if err := client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: controller.Name}, owner); err != nil {
+				return false
+			}
+			ownerReferences = owner.OwnerReferences
+		case "Job":
+			owner := &batchv1.Job{}
+			if err := client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: controller.Name}, owner); err != nil {
+				return false
+			}
+			ownerReferences = owner.OwnerReferences
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// skipAnnotation opts a pod out of all mutation: the webhook returns an
+// empty patch, leaving CPU requests, nodeSelector, tolerations, and affinity
+// untouched.
+const skipAnnotation = "ci-scheduling-webhook/skip"
+
+// scratchVolumeAnnotation designates, by name, an emptyDir volume the pod
+// wants converted to a memory-backed tmpfs for IO-bound work.
+const scratchVolumeAnnotation = "ci-workload.openshift.io/memory-backed-scratch"
+
+// convertScratchToMemory converts the designated scratch emptyDir to
+// medium=Memory, counting its size limit against the memory request of the
+// first container mounting it so the tmpfs is backed by real capacity. The
+// volume must carry a sizeLimit - unbounded tmpfs would let one build consume
+// a node's memory.
+func convertScratchToMemory(pod *corev1.Pod, volumeName string, logger *logrus.Entry) {
+	for i := range pod.Spec.Volumes {
+		volume := &pod.Spec.Volumes[i]
+		if volume.Name != volumeName {
+			continue
+		}
+		if volume.EmptyDir == nil || volume.EmptyDir.Medium == corev1.StorageMediumMemory {
+			return
+		}
+		if volume.EmptyDir.SizeLimit == nil {
+			logger.Warningf("not converting scratch volume %s to memory, it has no size limit", volumeName)
+			return
+		}
+		mounter := -1
+		for j := range pod.Spec.Containers {
+			if mountsVolume(&pod.Spec.Containers[j], volumeName) {
+				mounter = j
+				break
+			}
+		}
+		if mounter < 0 {
+			logger.Warningf("not converting scratch volume %s to memory, no container mounts it", volumeName)
+			return
+		}
+		volume.EmptyDir.Medium = corev1.StorageMediumMemory
+		limit := *volume.EmptyDir.SizeLimit
+		requests := pod.Spec.Containers[mounter].Resources.Requests
+		if requests == nil {
+			requests = corev1.ResourceList{}
+			pod.Spec.Containers[mounter].Resources.Requests = requests
+		}
+		memory := requests[corev1.ResourceMemory]
+		memory.Add(limit)
+		requests[corev1.ResourceMemory] = memory
+		logger.Debugf("converted scratch volume %s to memory, counted %s against container %s", volumeName, limit.String(), pod.Spec.Containers[mounter].Name)
+		return
+	}
+	logger.Warningf("not converting scratch volume %s to memory, no such emptyDir", volumeName)
+}
+
+func mountsVolume(container *corev1.Container, volumeName string) bool {
+	for _, mount := range container.VolumeMounts {
+		if mount.Name == volumeName {
+			return true
+		}
+	}
+	return false
+}
+
+// addEmptyDirSizeLimits defaults a sizeLimit onto emptyDir volumes that have
+// none, so one test writing 200GB triggers pod-level eviction instead of
+// node-level disk pressure that takes out its neighbors. Memory-backed
+// emptyDirs are bounded by the memory request already and are left alone.
+func addEmptyDirSizeLimits(pod *corev1.Pod, limit resource.Quantity) {
+	for i := range pod.Spec.Volumes {
+		emptyDir := pod.Spec.Volumes[i].EmptyDir
+		if emptyDir == nil || emptyDir.SizeLimit != nil || emptyDir.Medium == corev1.StorageMediumMemory {
+			continue
+		}
+		emptyDir.SizeLimit = &limit
+	}
+}
+
+const (
+	ingressBandwidthAnnotation = "kubernetes.io/ingress-bandwidth"
+	egressBandwidthAnnotation  = "kubernetes.io/egress-bandwidth"
+)
+
+// bandwidthLimits are the per-pod bandwidth caps for one class.
+type bandwidthLimits struct {
+	ingress string
+	egress  string
+}
+
+// addBandwidthAnnotations caps pod network bandwidth so a single
+// image-mirroring test cannot saturate a node's NIC and starve co-located
+// jobs. Explicitly set annotations are left alone.
+func addBandwidthAnnotations(pod *corev1.Pod, limits bandwidthLimits) {
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	if limits.ingress != "" {
+		if _, set := pod.Annotations[ingressBandwidthAnnotation]; !set {
+			pod.Annotations[ingressBandwidthAnnotation] = limits.ingress
+		}
+	}
+	if limits.egress != "" {
+		if _, set := pod.Annotations[egressBandwidthAnnotation]; !set {
+			pod.Annotations[egressBandwidthAnnotation] = limits.egress
+		}
+	}
+}
+
+// savings tracks the resource requests removed from admitted pods.
+type savings struct {
+	cpuMilli atomic.Int64
+}
+
+func (s *savings) recordCPUMilli(amount int64) {
+	if s != nil {
+		s.cpuMilli.Add(amount)
+	}
+}
+
+func (s *savings) totalCPUMilli() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.cpuMilli.Load()
+}
+
+func (m *podMutator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	pod := &corev1.Pod{}
+	if err := m.decoder.Decode(req, pod); err != nil {
+		logrus.WithError(err).Error("Failed to decode raw object as Pod.")
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	logger := m.logger.WithField("namespace", pod.Namespace).WithField("name", pod.Name)
+
+	if !m.namespaceInScope(pod.Namespace) {
+		return admission.Allowed("namespace out of mutation scope")
+	}
+	if pod.Annotations[skipAnnotation] == "true" {
+		// Debugging jobs and special workloads can bypass CPU shrinking and
+		// scheduling injection entirely.
+		logger.Debug("pod opted out of mutation")
+		return admission.Allowed("pod opted out of mutation via the skip annotation")
+	}
+
+	podClass, reason := m.currentClassifier().Explain(pod)
+	if podClass == scheduling.ClassNone {
+		if os, excluded := strings.CutPrefix(reason, scheduling.UnsupportedOSReasonPrefix); excluded {
+			unsupportedOSPods.WithLabelValues(os).Inc()
+		}
+		return admission.Allowed("ignoring pod outside of CI workload classes")
+	}
+	reasons := []string{reason}
+	if pinned := m.namespaceClass(ctx, pod.Namespace); pinned != "" {
+		podClass = pinned
+		reasons = append(reasons, "namespace class label")
+	}
+	if tenant := m.configTenantFor(pod); tenant != nil {
+		if tenant.PodClass != "" {
+			podClass = tenant.PodClass
+			reasons = append(reasons, fmt.Sprintf("configured tenant override for %s/%s", tenant.Org, tenant.Repo))
+		}
+	}
+	if podClass == scheduling.ClassProwjobs && m.prowJobNamespace != "" {
+		if refined, refinedReason := m.refineProwJobClass(ctx, pod); refined != "" {
+			podClass = refined
+			reasons = append(reasons, refinedReason)
+		}
+	}
+	overrides := &mutationOverrides{}
+	if classPolicy, err := m.classPolicies.policyFor(ctx, pod); err != nil {
+		logger.WithError(err).Warning("Failed to resolve classification policies, proceeding without them.")
+	} else if classPolicy != nil {
+		if scheduling.SetOf(scheduling.PodClasses)[classPolicy.Spec.PodClass] {
+			podClass = classPolicy.Spec.PodClass
+		}
+		overrides.shrinkFactor = classPolicy.Spec.ShrinkCPUFactor
+		overrides.tolerations = append(overrides.tolerations, classPolicy.Spec.Tolerations...)
+		reasons = append(reasons, fmt.Sprintf("PodClassificationPolicy %s", classPolicy.Name))
+	}
+	policy, err := m.tenantPolicies.policyFor(ctx, pod)
+	if err != nil {
+		logger.WithError(err).Warning("Failed to resolve tenant scheduling policy, proceeding without it.")
+	}
+	if policy != nil {
+		if scheduling.SetOf(scheduling.PodClasses)[policy.PodClass] {
+			podClass = policy.PodClass
+			reasons = append(reasons, "tenant policy pool override")
+		}
+		overrides.priorityClassName = policy.PriorityClassName
+		overrides.shrinkExempt = overrides.shrinkExempt || policy.ShrinkExempt
+	}
+	if podClass == scheduling.ClassTests && m.overflow.shouldOverflow(ctx) {
+		podClass = scheduling.ClassOverflow
+		reasons = append(reasons, "test pool backlog overflow routing")
+	}
+	logger = logger.WithField("class", podClass)
+	m.imageUsage.record(podClass, pod)
+
+	statefulSetOwned := false
+	if m.statefulSetPodPolicy != statefulSetPolicyFull && len(pod.OwnerReferences) > 0 {
+		//nolint:errcheck // the resolver reports false on any failure
+		_ = m.runStage(ctx, "owner-resolution", func(ctx context.Context) error {
+			statefulSetOwned = hasStatefulSetOwner(ctx, m.client, pod.Namespace, pod.OwnerReferences)
+			return nil
+		})
+	}
+	if statefulSetOwned {
+		if m.statefulSetPodPolicy == statefulSetPolicyIgnore {
+			return admission.Allowed("ignoring StatefulSet-owned pod")
+		}
+		// Relocating StatefulSet pods breaks their PV node affinity; label
+		// them for observability but leave scheduling untouched.
+		if pod.Labels == nil {
+			pod.Labels = map[string]string{}
+		}
+		pod.Labels[scheduling.LabelName] = podClass
+		marshaledPod, err := json.Marshal(pod)
+		if err != nil {
+			logger.WithError(err).Error("Could not marshal mutated Pod.")
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		response := admission.PatchResponseFromRaw(req.Object.Raw, marshaledPod)
+		response.Patches = scheduling.MinimizePatches(req.Object.Raw, response.Patches)
+		return response
+	}
+
+	record := mutationRecord{
+		Time:      time.Now(),
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Class:     podClass,
+		Reasons:   reasons,
+	}
+	if err := m.mutatePod(ctx, pod, podClass, overrides, &record, logger); err != nil {
+		logger.WithError(err).Error("Failed to mutate pod.")
+		return admission.Allowed("Failed to mutate pod, ignoring.")
+	}
+	stampDecisionAnnotations(pod, &record)
+	m.audit.record(record)
+	if response := m.rejectOversize(ctx, pod, podClass, logger); response != nil {
+		return *response
+	}
+
+	marshaledPod, err := json.Marshal(pod)
+	if err != nil {
+		logger.WithError(err).Error("Could not marshal mutated Pod.")
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	response := admission.PatchResponseFromRaw(req.Object.Raw, marshaledPod)
+	response.Patches = scheduling.MinimizePatches(req.Object.Raw, response.Patches)
+	// we need these to be deterministically ordered for testing
+	sort.Slice(response.Patches, func(i, j int) bool {
+		if response.Patches[i].Operation != response.Patches[j].Operation {
+			return response.Patches[i].Operation < response.Patches[j].Operation
+		}
+		return response.Patches[i].Path < response.Patches[j].Path
+	})
+	// Guards must be inserted after sorting so each test operation stays
+	// immediately ahead of the operation it protects.
+	response.Patches = scheduling.GuardPatches(req.Object.Raw, response.Patches, m.patchGuards)
+	return response
+}
+
+// namespaceClass returns the class an entire namespace is pinned to through
+// the ci-workload label on the Namespace object, letting the tool that
+// creates ephemeral ci-op-* namespaces pin them to a class (e.g. longtests)
+// and override per-pod heuristics.
+func (m *podMutator) namespaceClass(ctx context.Context, name string) string {
+	if m.client == nil {
+		return ""
+	}
+	namespace := &corev1.Namespace{}
+	if err := m.client.Get(ctx, types.NamespacedName{Name: name}, namespace); err != nil {
+		return ""
+	}
+	if pinned := namespace.Labels[scheduling.LabelName]; scheduling.SetOf(scheduling.PodClasses)[pinned] {
+		return pinned
+	}
+	return ""
+}
+
+// configTenantFor returns the configured tenant override matching the pod's
+// org/repo metadata labels, preferring a repo-scoped entry over an org-wide
+// one.
+func (m *podMutator) configTenantFor(pod *corev1.Pod) *schedulingv1.TenantOverride {
+	hot := m.hot.load()
+	if hot == nil {
+		return nil
+	}
+	org, repo := pod.Labels[metadataOrgLabel], pod.Labels[metadataRepoLabel]
+	if org == "" {
+		return nil
+	}
+	var orgWide *schedulingv1.TenantOverride
+	for i := range hot.tenants {
+		tenant := &hot.tenants[i]
+		if tenant.Org != org {
+			continue
+		}
+		if tenant.Repo == repo && repo != "" {
+			return tenant
+		}
+		if tenant.Repo == "" {
+			orgWide = tenant
+		}
+	}
+	return orgWide
+}
+
+// refineProwJobClass looks up the owning ProwJob of a prow-created pod
+// through the cached client and selects a more accurate class from its
+// stamped label or its type, instead of treating every prow-created pod
+// identically.
+func (m *podMutator) refineProwJobClass(ctx context.Context, pod *corev1.Pod) (string, string) {
+	prowJob := &pjapi.ProwJob{}
+	if err := m.client.Get(ctx, types.NamespacedName{Namespace: m.prowJobNamespace, Name: pod.Name}, prowJob); err != nil {
+		// Pods not named after their ProwJob, or jobs already gone, keep the
+		// generic class.
+		return "", ""
+	}
+	if stamped := prowJob.Labels[scheduling.LabelName]; scheduling.SetOf(scheduling.PodClasses)[stamped] {
+		return stamped, "owning ProwJob class label"
+	}
+	if class, mapped := m.prowJobTypeClasses[string(prowJob.Spec.Type)]; mapped {
+		return class, fmt.Sprintf("owning ProwJob type %s", prowJob.Spec.Type)
+	}
+	return "", ""
+}
+
+// dryRun evaluates a pod manifest through the full mutation pipeline without
+// recording savings, image usage, or audit entries, returning the class,
+// matched rules, and the patch that would be generated.
+func (m *podMutator) dryRun(ctx context.Context, manifest []byte) (*dryRunExplanation, error) {
+	pod := &corev1.Pod{}
+	if err := yaml.UnmarshalStrict(manifest, pod); err != nil {
+		return nil, fmt.Errorf("could not unmarshal pod manifest: %w", err)
+	}
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal pod: %w", err)
+	}
+	detached := *m
+	detached.savings = nil
+	detached.imageUsage = nil
+	detached.audit = nil
+	response := detached.Handle(ctx, admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		UID:    "dry-run",
+		Kind:   metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Object: runtime.RawExtension{Raw: raw},
+	}})
+	podClass, reason := detached.currentClassifier().Explain(pod)
+	explanation := &dryRunExplanation{
+		Class:   podClass,
+		Reasons: []string{reason},
+		Allowed: response.Allowed,
+	}
+	if response.Result != nil {
+		explanation.Message = response.Result.Message
+	}
+	if len(response.Patches) > 0 {
+		patch, err := json.Marshal(response.Patches)
+		if err != nil {
+			return nil, fmt.Errorf("could not marshal patch: %w", err)
+		}
+		explanation.Patch = patch
+	}
+	return explanation, nil
+}
+
+// mutatePod steers a classified pod onto its dedicated node pool and away
+// from nodes we would like the autoscaler to reclaim.
+func (m *podMutator) mutatePod(ctx context.Context, pod *corev1.Pod, podClass string, overrides *mutationOverrides, record *mutationRecord, logger *logrus.Entry) error {
+	if _, snapshotted := pod.Annotations[originalRequestsAnnotation]; !snapshotted {
+		snapshotOriginalRequests(pod)
+	}
+	if pod.Spec.NodeSelector == nil {
+		pod.Spec.NodeSelector = map[string]string{}
+	}
+	pod.Spec.NodeSelector[scheduling.LabelName] = podClass
+	if m.tolerationMode == tolerationModeRuntimeClass {
+		if pod.Spec.RuntimeClassName == nil {
+			runtimeClass := runtimeClassPrefix + podClass
+			pod.Spec.RuntimeClassName = &runtimeClass
+		}
+	} else {
+		addToleration(pod, corev1.Toleration{
+			Key:      scheduling.LabelName,
+			Operator: corev1.TolerationOpEqual,
+			Value:    podClass,
+			Effect:   corev1.TaintEffectNoSchedule,
+		})
+	}
+	for _, toleration := range m.classTolerations[podClass] {
+		addToleration(pod, toleration)
+	}
+	if overrides != nil {
+		for _, toleration := range overrides.tolerations {
+			addToleration(pod, toleration)
+		}
+	}
+	if overrides != nil && overrides.priorityClassName != "" && pod.Spec.PriorityClassName == "" {
+		pod.Spec.Priority = nil
+		pod.Spec.PriorityClassName = overrides.priorityClassName
+	}
+	if priorityClass, tiered := m.classPriorityClasses[podClass]; tiered && pod.Spec.PriorityClassName == "" {
+		pod.Spec.Priority = nil
+		pod.Spec.PriorityClassName = priorityClass
+	}
+	// The applied-shrink-factor annotation marks pods we already shrank; a
+	// re-admission of the same object must not compound the reduction. This
+	// replaces the old in-band signature of rounding requests to end in 1m,
+	// which collided with user-authored values like 101m.
+	shrinkExempt := overrides != nil && overrides.shrinkExempt
+	if _, alreadyShrunk := pod.Annotations[shrinkFactorAnnotation]; alreadyShrunk {
+		shrinkExempt = true
+	}
+	if namespaceMatches(pod.Namespace, m.shrinkExemptNamespaces) {
+		shrinkExempt = true
+	}
+	for key, value := range m.shrinkExemptLabels {
+		if pod.Labels[key] == value {
+			shrinkExempt = true
+		}
+	}
+	retryBoost := 1.0
+	if m.retries != nil {
+		retryBoost = m.retries.boostFactor(pod.Labels["prow.k8s.io/job"])
+		if retryBoost > 1.0 {
+			// Retried jobs get their full declared CPU back: boosting an
+			// already-shrunk request would defeat the point of the retry.
+			shrinkExempt = true
+		}
+	}
+	var rightsized map[string]bool
+	if !shrinkExempt {
+		rightsized = m.rightsizeRequests(pod, logger)
+		if len(rightsized) > 0 && record != nil {
+			record.Reasons = append(record.Reasons, "requests right-sized from historical P95 usage")
+		}
+	}
+	factor, shrink := m.shrinkFactorFor(podClass)
+	if tenant := m.configTenantFor(pod); tenant != nil && tenant.ShrinkCPUFactor != nil {
+		factor, shrink = *tenant.ShrinkCPUFactor, true
+	}
+	if overrides != nil && overrides.shrinkFactor != nil {
+		factor, shrink = *overrides.shrinkFactor, true
+	}
+	initFactor := factor
+	if m.initShrinkFactor > 0 {
+		initFactor = m.initShrinkFactor
+	}
+	limitPolicy := m.classLimitPolicy[podClass]
+	if shrink && (factor < 1.0 || initFactor < 1.0) && !shrinkExempt {
+		saved, changes := shrinkContainersWithPolicy(pod.Spec.InitContainers, initFactor, limitPolicy, m.containerShrinkRules, rightsized, logger)
+		mainSaved, mainChanges := shrinkContainersWithPolicy(pod.Spec.Containers, factor, limitPolicy, m.containerShrinkRules, rightsized, logger)
+		saved += mainSaved
+		changes = append(changes, mainChanges...)
+		if len(changes) > 0 {
+			if pod.Annotations == nil {
+				pod.Annotations = map[string]string{}
+			}
+			// Original and new values let the true per-node over-commit
+			// ratio be computed later for capacity planning.
+			pod.Annotations[requestChangesAnnotation] = strings.Join(changes, ",")
+		}
+		if saved > 0 {
+			m.savings.recordCPUMilli(saved)
+			if pod.Annotations == nil {
+				pod.Annotations = map[string]string{}
+			}
+			pod.Annotations[shrinkFactorAnnotation] = strconv.FormatFloat(factor, 'f', -1, 64)
+			if record != nil {
+				record.ShrinkFactor = pod.Annotations[shrinkFactorAnnotation]
+			}
+		}
+	}
+	if m.stripCPULimitClasses[podClass] {
+		stripCPULimits(pod)
+	}
+	m.bumpOOMMemory(pod, logger)
+	m.boostRetryRequests(pod, retryBoost, logger)
+	if m.guaranteedQoSClasses[podClass] {
+		enforceGuaranteedQoS(pod)
+	}
+	if deadline, bounded := m.classActiveDeadlineSeconds[podClass]; bounded && pod.Spec.ActiveDeadlineSeconds == nil {
+		pod.Spec.ActiveDeadlineSeconds = &deadline
+	}
+	if grace, configured := m.classTerminationGraceSeconds[podClass]; configured {
+		if pod.Spec.TerminationGracePeriodSeconds == nil || *pod.Spec.TerminationGracePeriodSeconds > grace {
+			pod.Spec.TerminationGracePeriodSeconds = &grace
+		}
+	}
+	if defaults, configured := m.classDefaultRequests[podClass]; configured {
+		addDefaultRequests(pod, defaults)
+	}
+	if request, defaulted := m.classEphemeralStorageRequest[podClass]; defaulted {
+		addEphemeralStorageRequests(pod, request)
+	}
+	if m.initCPUCapMilli > 0 && !shrinkExempt {
+		capInitContainerCPU(pod, m.initCPUCapMilli)
+	}
+	if m.cpuRequestIncrementMilli > 0 {
+		roundCPURequests(pod, m.cpuRequestIncrementMilli)
+	}
+	if len(m.requestBounds) > 0 {
+		clampRequests(pod, m.requestBounds)
+	}
+	if limits, capped := m.classBandwidth[podClass]; capped {
+		addBandwidthAnnotations(pod, limits)
+	}
+	if limit, bounded := m.classEmptyDirSizeLimit[podClass]; bounded {
+		addEmptyDirSizeLimits(pod, limit)
+	}
+	if volumeName, optedIn := pod.Annotations[scratchVolumeAnnotation]; optedIn && m.memoryScratchClasses[podClass] {
+		convertScratchToMemory(pod, volumeName, logger)
+	}
+	if m.schedulingGateClasses[podClass] && pod.Spec.NodeName == "" {
+		addSchedulingGate(pod)
+	}
+	if m.excludeControlPlane {
+		for _, role := range protectedNodeRoles {
+			addPreclusionRequirement(pod, corev1.NodeSelectorRequirement{
+				Key:      role,
+				Operator: corev1.NodeSelectorOpDoesNotExist,
+			})
+		}
+	}
+	if m.spotNodeLabel != "" && m.spotForbiddenClasses[podClass] {
+		addPreclusionRequirement(pod, corev1.NodeSelectorRequirement{
+			Key:      m.spotNodeLabel,
+			Operator: corev1.NodeSelectorOpDoesNotExist,
+		})
+	}
+	if maxSkew, spread := m.classZoneSpread[podClass]; spread {
+		addZoneSpreadConstraint(pod, podClass, maxSkew)
+	}
+	if m.colocateNamespaces {
+		addNamespaceColocation(pod)
+	}
+	if m.pinNamespaceZones {
+		if zone := m.pinnedZoneFor(ctx, pod.Namespace); zone != "" && pod.Spec.NodeSelector[corev1.LabelTopologyZone] == "" {
+			pod.Spec.NodeSelector[corev1.LabelTopologyZone] = zone
+		}
+	}
+	if m.buildSpreadClasses[podClass] && m.maxBuildsPerNodePerRepo > 0 {
+		addBuildRepoSpread(pod, m.maxBuildsPerNodePerRepo)
+	}
+	if m.avoidanceMode == avoidanceModeTaints {
+		// The avoidance taints managed on the nodes are the entirety of the
+		// scale-down avoidance story in this mode.
+		return nil
+	}
+	if m.avoidanceMode == avoidanceModeTiered {
+		var hard, soft []string
+		err := m.runStage(ctx, "preclusion-lookup", func(ctx context.Context) error {
+			var stageErr error
+			hard, soft, stageErr = m.prioritization.TieredPreclusion(ctx, podClass)
+			return stageErr
+		})
+		if err != nil {
+			logger.WithError(err).Warning("tiered preclusion lookup exceeded its budget, falling back to taint-based avoidance")
+			return nil
+		}
+		if len(hard) > 0 {
+			addPreclusionAffinity(pod, hard)
+		}
+		if len(soft) > 0 {
+			addSoftPreclusion(pod, soft)
+		}
+		if record != nil && len(hard)+len(soft) > 0 {
+			record.PrecludedHostnames = append(append([]string{}, hard...), soft...)
+			record.PrecludedComputedAt = time.Now()
+		}
+		return nil
+	}
+	if m.avoidanceMode == avoidanceModeLabel {
+		// One small DoesNotExist term replaces the per-pod hostname list;
+		// the reconciler keeps the label on precluded nodes.
+		addPreclusionLabelAffinity(pod)
+		return nil
+	}
+	var requirement corev1.NodeSelectorRequirement
+	var compacted bool
+	err := m.runStage(ctx, "preclusion-lookup", func(ctx context.Context) error {
+		var stageErr error
+		requirement, compacted, stageErr = m.prioritization.PreclusionRequirement(ctx, podClass)
+		return stageErr
+	})
+	if err != nil {
+		// The avoidance taints still steer the pod; responding promptly beats
+		// a perfect preclusion list.
+		logger.WithError(err).Warning("preclusion lookup exceeded its budget, falling back to taint-based avoidance")
+		return nil
+	}
+	if len(requirement.Values) > 0 {
+		if compacted {
+			preclusionCompactions.WithLabelValues(podClass).Inc()
+			logger.Debugf("preclusion list exceeded the cap, compacted to an allowed list of %d nodes", len(requirement.Values))
+		}
+		addPreclusionRequirement(pod, requirement)
+		if record != nil {
+			record.PrecludedHostnames = requirement.Values
+			record.PrecludedComputedAt = time.Now()
+		}
+	}
+	if m.packingPreferenceNodes > 0 {
+		var terms []corev1.PreferredSchedulingTerm
+		err := m.runStage(ctx, "packing-preference", func(ctx context.Context) error {
+			var stageErr error
+			terms, stageErr = m.prioritization.PackingPreferredTerms(ctx, podClass, m.packingPreferenceNodes)
+			return stageErr
+		})
+		if err != nil {
+			logger.WithError(err).Warning("packing preference lookup exceeded its budget, skipping the bias")
+		} else if len(terms) > 0 {
+			addPackingPreference(pod, terms)
+		}
+	}
+	return nil
+}
+
+// addPackingPreference appends preferred affinity terms biasing the pod
+// toward the most-packed nodes.
+func addPackingPreference(pod *corev1.Pod, terms []corev1.PreferredSchedulingTerm) {
+	if pod.Spec.Affinity == nil {
+		pod.Spec.Affinity = &corev1.Affinity{}
+	}
+	if pod.Spec.Affinity.NodeAffinity == nil {
+		pod.Spec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	nodeAffinity := pod.Spec.Affinity.NodeAffinity
+	nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution, terms...)
+}
+
+// rejectOversize denies pods whose CPU request exceeds what any node in the
+// class pool can provide, per the provider's instance capacity tables; such
+// pods would otherwise sit Pending forever with no actionable signal.
+func (m *podMutator) rejectOversize(ctx context.Context, pod *corev1.Pod, podClass string, logger *logrus.Entry) *admission.Response {
+	var capacity int64
+	var profile *scheduling.ProviderProfile
+	err := m.runStage(ctx, "capacity-lookup", func(ctx context.Context) error {
+		var stageErr error
+		capacity, profile, stageErr = m.prioritization.PoolCPUCapacityMilli(ctx, podClass)
+		return stageErr
+	})
+	if err != nil || capacity == 0 {
+		// An empty or unreadable pool is an autoscaling opportunity, not a
+		// reason to reject.
+		return nil
+	}
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			request := container.Resources.Requests.Cpu().MilliValue()
+			if request > capacity {
+				logger.Warningf("rejecting pod, container %s requests %dm CPU but the largest %s node in the %s pool provides %dm", container.Name, request, profile.Name, podClass, capacity)
+				response := admission.Denied(fmt.Sprintf("container %s requests %dm CPU but no node in the %s pool can provide more than %dm", container.Name, request, podClass, capacity))
+				return &response
+			}
+		}
+	}
+	return nil
+}
+
+// addToleration appends a toleration unless the pod already carries it.
+func addToleration(pod *corev1.Pod, toleration corev1.Toleration) {
+	for _, existing := range pod.Spec.Tolerations {
+		if existing == toleration {
+			return
+		}
+	}
+	pod.Spec.Tolerations = append(pod.Spec.Tolerations, toleration)
+}
+
+// shrinkPodCPURequests reduces the CPU requests of all containers in the pod
+// by the given factor and returns the total millicores removed. CI workloads
+// chronically over-request CPU, and the dedicated pools pack far better when
+// requests reflect reality. Idempotency comes from the applied-shrink-factor
+// annotation the caller stamps, not from any in-band value signature.
+
+// containerShrinkRule overrides the shrink factor for containers whose name
+// matches the pattern; the first matching rule wins.
+type containerShrinkRule struct {
+	pattern *regexp.Regexp
+	factor  float64
+}
+
+func shrinkPodCPURequests(pod *corev1.Pod, factor float64, rules []containerShrinkRule, exclude map[string]bool, logger *logrus.Entry) int64 {
+	saved := shrinkContainers(pod.Spec.InitContainers, factor, rules, exclude, logger)
+	return saved + shrinkContainers(pod.Spec.Containers, factor, rules, exclude, logger)
+}
+
+// requestChangesAnnotation records original and new CPU requests of shrunk
+// containers, e.g. "test:2000m->1600m".
+const requestChangesAnnotation = "ci-workload.openshift.io/cpu-request-changes"
+
+// originalRequestsAnnotation snapshots the exact declared requests per
+// container as a JSON blob before any mutation, so downstream tooling (cost
+// reporter, utilization analysis) can reconstruct declared vs adjusted vs
+// used without the webhook's audit log.
+const originalRequestsAnnotation = "ci-workload.openshift.io/original-requests"
+
+// snapshotOriginalRequests records the declared requests of every container
+// on the pod before mutation touches them.
+func snapshotOriginalRequests(pod *corev1.Pod) {
+	original := map[string]corev1.ResourceList{}
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			if len(container.Resources.Requests) > 0 {
+				original[container.Name] = container.Resources.Requests
+			}
+		}
+	}
+	if len(original) == 0 {
+		return
+	}
+	raw, err := json.Marshal(original)
+	if err != nil {
+		return
+	}
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[originalRequestsAnnotation] = string(raw)
+}
+
+// limit policies for shrunk containers.
+const (
+	limitPolicyKeep         = "keep"
+	limitPolicyProportional = "proportional"
+	limitPolicyStrip        = "strip"
+)
+
+// shrunkCPUSignatureMilli is the in-band signature older webhook builds left
+// on shrunk CPU requests (values rounded to end in 1m). It survives only as
+// a migration guard below.
+const shrunkCPUSignatureMilli = 1
+
+// shrinkContainers reduces the CPU requests of one container list by the
+// factor, honoring name-pattern overrides and exclusions, and returns the
+// millicores removed.
+func shrinkContainers(containers []corev1.Container, factor float64, rules []containerShrinkRule, exclude map[string]bool, logger *logrus.Entry) int64 {
+	saved, _ := shrinkContainersWithPolicy(containers, factor, limitPolicyKeep, rules, exclude, logger)
+	return saved
+}
+
+// shrinkContainersWithPolicy additionally applies the class limit policy to
+// shrunk containers and reports per-container request changes.
+func shrinkContainersWithPolicy(containers []corev1.Container, factor float64, limitPolicy string, rules []containerShrinkRule, exclude map[string]bool, logger *logrus.Entry) (int64, []string) {
+	var saved int64
+	var changes []string
+	for i := range containers {
+		if scheduling.IsArtifactContainer(containers[i].Name) {
+			// Upload containers keep their full CPU request no matter
+			// which class the surrounding pod landed in.
+			continue
+		}
+		if exclude[containers[i].Name] {
+			// Right-sized containers already reflect real usage.
+			continue
+		}
+		requests := containers[i].Resources.Requests
+		current, has := requests[corev1.ResourceCPU]
+		if !has {
+			continue
+		}
+		effective := factor
+		for _, rule := range rules {
+			if rule.pattern.MatchString(containers[i].Name) {
+				effective = rule.factor
+				break
+			}
+		}
+		milli := current.MilliValue()
+		if milli%10 == shrunkCPUSignatureMilli {
+			// Transitional: a pod shrunk by a previously deployed build
+			// carries the old value signature but no annotation; shrinking
+			// it again during rollout would compound the reduction.
+			continue
+		}
+		shrunk := int64(float64(milli) * effective)
+		if shrunk >= milli {
+			continue
+		}
+		logger.Debugf("shrinking CPU request of container %s from %dm to %dm", containers[i].Name, milli, shrunk)
+		requests[corev1.ResourceCPU] = *resource.NewMilliQuantity(shrunk, resource.DecimalSI)
+		saved += milli - shrunk
+		changes = append(changes, fmt.Sprintf("%s:%dm->%dm", containers[i].Name, milli, shrunk))
+		limits := containers[i].Resources.Limits
+		if limit, hasLimit := limits[corev1.ResourceCPU]; hasLimit {
+			switch limitPolicy {
+			case limitPolicyProportional:
+				limits[corev1.ResourceCPU] = *resource.NewMilliQuantity(int64(float64(limit.MilliValue())*effective), resource.DecimalSI)
+			case limitPolicyStrip:
+				delete(limits, corev1.ResourceCPU)
+			}
+		}
+	}
+	return saved, changes
+}
+
+// addBuildRepoSpread keeps concurrent builds of the same repo apart: with a
+// limit of one it injects required pod anti-affinity on the hostname, beyond
+// that a hard topology spread whose skew approximates the per-node cap. Pods
+// without repo metadata labels are left alone.
+func addBuildRepoSpread(pod *corev1.Pod, limit int) {
+	org, repo := pod.Labels[metadataOrgLabel], pod.Labels[metadataRepoLabel]
+	if org == "" || repo == "" {
+		return
+	}
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{
+		metadataOrgLabel:  org,
+		metadataRepoLabel: repo,
+	}}
+	if pod.Spec.Affinity == nil {
+		pod.Spec.Affinity = &corev1.Affinity{}
+	}
+	if limit == 1 {
+		if pod.Spec.Affinity.PodAntiAffinity == nil {
+			pod.Spec.Affinity.PodAntiAffinity = &corev1.PodAntiAffinity{}
+		}
+		antiAffinity := pod.Spec.Affinity.PodAntiAffinity
+		antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution = append(antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution, corev1.PodAffinityTerm{
+			LabelSelector: selector,
+			TopologyKey:   scheduling.HostnameLabelName,
+		})
+		return
+	}
+	pod.Spec.TopologySpreadConstraints = append(pod.Spec.TopologySpreadConstraints, corev1.TopologySpreadConstraint{
+		MaxSkew:           int32(limit),
+		TopologyKey:       scheduling.HostnameLabelName,
+		WhenUnsatisfiable: corev1.DoNotSchedule,
+		LabelSelector:     selector,
+	})
+}
+
+// addNamespaceColocation labels the pod with its namespace and prefers
+// scheduling next to pods carrying the same label, strongest on the same
+// node and more weakly within the zone.
+func addNamespaceColocation(pod *corev1.Pod) {
+	if pod.Labels == nil {
+		pod.Labels = map[string]string{}
+	}
+	pod.Labels[scheduling.NamespaceLabelName] = pod.Namespace
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{scheduling.NamespaceLabelName: pod.Namespace}}
+	if pod.Spec.Affinity == nil {
+		pod.Spec.Affinity = &corev1.Affinity{}
+	}
+	if pod.Spec.Affinity.PodAffinity == nil {
+		pod.Spec.Affinity.PodAffinity = &corev1.PodAffinity{}
+	}
+	podAffinity := pod.Spec.Affinity.PodAffinity
+	podAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(podAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+		corev1.WeightedPodAffinityTerm{
+			Weight: 50,
+			PodAffinityTerm: corev1.PodAffinityTerm{
+				LabelSelector: selector,
+				TopologyKey:   scheduling.HostnameLabelName,
+			},
+		},
+		corev1.WeightedPodAffinityTerm{
+			Weight: 25,
+			PodAffinityTerm: corev1.PodAffinityTerm{
+				LabelSelector: selector,
+				TopologyKey:   corev1.LabelTopologyZone,
+			},
+		},
+	)
+}
+
+// addZoneSpreadConstraint spreads class pods across zones softly; pods that
+// already declare a zone spread keep theirs.
+func addZoneSpreadConstraint(pod *corev1.Pod, podClass string, maxSkew int32) {
+	for _, constraint := range pod.Spec.TopologySpreadConstraints {
+		if constraint.TopologyKey == corev1.LabelTopologyZone {
+			return
+		}
+	}
+	pod.Spec.TopologySpreadConstraints = append(pod.Spec.TopologySpreadConstraints, corev1.TopologySpreadConstraint{
+		MaxSkew:           maxSkew,
+		TopologyKey:       corev1.LabelTopologyZone,
+		WhenUnsatisfiable: corev1.ScheduleAnyway,
+		LabelSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{scheduling.LabelName: podClass},
+		},
+	})
+	// The selector needs the class label on the pod itself to count peers.
+	if pod.Labels == nil {
+		pod.Labels = map[string]string{}
+	}
+	pod.Labels[scheduling.LabelName] = podClass
+}
+
+// addPreclusionLabelAffinity excludes all labeled avoidance candidates with
+// a single requirement, keeping pod specs small and clear of the 64-term
+// selector limits.
+func addPreclusionLabelAffinity(pod *corev1.Pod) {
+	requirement := corev1.NodeSelectorRequirement{
+		Key:      scheduling.AvoidanceStateLabelName,
+		Operator: corev1.NodeSelectorOpDoesNotExist,
+	}
+	if pod.Spec.Affinity == nil {
+		pod.Spec.Affinity = &corev1.Affinity{}
+	}
+	if pod.Spec.Affinity.NodeAffinity == nil {
+		pod.Spec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	nodeAffinity := pod.Spec.Affinity.NodeAffinity
+	if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{{}},
+		}
+	}
+	selector := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	for i := range selector.NodeSelectorTerms {
+		selector.NodeSelectorTerms[i].MatchExpressions = append(selector.NodeSelectorTerms[i].MatchExpressions, requirement)
+	}
+}
+
+var preclusionCompactions = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "ci_scheduling_webhook_preclusion_compactions_total",
+	Help: "Preclusion lists compacted to an allowed-node In expression because the NotIn list exceeded the cap.",
+}, []string{"class"})
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(preclusionCompactions)
+}
+
+// addPreclusionAffinity excludes the given hostnames from scheduling through
+// a required node affinity term. The list is bounded by the prioritization's
+// cap; nodes beyond the cap are avoided only through the soft avoidance taint.
+func addPreclusionAffinity(pod *corev1.Pod, hostnames []string) {
+	addPreclusionRequirement(pod, corev1.NodeSelectorRequirement{
+		Key:      scheduling.HostnameLabelName,
+		Operator: corev1.NodeSelectorOpNotIn,
+		Values:   hostnames,
+	})
+}
+
+// protectedNodeRoles are the node-role labels no CI workload may ever land
+// on, regardless of pool labeling mistakes.
+var protectedNodeRoles = []string{
+	"node-role.kubernetes.io/master",
+	"node-role.kubernetes.io/control-plane",
+	"node-role.kubernetes.io/infra",
+}
+
+// addSoftPreclusion adds a weighted preference away from early-avoidance
+// nodes.
+func addSoftPreclusion(pod *corev1.Pod, hostnames []string) {
+	if pod.Spec.Affinity == nil {
+		pod.Spec.Affinity = &corev1.Affinity{}
+	}
+	if pod.Spec.Affinity.NodeAffinity == nil {
+		pod.Spec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	nodeAffinity := pod.Spec.Affinity.NodeAffinity
+	nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution, corev1.PreferredSchedulingTerm{
+		Weight: 50,
+		Preference: corev1.NodeSelectorTerm{
+			MatchExpressions: []corev1.NodeSelectorRequirement{{
+				Key:      scheduling.HostnameLabelName,
+				Operator: corev1.NodeSelectorOpNotIn,
+				Values:   hostnames,
+			}},
+		},
+	})
+}
+
+// addPreclusionRequirement appends a preclusion requirement to every node
+// selector term so it stays a hard constraint.
+func addPreclusionRequirement(pod *corev1.Pod, requirement corev1.NodeSelectorRequirement) {
+	if pod.Spec.Affinity == nil {
+		pod.Spec.Affinity = &corev1.Affinity{}
+	}
+	if pod.Spec.Affinity.NodeAffinity == nil {
+		pod.Spec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	nodeAffinity := pod.Spec.Affinity.NodeAffinity
+	if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{{}},
+		}
+	}
+	selector := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	// NodeSelectorTerms are ORed, so our requirement must be appended to every
+	// term to remain a hard constraint.
+	for i := range selector.NodeSelectorTerms {
+		selector.NodeSelectorTerms[i].MatchExpressions = append(selector.NodeSelectorTerms[i].MatchExpressions, requirement)
+	}
+}
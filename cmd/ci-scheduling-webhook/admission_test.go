@@ -0,0 +1,952 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+func TestNamespaceClassPinning(t *testing.T) {
+	pinned := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "ci-op-pinned",
+		Labels: map[string]string{scheduling.LabelName: scheduling.ClassLongTests},
+	}}
+	unpinned := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ci-op-plain"}}
+	mutator := &podMutator{client: fakectrlruntimeclient.NewClientBuilder().WithObjects(pinned, unpinned).Build()}
+	if got := mutator.namespaceClass(context.Background(), "ci-op-pinned"); got != scheduling.ClassLongTests {
+		t.Errorf("expected the pinned class, got %q", got)
+	}
+	if got := mutator.namespaceClass(context.Background(), "ci-op-plain"); got != "" {
+		t.Errorf("expected no pin for an unlabeled namespace, got %q", got)
+	}
+	if got := mutator.namespaceClass(context.Background(), "missing"); got != "" {
+		t.Errorf("expected no pin for a missing namespace, got %q", got)
+	}
+}
+
+func TestNamespaceInScope(t *testing.T) {
+	mutator := &podMutator{
+		namespaceAllowlist: []string{"ci-op-*", "ci"},
+		namespaceDenylist:  []string{"ci-op-quarantine*"},
+	}
+	var testCases = []struct {
+		namespace string
+		expected  bool
+	}{
+		{namespace: "ci-op-12345", expected: true},
+		{namespace: "ci", expected: true},
+		{namespace: "ci-op-quarantine-1", expected: false},
+		{namespace: "openshift-monitoring", expected: false},
+	}
+	for _, testCase := range testCases {
+		if got := mutator.namespaceInScope(testCase.namespace); got != testCase.expected {
+			t.Errorf("%s: expected in-scope=%t, got %t", testCase.namespace, testCase.expected, got)
+		}
+	}
+	unrestricted := &podMutator{namespaceDenylist: []string{"kube-system"}}
+	if !unrestricted.namespaceInScope("anything") || unrestricted.namespaceInScope("kube-system") {
+		t.Error("expected an empty allowlist to mutate everywhere except denied namespaces")
+	}
+}
+
+func TestSkipAnnotation(t *testing.T) {
+	mutator := &podMutator{
+		logger:         logrus.WithField("test", t.Name()),
+		decoder:        admission.NewDecoder(scheme.Scheme),
+		prioritization: &scheduling.Prioritization{Client: fakectrlruntimeclient.NewClientBuilder().Build()},
+	}
+	pod := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "ci-op-12345",
+			Name:        "debug-pod",
+			Annotations: map[string]string{skipAnnotation: "true"},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "test", Image: "image"}}},
+	}
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatalf("could not marshal pod: %v", err)
+	}
+	response := mutator.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		UID:    "uid",
+		Kind:   metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Object: runtime.RawExtension{Raw: raw},
+	}})
+	if !response.Allowed || len(response.Patches) != 0 {
+		t.Errorf("expected an empty patch for an opted-out pod, got %+v", response)
+	}
+}
+
+func TestMutationStageBudget(t *testing.T) {
+	slowClient := &slowReader{Client: fakectrlruntimeclient.NewClientBuilder().Build(), delay: 200 * time.Millisecond}
+	mutator := &podMutator{
+		prioritization: &scheduling.Prioritization{Client: slowClient, MaxPrecludedHostnames: 25},
+		avoidanceMode:  avoidanceModeAffinity,
+		stageBudget:    10 * time.Millisecond,
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "pod"}}
+	started := time.Now()
+	if err := mutator.mutatePod(context.Background(), pod, scheduling.ClassTests, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("expected the budget fallback to swallow the slow lookup, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 150*time.Millisecond {
+		t.Errorf("expected the stage budget to bound mutation latency, took %s", elapsed)
+	}
+	if pod.Spec.Affinity != nil {
+		t.Error("expected no affinity when the preclusion lookup fell over budget")
+	}
+	if pod.Spec.NodeSelector[scheduling.LabelName] != scheduling.ClassTests {
+		t.Error("expected the essential mutations to still apply")
+	}
+}
+
+// slowReader delays every List to simulate a slow downstream data source.
+type slowReader struct {
+	ctrlruntimeclient.Client
+	delay time.Duration
+}
+
+func (s *slowReader) List(ctx context.Context, list ctrlruntimeclient.ObjectList, opts ...ctrlruntimeclient.ListOption) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(s.delay):
+	}
+	return s.Client.List(ctx, list, opts...)
+}
+
+func TestHasStatefulSetOwner(t *testing.T) {
+	truth := true
+	statefulSetRef := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "db", Controller: &truth}
+	deploymentRef := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-abc", Controller: &truth}
+	replicaSet := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Namespace:       "ci-op-12345",
+		Name:            "web-abc",
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Controller: &truth}},
+	}}
+	client := fakectrlruntimeclient.NewClientBuilder().WithObjects(replicaSet).Build()
+	if !hasStatefulSetOwner(context.Background(), client, "ci-op-12345", []metav1.OwnerReference{statefulSetRef}) {
+		t.Error("expected direct StatefulSet ownership to be detected")
+	}
+	if hasStatefulSetOwner(context.Background(), client, "ci-op-12345", []metav1.OwnerReference{deploymentRef}) {
+		t.Error("expected ReplicaSet/Deployment chain to not count as StatefulSet ownership")
+	}
+	if hasStatefulSetOwner(context.Background(), client, "ci-op-12345", nil) {
+		t.Error("expected ownerless pod to not count as StatefulSet-owned")
+	}
+}
+
+func TestShrinkFactorWindows(t *testing.T) {
+	windows, err := parseShrinkWindows([]string{"tests=0.4@06:00-18:00", "builds=0.6@22:00-04:00"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	at := func(hour int) func() time.Time {
+		return func() time.Time { return time.Date(2024, 1, 1, hour, 30, 0, 0, time.UTC) }
+	}
+	mutator := &podMutator{
+		shrinkCPURequests: map[string]float64{scheduling.ClassTests: 0.8},
+		shrinkWindows:     windows,
+	}
+	var testCases = []struct {
+		name     string
+		class    string
+		hour     int
+		expected float64
+	}{
+		{name: "peak window overrides base factor", class: scheduling.ClassTests, hour: 12, expected: 0.4},
+		{name: "outside window the base factor applies", class: scheduling.ClassTests, hour: 20, expected: 0.8},
+		{name: "window wrapping midnight matches late evening", class: scheduling.ClassBuilds, hour: 23, expected: 0.6},
+		{name: "window wrapping midnight matches early morning", class: scheduling.ClassBuilds, hour: 2, expected: 0.6},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			mutator.now = at(testCase.hour)
+			factor, configured := mutator.shrinkFactorFor(testCase.class)
+			if !configured || factor != testCase.expected {
+				t.Errorf("%s: expected factor %f, got %f (configured %t)", testCase.name, testCase.expected, factor, configured)
+			}
+		})
+	}
+	for _, invalid := range []string{"tests=0.4", "unknown=0.4@06:00-18:00", "tests=1.4@06:00-18:00", "tests=0.4@6am-6pm"} {
+		if _, err := parseShrinkWindows([]string{invalid}); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestShrinkPodCPURequests(t *testing.T) {
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "shrunk",
+					Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+						corev1.ResourceCPU: resource.MustParse("1000m"),
+					}},
+				},
+				{
+					Name: "legacy-signed",
+					Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+						corev1.ResourceCPU: resource.MustParse("501m"),
+					}},
+				},
+				{
+					Name: "gcsupload",
+					Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+						corev1.ResourceCPU: resource.MustParse("1000m"),
+					}},
+				},
+				{
+					Name: "no-request",
+				},
+			},
+		},
+	}
+	saved := shrinkPodCPURequests(pod, 0.5, nil, nil, logrus.WithField("test", t.Name()))
+	if saved != 500 {
+		t.Errorf("expected 500 millicores saved, got %d", saved)
+	}
+	// The legacy-signed container was shrunk by an older build and must not
+	// compound during rollout.
+	expected := []string{"500m", "501m", "1", "0"}
+	for i, container := range pod.Spec.Containers {
+		if diff := cmp.Diff(expected[i], container.Resources.Requests.Cpu().String()); diff != "" {
+			t.Errorf("container %s: incorrect CPU request: %v", container.Name, diff)
+		}
+	}
+}
+
+func TestEnforceGuaranteedQoS(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+		Name: "payload",
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+			Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("8Gi")},
+		},
+	}}}}
+	enforceGuaranteedQoS(pod)
+	resources := pod.Spec.Containers[0].Resources
+	if limit := resources.Limits[corev1.ResourceCPU]; limit.String() != "2" {
+		t.Errorf("expected the CPU limit to match the request, got %s", limit.String())
+	}
+	if request := resources.Requests[corev1.ResourceMemory]; request.String() != "8Gi" {
+		t.Errorf("expected the memory request to match the limit, got %s", request.String())
+	}
+}
+
+func TestStripCPULimits(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+		Name: "test",
+		Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("4"),
+			corev1.ResourceMemory: resource.MustParse("8Gi"),
+		}},
+	}}}}
+	stripCPULimits(pod)
+	limits := pod.Spec.Containers[0].Resources.Limits
+	if _, has := limits[corev1.ResourceCPU]; has {
+		t.Error("expected the CPU limit to be removed")
+	}
+	if memory := limits[corev1.ResourceMemory]; memory.String() != "8Gi" {
+		t.Errorf("expected the memory limit to survive, got %s", memory.String())
+	}
+}
+
+func TestControlPlaneExclusion(t *testing.T) {
+	mutator := &podMutator{
+		prioritization:      &scheduling.Prioritization{Client: fakectrlruntimeclient.NewClientBuilder().Build()},
+		avoidanceMode:       avoidanceModeTaints,
+		excludeControlPlane: true,
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "pod"}}
+	if err := mutator.mutatePod(context.Background(), pod, scheduling.ClassTests, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	requirements := pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions
+	excluded := map[string]bool{}
+	for _, requirement := range requirements {
+		if requirement.Operator == corev1.NodeSelectorOpDoesNotExist {
+			excluded[requirement.Key] = true
+		}
+	}
+	for _, role := range protectedNodeRoles {
+		if !excluded[role] {
+			t.Errorf("expected %s to be excluded", role)
+		}
+	}
+}
+
+func TestSpotNodeExclusion(t *testing.T) {
+	mutator := &podMutator{
+		prioritization:       &scheduling.Prioritization{Client: fakectrlruntimeclient.NewClientBuilder().Build()},
+		avoidanceMode:        avoidanceModeTaints,
+		spotNodeLabel:        "machine.openshift.io/interruptible-instance",
+		spotForbiddenClasses: map[string]bool{scheduling.ClassLongTests: true},
+	}
+	forbidden := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "long"}}
+	if err := mutator.mutatePod(context.Background(), forbidden, scheduling.ClassLongTests, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	terms := forbidden.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	requirement := terms[0].MatchExpressions[0]
+	if requirement.Key != "machine.openshift.io/interruptible-instance" || requirement.Operator != corev1.NodeSelectorOpDoesNotExist {
+		t.Errorf("expected the spot exclusion requirement, got %+v", requirement)
+	}
+	allowed := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "short"}}
+	if err := mutator.mutatePod(context.Background(), allowed, scheduling.ClassTests, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed.Spec.Affinity != nil {
+		t.Error("expected short tests to stay eligible for spot nodes")
+	}
+}
+
+func TestNamespaceZonePinning(t *testing.T) {
+	node := poolNode("node-0", scheduling.ClassTests, 10)
+	node.Labels[corev1.LabelTopologyZone] = "us-east-1a"
+	scheduledPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "first"},
+		Spec:       corev1.PodSpec{NodeName: "node-0"},
+	}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ci-op-12345"}}
+	client := fakectrlruntimeclient.NewClientBuilder().WithObjects(node, namespace, scheduledPod).Build()
+	pinner := &zonePinner{logger: logrus.WithField("test", t.Name()), client: client}
+	if err := pinner.reconcile(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mutator := &podMutator{
+		prioritization:    &scheduling.Prioritization{Client: client},
+		avoidanceMode:     avoidanceModeTaints,
+		client:            client,
+		pinNamespaceZones: true,
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "second"}}
+	if err := mutator.mutatePod(context.Background(), pod, scheduling.ClassTests, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if zone := pod.Spec.NodeSelector[corev1.LabelTopologyZone]; zone != "us-east-1a" {
+		t.Errorf("expected the pod to be pinned to the namespace zone, got %q", zone)
+	}
+}
+
+func TestTolerationModes(t *testing.T) {
+	client := fakectrlruntimeclient.NewClientBuilder().Build()
+	direct := &podMutator{prioritization: &scheduling.Prioritization{Client: client}, avoidanceMode: avoidanceModeTaints, tolerationMode: tolerationModeDirect}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "pod"}}
+	if err := direct.mutatePod(context.Background(), pod, scheduling.ClassTests, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pod.Spec.Tolerations) == 0 || pod.Spec.RuntimeClassName != nil {
+		t.Errorf("expected direct toleration injection, got tolerations=%v runtimeClass=%v", pod.Spec.Tolerations, pod.Spec.RuntimeClassName)
+	}
+
+	viaRuntime := &podMutator{prioritization: &scheduling.Prioritization{Client: client}, avoidanceMode: avoidanceModeTaints, tolerationMode: tolerationModeRuntimeClass}
+	pod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "pod"}}
+	if err := viaRuntime.mutatePod(context.Background(), pod, scheduling.ClassTests, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pod.Spec.RuntimeClassName == nil || *pod.Spec.RuntimeClassName != runtimeClassPrefix+scheduling.ClassTests {
+		t.Errorf("expected the per-class RuntimeClass reference, got %v", pod.Spec.RuntimeClassName)
+	}
+	if len(pod.Spec.Tolerations) != 0 {
+		t.Errorf("expected no direct class toleration in runtimeclass mode, got %v", pod.Spec.Tolerations)
+	}
+}
+
+func TestBuildRepoSpread(t *testing.T) {
+	buildPod := func() *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ci-op-12345",
+			Name:      "build",
+			Labels:    map[string]string{metadataOrgLabel: "openshift", metadataRepoLabel: "origin"},
+		}}
+	}
+	exclusive := buildPod()
+	addBuildRepoSpread(exclusive, 1)
+	if terms := exclusive.Spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution; len(terms) != 1 || terms[0].TopologyKey != scheduling.HostnameLabelName {
+		t.Errorf("expected required anti-affinity for a limit of one, got %+v", terms)
+	}
+	bounded := buildPod()
+	addBuildRepoSpread(bounded, 3)
+	if constraints := bounded.Spec.TopologySpreadConstraints; len(constraints) != 1 || constraints[0].MaxSkew != 3 || constraints[0].WhenUnsatisfiable != corev1.DoNotSchedule {
+		t.Errorf("expected a hard spread for larger limits, got %+v", constraints)
+	}
+	unlabeled := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "build"}}
+	addBuildRepoSpread(unlabeled, 1)
+	if unlabeled.Spec.Affinity != nil {
+		t.Error("expected pods without repo metadata to be left alone")
+	}
+}
+
+func TestNamespaceColocation(t *testing.T) {
+	mutator := &podMutator{
+		prioritization:     &scheduling.Prioritization{Client: fakectrlruntimeclient.NewClientBuilder().Build()},
+		avoidanceMode:      avoidanceModeTaints,
+		colocateNamespaces: true,
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "pod"}}
+	if err := mutator.mutatePod(context.Background(), pod, scheduling.ClassTests, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pod.Labels[scheduling.NamespaceLabelName] != "ci-op-12345" {
+		t.Error("expected the namespace label for peers to match on")
+	}
+	terms := pod.Spec.Affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+	if len(terms) != 2 || terms[0].PodAffinityTerm.TopologyKey != scheduling.HostnameLabelName || terms[1].PodAffinityTerm.TopologyKey != corev1.LabelTopologyZone {
+		t.Errorf("unexpected colocation terms: %+v", terms)
+	}
+	if terms[0].Weight <= terms[1].Weight {
+		t.Error("expected same-node preference to outweigh same-zone")
+	}
+}
+
+func TestZoneSpreadConstraint(t *testing.T) {
+	mutator := &podMutator{
+		prioritization:  &scheduling.Prioritization{Client: fakectrlruntimeclient.NewClientBuilder().Build()},
+		avoidanceMode:   avoidanceModeTaints,
+		classZoneSpread: map[string]int32{scheduling.ClassTests: 5},
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "pod"}}
+	if err := mutator.mutatePod(context.Background(), pod, scheduling.ClassTests, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pod.Spec.TopologySpreadConstraints) != 1 {
+		t.Fatalf("expected one spread constraint, got %+v", pod.Spec.TopologySpreadConstraints)
+	}
+	constraint := pod.Spec.TopologySpreadConstraints[0]
+	if constraint.TopologyKey != corev1.LabelTopologyZone || constraint.MaxSkew != 5 || constraint.WhenUnsatisfiable != corev1.ScheduleAnyway {
+		t.Errorf("unexpected constraint: %+v", constraint)
+	}
+	if pod.Labels[scheduling.LabelName] != scheduling.ClassTests {
+		t.Error("expected the class label for the spread selector to count peers")
+	}
+}
+
+func TestOriginalRequestsSnapshot(t *testing.T) {
+	mutator := &podMutator{
+		prioritization:    &scheduling.Prioritization{Client: fakectrlruntimeclient.NewClientBuilder().Build()},
+		avoidanceMode:     avoidanceModeTaints,
+		shrinkCPURequests: map[string]float64{scheduling.ClassTests: 0.5},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "pod"},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name:      "test",
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}},
+		}}},
+	}
+	if err := mutator.mutatePod(context.Background(), pod, scheduling.ClassTests, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var original map[string]corev1.ResourceList
+	if err := json.Unmarshal([]byte(pod.Annotations[originalRequestsAnnotation]), &original); err != nil {
+		t.Fatalf("could not parse the snapshot annotation: %v", err)
+	}
+	if cpu := original["test"][corev1.ResourceCPU]; cpu.String() != "2" {
+		t.Errorf("expected the declared request in the snapshot, got %s", cpu.String())
+	}
+	if cpu := pod.Spec.Containers[0].Resources.Requests.Cpu().String(); cpu != "1" {
+		t.Errorf("expected the live request to be shrunk, got %s", cpu)
+	}
+}
+
+func TestClassRuntimeBounds(t *testing.T) {
+	deadline, grace := int64(3600), int64(300)
+	mutator := &podMutator{
+		prioritization:               &scheduling.Prioritization{Client: fakectrlruntimeclient.NewClientBuilder().Build()},
+		avoidanceMode:                avoidanceModeTaints,
+		classActiveDeadlineSeconds:   map[string]int64{scheduling.ClassTests: deadline},
+		classTerminationGraceSeconds: map[string]int64{scheduling.ClassTests: grace},
+	}
+	// The apiserver defaults terminationGracePeriodSeconds before admission
+	// runs, so the webhook only ever sees the field set.
+	defaulted := int64(30)
+	runaway := int64(7200)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "pod"},
+		Spec:       corev1.PodSpec{TerminationGracePeriodSeconds: &runaway},
+	}
+	if err := mutator.mutatePod(context.Background(), pod, scheduling.ClassTests, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pod.Spec.ActiveDeadlineSeconds == nil || *pod.Spec.ActiveDeadlineSeconds != deadline {
+		t.Errorf("expected the deadline to be defaulted, got %v", pod.Spec.ActiveDeadlineSeconds)
+	}
+	if pod.Spec.TerminationGracePeriodSeconds == nil || *pod.Spec.TerminationGracePeriodSeconds != grace {
+		t.Errorf("expected the runaway grace period to be clamped to the cap, got %v", pod.Spec.TerminationGracePeriodSeconds)
+	}
+	short := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "short"},
+		Spec:       corev1.PodSpec{TerminationGracePeriodSeconds: &defaulted},
+	}
+	if err := mutator.mutatePod(context.Background(), short, scheduling.ClassTests, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *short.Spec.TerminationGracePeriodSeconds != defaulted {
+		t.Errorf("expected grace periods under the cap to be kept, got %v", *short.Spec.TerminationGracePeriodSeconds)
+	}
+	declared := int64(60)
+	pinned := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "pinned"},
+		Spec:       corev1.PodSpec{ActiveDeadlineSeconds: &declared, TerminationGracePeriodSeconds: &declared},
+	}
+	if err := mutator.mutatePod(context.Background(), pinned, scheduling.ClassTests, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *pinned.Spec.ActiveDeadlineSeconds != declared || *pinned.Spec.TerminationGracePeriodSeconds != declared {
+		t.Error("expected declared runtime bounds under the cap to be kept")
+	}
+}
+
+func TestShrinkExemptions(t *testing.T) {
+	mutator := &podMutator{
+		prioritization:         &scheduling.Prioritization{Client: fakectrlruntimeclient.NewClientBuilder().Build()},
+		avoidanceMode:          avoidanceModeTaints,
+		shrinkCPURequests:      map[string]float64{scheduling.ClassTests: 0.5},
+		shrinkExemptNamespaces: []string{"ci-op-payload-*"},
+		shrinkExemptLabels:     map[string]string{"tuned": "true"},
+	}
+	exemptPod := func(namespace string, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "pod", Labels: labels},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name:      "test",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2000m")}},
+			}}},
+		}
+	}
+	for name, pod := range map[string]*corev1.Pod{
+		"namespace exemption": exemptPod("ci-op-payload-123", nil),
+		"label exemption":     exemptPod("ci-op-12345", map[string]string{"tuned": "true"}),
+	} {
+		if err := mutator.mutatePod(context.Background(), pod, scheduling.ClassTests, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if cpu := pod.Spec.Containers[0].Resources.Requests.Cpu().String(); cpu != "2" {
+			t.Errorf("%s: expected no shrink, got %s", name, cpu)
+		}
+		if pod.Spec.NodeSelector[scheduling.LabelName] != scheduling.ClassTests {
+			t.Errorf("%s: expected routing mutations to still apply", name)
+		}
+	}
+}
+
+func TestLimitPolicyAndChangeAudit(t *testing.T) {
+	mutator := &podMutator{
+		prioritization:    &scheduling.Prioritization{Client: fakectrlruntimeclient.NewClientBuilder().Build()},
+		avoidanceMode:     avoidanceModeTaints,
+		shrinkCPURequests: map[string]float64{scheduling.ClassTests: 0.5},
+		classLimitPolicy:  map[string]string{scheduling.ClassTests: limitPolicyProportional},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "pod"},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "test",
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2000m")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4000m")},
+			},
+		}}},
+	}
+	if err := mutator.mutatePod(context.Background(), pod, scheduling.ClassTests, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if limit := pod.Spec.Containers[0].Resources.Limits.Cpu().String(); limit != "2" {
+		t.Errorf("expected the limit to scale proportionally, got %s", limit)
+	}
+	if changes := pod.Annotations[requestChangesAnnotation]; changes != "test:2000m->1000m" {
+		t.Errorf("expected the change audit annotation, got %q", changes)
+	}
+}
+
+func TestInitContainerReductionProfile(t *testing.T) {
+	mutator := &podMutator{
+		prioritization:    &scheduling.Prioritization{Client: fakectrlruntimeclient.NewClientBuilder().Build()},
+		avoidanceMode:     avoidanceModeTaints,
+		shrinkCPURequests: map[string]float64{scheduling.ClassTests: 0.8},
+		initShrinkFactor:  0.2,
+		initCPUCapMilli:   100,
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "pod"},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{
+				Name:      "setup",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2000m")}},
+			}},
+			Containers: []corev1.Container{{
+				Name:      "test",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2000m")}},
+			}},
+		},
+	}
+	if err := mutator.mutatePod(context.Background(), pod, scheduling.ClassTests, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 2000m * 0.2 = 400m, then capped at 100m.
+	if cpu := pod.Spec.InitContainers[0].Resources.Requests.Cpu().String(); cpu != "100m" {
+		t.Errorf("expected the init container to be aggressively reduced and capped, got %s", cpu)
+	}
+	if cpu := pod.Spec.Containers[0].Resources.Requests.Cpu().String(); cpu != "1600m" {
+		t.Errorf("expected the main container to use the class factor, got %s", cpu)
+	}
+}
+
+func TestRoundCPURequests(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		{Name: "odd", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("733m")}}},
+		{Name: "aligned", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")}}},
+	}}}
+	roundCPURequests(pod, 250)
+	if cpu := pod.Spec.Containers[0].Resources.Requests.Cpu().String(); cpu != "750m" {
+		t.Errorf("expected 733m to round up to 750m, got %s", cpu)
+	}
+	if cpu := pod.Spec.Containers[1].Resources.Requests.Cpu().String(); cpu != "500m" {
+		t.Errorf("expected aligned requests untouched, got %s", cpu)
+	}
+}
+
+func TestContainerShrinkRules(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		{Name: "test", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1000m")}}},
+		{Name: "sidecar", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1000m")}}},
+	}}}
+	rules := []containerShrinkRule{{pattern: regexp.MustCompile(`^(sidecar|entrypoint)$`), factor: 0.1}}
+	shrinkPodCPURequests(pod, 0.8, rules, nil, logrus.WithField("test", t.Name()))
+	if cpu := pod.Spec.Containers[0].Resources.Requests.Cpu().String(); cpu != "800m" {
+		t.Errorf("expected the class factor on the test container, got %s", cpu)
+	}
+	if cpu := pod.Spec.Containers[1].Resources.Requests.Cpu().String(); cpu != "100m" {
+		t.Errorf("expected the aggressive sidecar factor, got %s", cpu)
+	}
+}
+
+func TestAddDefaultRequests(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		{Name: "sidecar"},
+		{Name: "declared", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("2"),
+		}}},
+	}}}
+	addDefaultRequests(pod, defaultRequests{cpu: resource.MustParse("100m"), memory: resource.MustParse("200Mi")})
+	sidecar := pod.Spec.Containers[0].Resources.Requests
+	if sidecar.Cpu().String() != "100m" || sidecar.Memory().String() != "200Mi" {
+		t.Errorf("expected defaults on the bare container, got %v", sidecar)
+	}
+	declared := pod.Spec.Containers[1].Resources.Requests
+	if declared.Cpu().String() != "2" || len(declared) != 1 {
+		t.Errorf("expected declared containers to stay untouched, got %v", declared)
+	}
+}
+
+func TestAddEphemeralStorageRequests(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		{Name: "bare"},
+		{Name: "declared", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceEphemeralStorage: resource.MustParse("5Gi"),
+		}}},
+	}}}
+	addEphemeralStorageRequests(pod, resource.MustParse("20Gi"))
+	if got := pod.Spec.Containers[0].Resources.Requests[corev1.ResourceEphemeralStorage]; got.String() != "20Gi" {
+		t.Errorf("expected the default to be injected, got %s", got.String())
+	}
+	if got := pod.Spec.Containers[1].Resources.Requests[corev1.ResourceEphemeralStorage]; got.String() != "5Gi" {
+		t.Errorf("expected the declared request to be kept, got %s", got.String())
+	}
+}
+
+func TestClampRequests(t *testing.T) {
+	minCPU, maxCPU := resource.MustParse("10m"), resource.MustParse("8")
+	maxMemory := resource.MustParse("20Gi")
+	bounds := map[corev1.ResourceName]requestBound{
+		corev1.ResourceCPU:    {min: &minCPU, max: &maxCPU},
+		corev1.ResourceMemory: {max: &maxMemory},
+	}
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		{Name: "tiny", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1m")}}},
+		{Name: "huge", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("16"),
+			corev1.ResourceMemory: resource.MustParse("64Gi"),
+		}}},
+		{Name: "unbounded"},
+	}}}
+	clampRequests(pod, bounds)
+	if cpu := pod.Spec.Containers[0].Resources.Requests.Cpu().String(); cpu != "10m" {
+		t.Errorf("expected the minimum to apply, got %s", cpu)
+	}
+	if cpu := pod.Spec.Containers[1].Resources.Requests.Cpu().String(); cpu != "8" {
+		t.Errorf("expected the CPU maximum to apply, got %s", cpu)
+	}
+	if memory := pod.Spec.Containers[1].Resources.Requests.Memory().String(); memory != "20Gi" {
+		t.Errorf("expected the memory maximum to apply, got %s", memory)
+	}
+	if len(pod.Spec.Containers[2].Resources.Requests) != 0 {
+		t.Error("expected containers without requests to stay untouched")
+	}
+}
+
+func TestShrinkIdempotencyViaAnnotation(t *testing.T) {
+	mutator := &podMutator{
+		prioritization:    &scheduling.Prioritization{Client: fakectrlruntimeclient.NewClientBuilder().Build()},
+		avoidanceMode:     avoidanceModeTaints,
+		shrinkCPURequests: map[string]float64{scheduling.ClassTests: 0.5},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "ci-op-12345",
+			Name:        "pod",
+			Annotations: map[string]string{shrinkFactorAnnotation: "0.5"},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "test",
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+				corev1.ResourceCPU: resource.MustParse("500m"),
+			}},
+		}}},
+	}
+	if err := mutator.mutatePod(context.Background(), pod, scheduling.ClassTests, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cpu := pod.Spec.Containers[0].Resources.Requests.Cpu().String(); cpu != "500m" {
+		t.Errorf("expected the annotation marker to prevent a second shrink, got %s", cpu)
+	}
+}
+
+func TestAddBandwidthAnnotations(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{egressBandwidthAnnotation: "1G"},
+	}}
+	addBandwidthAnnotations(pod, bandwidthLimits{ingress: "50M", egress: "200M"})
+	expected := map[string]string{
+		ingressBandwidthAnnotation: "50M",
+		egressBandwidthAnnotation:  "1G",
+	}
+	if diff := cmp.Diff(expected, pod.Annotations); diff != "" {
+		t.Errorf("incorrect bandwidth annotations: %v", diff)
+	}
+}
+
+func TestAddEmptyDirSizeLimits(t *testing.T) {
+	explicit := resource.MustParse("5Gi")
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Volumes: []corev1.Volume{
+		{Name: "unbounded", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		{Name: "bounded", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{SizeLimit: &explicit}}},
+		{Name: "memory", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory}}},
+		{Name: "not-emptydir", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/"}}},
+	}}}
+	addEmptyDirSizeLimits(pod, resource.MustParse("100Gi"))
+	expected := []string{"100Gi", "5Gi", "", ""}
+	for i, volume := range pod.Spec.Volumes {
+		var got string
+		if volume.EmptyDir != nil && volume.EmptyDir.SizeLimit != nil {
+			got = volume.EmptyDir.SizeLimit.String()
+		}
+		if got != expected[i] {
+			t.Errorf("volume %s: expected size limit %q, got %q", volume.Name, expected[i], got)
+		}
+	}
+}
+
+func TestConvertScratchToMemory(t *testing.T) {
+	limit := resource.MustParse("4Gi")
+	scratchPod := func(sizeLimit *resource.Quantity, mounted bool) *corev1.Pod {
+		container := corev1.Container{
+			Name: "build",
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+				corev1.ResourceMemory: resource.MustParse("2Gi"),
+			}},
+		}
+		if mounted {
+			container.VolumeMounts = []corev1.VolumeMount{{Name: "scratch", MountPath: "/scratch"}}
+		}
+		return &corev1.Pod{Spec: corev1.PodSpec{
+			Volumes:    []corev1.Volume{{Name: "scratch", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{SizeLimit: sizeLimit}}}},
+			Containers: []corev1.Container{container},
+		}}
+	}
+	logger := logrus.WithField("test", t.Name())
+
+	converted := scratchPod(&limit, true)
+	convertScratchToMemory(converted, "scratch", logger)
+	if converted.Spec.Volumes[0].EmptyDir.Medium != corev1.StorageMediumMemory {
+		t.Error("expected scratch volume to be memory-backed")
+	}
+	if memory := converted.Spec.Containers[0].Resources.Requests[corev1.ResourceMemory]; memory.String() != "6Gi" {
+		t.Errorf("expected size limit to be counted against the memory request, got %s", memory.String())
+	}
+
+	unbounded := scratchPod(nil, true)
+	convertScratchToMemory(unbounded, "scratch", logger)
+	if unbounded.Spec.Volumes[0].EmptyDir.Medium == corev1.StorageMediumMemory {
+		t.Error("expected unbounded scratch volume to be left alone")
+	}
+
+	unmounted := scratchPod(&limit, false)
+	convertScratchToMemory(unmounted, "scratch", logger)
+	if unmounted.Spec.Volumes[0].EmptyDir.Medium == corev1.StorageMediumMemory {
+		t.Error("expected unmounted scratch volume to be left alone")
+	}
+}
+
+func TestAddPreclusionAffinity(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		pod      *corev1.Pod
+		expected int
+	}{
+		{
+			name:     "no existing affinity",
+			pod:      &corev1.Pod{},
+			expected: 1,
+		},
+		{
+			name: "existing node selector terms each get the requirement",
+			pod: &corev1.Pod{Spec: corev1.PodSpec{Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{
+						{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "foo", Operator: corev1.NodeSelectorOpExists}}},
+						{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "bar", Operator: corev1.NodeSelectorOpExists}}},
+					},
+				},
+			}}}},
+			expected: 2,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			addPreclusionAffinity(testCase.pod, []string{"node-a", "node-b"})
+			terms := testCase.pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+			if len(terms) != testCase.expected {
+				t.Fatalf("%s: expected %d node selector terms, got %d", testCase.name, testCase.expected, len(terms))
+			}
+			for _, term := range terms {
+				requirement := term.MatchExpressions[len(term.MatchExpressions)-1]
+				if requirement.Key != scheduling.HostnameLabelName || requirement.Operator != corev1.NodeSelectorOpNotIn {
+					t.Errorf("%s: last requirement is not the preclusion: %v", testCase.name, requirement)
+				}
+				if diff := cmp.Diff([]string{"node-a", "node-b"}, requirement.Values); diff != "" {
+					t.Errorf("%s: incorrect precluded hostnames: %v", testCase.name, diff)
+				}
+			}
+		})
+	}
+}
+
+func poolNode(name, podClass string, allocatablePods int64) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				scheduling.LabelName:         podClass,
+				scheduling.HostnameLabelName: name,
+			},
+		},
+		Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+			corev1.ResourcePods: *resource.NewQuantity(allocatablePods, resource.DecimalSI),
+		}},
+	}
+}
+
+func poolPods(node, podClass string, count int) []*corev1.Pod {
+	var pods []*corev1.Pod
+	for i := 0; i < count; i++ {
+		pods = append(pods, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: fmt.Sprintf("%s-pod-%d", node, i)},
+			Spec: corev1.PodSpec{
+				NodeName:     node,
+				NodeSelector: map[string]string{scheduling.LabelName: podClass},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		})
+	}
+	return pods
+}
+
+func TestMutatePodAvoidanceModes(t *testing.T) {
+	builder := fakectrlruntimeclient.NewClientBuilder()
+	for i := 0; i < 3; i++ {
+		builder.WithObjects(poolNode(fmt.Sprintf("node-%d", i), scheduling.ClassTests, 10))
+		for _, pod := range poolPods(fmt.Sprintf("node-%d", i), scheduling.ClassTests, i+1) {
+			builder.WithObjects(pod)
+		}
+	}
+	p := &scheduling.Prioritization{Client: builder.Build(), MaxPrecludedHostnames: 25}
+	var testCases = []struct {
+		name           string
+		avoidanceMode  string
+		expectAffinity bool
+	}{
+		{
+			name:           "affinity mode injects preclusion affinity",
+			avoidanceMode:  avoidanceModeAffinity,
+			expectAffinity: true,
+		},
+		{
+			name:           "taint-only mode leaves pod affinity untouched",
+			avoidanceMode:  avoidanceModeTaints,
+			expectAffinity: false,
+		},
+		{
+			name:           "tiered mode injects affinity for drained candidates",
+			avoidanceMode:  avoidanceModeTiered,
+			expectAffinity: true,
+		},
+		{
+			name:           "label mode injects the single preclusion term",
+			avoidanceMode:  avoidanceModeLabel,
+			expectAffinity: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "pod"}}
+			mutator := &podMutator{
+				prioritization: p,
+				avoidanceMode:  testCase.avoidanceMode,
+				classTolerations: map[string][]corev1.Toleration{
+					scheduling.ClassTests: {{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "ci", Effect: corev1.TaintEffectNoSchedule}},
+				},
+			}
+			if err := mutator.mutatePod(context.Background(), pod, scheduling.ClassTests, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+				t.Fatalf("%s: unexpected error: %v", testCase.name, err)
+			}
+			var foundExtra bool
+			for _, toleration := range pod.Spec.Tolerations {
+				if toleration.Key == "dedicated" && toleration.Value == "ci" {
+					foundExtra = true
+				}
+			}
+			if !foundExtra {
+				t.Errorf("%s: expected the extra class toleration to be injected", testCase.name)
+			}
+			if got := pod.Spec.Affinity != nil; got != testCase.expectAffinity {
+				t.Errorf("%s: expected affinity presence %t, got %t", testCase.name, testCase.expectAffinity, got)
+			}
+			if diff := cmp.Diff(scheduling.ClassTests, pod.Spec.NodeSelector[scheduling.LabelName]); diff != "" {
+				t.Errorf("%s: incorrect node selector: %v", testCase.name, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/interrupts"
+)
+
+// farm is one build farm's webhook the aggregator scrapes.
+type farm struct {
+	name string
+	url  string
+}
+
+// farmStatus is the last observation of one farm.
+type farmStatus struct {
+	Name      string         `json:"name"`
+	Error     string         `json:"error,omitempty"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	Status    *webhookStatus `json:"status,omitempty"`
+}
+
+// aggregator scrapes the status endpoints of every build farm's webhook and
+// serves a consolidated view, giving the test platform team one global
+// capacity picture instead of per-farm dashboards.
+type aggregator struct {
+	logger    *logrus.Entry
+	client    *http.Client
+	farms     []farm
+	tokenFile string
+	interval  time.Duration
+
+	lock     sync.RWMutex
+	statuses map[string]farmStatus
+}
+
+func parseFarms(specs []string) ([]farm, error) {
+	var farms []farm
+	for _, spec := range specs {
+		name, url, valid := strings.Cut(spec, "=")
+		if !valid || name == "" || url == "" {
+			return nil, fmt.Errorf("--farm must be in name=url form, not %q", spec)
+		}
+		farms = append(farms, farm{name: name, url: url})
+	}
+	return farms, nil
+}
+
+func (a *aggregator) run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		a.scrape(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *aggregator) scrape(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, target := range a.farms {
+		target := target
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			observation := farmStatus{Name: target.name, UpdatedAt: time.Now()}
+			status, err := a.scrapeFarm(ctx, target)
+			if err != nil {
+				a.logger.WithError(err).WithField("farm", target.name).Warning("Failed to scrape farm status.")
+				observation.Error = err.Error()
+			} else {
+				observation.Status = status
+			}
+			a.lock.Lock()
+			defer a.lock.Unlock()
+			a.statuses[target.name] = observation
+		}()
+	}
+	wg.Wait()
+}
+
+func (a *aggregator) scrapeFarm(ctx context.Context, target farm) (*webhookStatus, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(target.url, "/")+"/status", nil)
+	if err != nil {
+		return nil, err
+	}
+	if a.tokenFile != "" {
+		token, err := os.ReadFile(a.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read farm token: %w", err)
+		}
+		request.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	response, err := a.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 256))
+		return nil, fmt.Errorf("farm returned %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+	status := &webhookStatus{}
+	if err := json.NewDecoder(response.Body).Decode(status); err != nil {
+		return nil, fmt.Errorf("could not decode farm status: %w", err)
+	}
+	return status, nil
+}
+
+// globalStatus is the consolidated cross-farm document served on /api/status.
+type globalStatus struct {
+	Farms              []farmStatus `json:"farms"`
+	TotalNodes         int          `json:"totalNodes"`
+	TotalPods          int          `json:"totalPods"`
+	TotalCPUMilliSaved int64        `json:"totalCPUMilliSaved"`
+}
+
+func (a *aggregator) handleStatus(writer http.ResponseWriter, _ *http.Request) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	global := globalStatus{}
+	for _, target := range a.farms {
+		observation, scraped := a.statuses[target.name]
+		if !scraped {
+			observation = farmStatus{Name: target.name}
+		}
+		global.Farms = append(global.Farms, observation)
+		if observation.Status == nil {
+			continue
+		}
+		global.TotalCPUMilliSaved += observation.Status.TotalCPUMilliSaved
+		for _, class := range observation.Status.Classes {
+			global.TotalNodes += class.Nodes
+			global.TotalPods += class.Pods
+		}
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(writer).Encode(global); err != nil {
+		a.logger.WithError(err).Error("Failed to encode global status response.")
+	}
+}
+
+func (a *aggregator) serve(port int) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/status", a.handleStatus)
+	server := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: mux}
+	a.logger.WithField("port", port).Info("Serving aggregated farm status.")
+	interrupts.ListenAndServe(server, 0)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// mutationRecord is the webhook's full reasoning for one admitted pod,
+// retrievable later through the explain endpoint. Most "why is my job on a
+// weird node" support threads end here.
+type mutationRecord struct {
+	Time      time.Time `json:"time"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Class     string    `json:"class"`
+	// Reasons name the classification rule and every override applied on top,
+	// in order.
+	Reasons []string `json:"reasons"`
+	// ShrinkFactor is the CPU shrink factor applied, empty if none.
+	ShrinkFactor string `json:"shrinkFactor,omitempty"`
+	// PrecludedHostnames is the avoidance list injected into the pod.
+	PrecludedHostnames []string `json:"precludedHostnames,omitempty"`
+	// PrecludedComputedAt is when the preclusion list was computed, so stale
+	// cache data is visible in the explanation.
+	PrecludedComputedAt time.Time `json:"precludedComputedAt,omitempty"`
+}
+
+// auditLog keeps the most recent mutation record per pod, bounded in size.
+type auditLog struct {
+	lock    sync.Mutex
+	limit   int
+	order   []string
+	records map[string]mutationRecord
+}
+
+func newAuditLog(limit int) *auditLog {
+	return &auditLog{limit: limit, records: map[string]mutationRecord{}}
+}
+
+func (l *auditLog) record(entry mutationRecord) {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	key := entry.Namespace + "/" + entry.Name
+	if _, exists := l.records[key]; !exists {
+		l.order = append(l.order, key)
+	}
+	l.records[key] = entry
+	for len(l.order) > l.limit {
+		delete(l.records, l.order[0])
+		l.order = l.order[1:]
+	}
+}
+
+func (l *auditLog) lookup(namespace, name string) (mutationRecord, bool) {
+	if l == nil {
+		return mutationRecord{}, false
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	entry, found := l.records[namespace+"/"+name]
+	return entry, found
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/client-go/kubernetes/scheme"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+func TestAuditLogBounds(t *testing.T) {
+	log := newAuditLog(2)
+	for i := 0; i < 3; i++ {
+		log.record(mutationRecord{Namespace: "ci", Name: fmt.Sprintf("pod-%d", i), Class: "tests", Time: time.Now()})
+	}
+	if _, found := log.lookup("ci", "pod-0"); found {
+		t.Error("expected the oldest record to be evicted")
+	}
+	if _, found := log.lookup("ci", "pod-2"); !found {
+		t.Error("expected the newest record to be retained")
+	}
+	// Re-recording the same pod replaces in place without growing the log.
+	log.record(mutationRecord{Namespace: "ci", Name: "pod-2", Class: "overflow", Time: time.Now()})
+	if record, _ := log.lookup("ci", "pod-2"); record.Class != "overflow" {
+		t.Errorf("expected the record to be replaced, got %+v", record)
+	}
+	var nilLog *auditLog
+	nilLog.record(mutationRecord{})
+	if _, found := nilLog.lookup("ci", "pod"); found {
+		t.Error("expected a nil log to be inert")
+	}
+}
+
+func TestDryRunExplain(t *testing.T) {
+	mutator := &podMutator{
+		logger:         logrus.WithField("test", t.Name()),
+		prioritization: &scheduling.Prioritization{Client: fakectrlruntimeclient.NewClientBuilder().Build()},
+		decoder:        admission.NewDecoder(scheme.Scheme),
+		avoidanceMode:  avoidanceModeTaints,
+	}
+	explanation, err := mutator.dryRun(context.Background(), []byte(`
+apiVersion: v1
+kind: Pod
+metadata:
+  namespace: ci-op-12345
+  name: debug-me
+spec:
+  containers:
+  - name: test
+    image: image
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if explanation.Class != scheduling.ClassTests || !explanation.Allowed || len(explanation.Patch) == 0 {
+		t.Errorf("unexpected explanation: %+v", explanation)
+	}
+	if _, err := mutator.dryRun(context.Background(), []byte(`{not yaml`)); err == nil {
+		t.Error("expected malformed manifests to be rejected")
+	}
+}
+
+func TestHandleExplain(t *testing.T) {
+	log := newAuditLog(10)
+	log.record(mutationRecord{
+		Namespace: "ci-op-12345",
+		Name:      "test-pod",
+		Class:     "tests",
+		Reasons:   []string{"test namespace prefix"},
+	})
+	server := &adminServer{logger: logrus.WithField("test", t.Name()), audit: log}
+
+	recorder := httptest.NewRecorder()
+	server.handleExplain(recorder, httptest.NewRequest(http.MethodGet, "/explain?namespace=ci-op-12345&pod=test-pod", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected a record, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	server.handleExplain(recorder, httptest.NewRequest(http.MethodGet, "/explain?namespace=ci-op-12345&pod=unknown", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown pod, got %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	server.handleExplain(recorder, httptest.NewRequest(http.MethodGet, "/explain", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without query parameters, got %d", recorder.Code)
+	}
+}
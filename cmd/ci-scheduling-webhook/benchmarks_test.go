@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+func benchmarkPod() *corev1.Pod {
+	annotations := map[string]string{}
+	for i := 0; i < 100; i++ {
+		annotations[fmt.Sprintf("ci.openshift.io/annotation-%d", i)] = fmt.Sprintf("value-%d", i)
+	}
+	return &corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "pod", Annotations: annotations},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name:  "test",
+			Image: "image",
+		}}},
+	}
+}
+
+func BenchmarkPodMutationPatchGeneration(b *testing.B) {
+	builder := fakectrlruntimeclient.NewClientBuilder()
+	for i := 0; i < 10; i++ {
+		builder.WithObjects(poolNode(fmt.Sprintf("node-%d", i), scheduling.ClassTests, 10))
+	}
+	mutator := &podMutator{
+		logger:         logrus.WithField("benchmark", b.Name()),
+		decoder:        admission.NewDecoder(scheme.Scheme),
+		prioritization: &scheduling.Prioritization{Client: builder.Build(), MaxPrecludedHostnames: 25},
+		avoidanceMode:  avoidanceModeAffinity,
+	}
+	raw, err := json.Marshal(benchmarkPod())
+	if err != nil {
+		b.Fatalf("could not marshal pod: %v", err)
+	}
+	request := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		UID:    "benchmark",
+		Kind:   metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Object: runtime.RawExtension{Raw: raw},
+	}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if response := mutator.Handle(context.Background(), request); !response.Allowed {
+			b.Fatalf("admission unexpectedly not allowed: %v", response.Result)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	buildv1 "github.com/openshift/api/build/v1"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+// conformanceRunner drives a kind or kwok cluster that has the webhook
+// installed, creates representative nodes and pods for every class, and
+// asserts the full observable behavior: labels, taints, scheduling outcomes,
+// and scale-down avoidance flips. It is the repeatable acceptance gate before
+// promoting new images to build farms.
+type conformanceRunner struct {
+	logger *logrus.Entry
+	client ctrlruntimeclient.Client
+
+	// createNodes provisions kwok-managed fake nodes per class; on kind,
+	// existing nodes must be labeled into the pools instead.
+	createNodes bool
+	timeout     time.Duration
+
+	failures []string
+}
+
+func (r *conformanceRunner) failf(format string, args ...interface{}) {
+	failure := fmt.Sprintf(format, args...)
+	r.failures = append(r.failures, failure)
+	r.logger.Error(failure)
+}
+
+// run executes the conformance suite and returns an error if any assertion
+// failed.
+func (r *conformanceRunner) run(ctx context.Context) error {
+	if r.createNodes {
+		for _, podClass := range scheduling.PodClasses {
+			if err := r.ensureNodes(ctx, podClass); err != nil {
+				return fmt.Errorf("could not provision nodes for class %s: %w", podClass, err)
+			}
+		}
+	}
+	for _, podClass := range scheduling.PodClasses {
+		r.checkNodeTaints(ctx, podClass)
+	}
+	r.checkPodMutation(ctx)
+	r.checkAvoidanceFlips(ctx)
+	if len(r.failures) > 0 {
+		return fmt.Errorf("%d conformance assertions failed", len(r.failures))
+	}
+	r.logger.Info("All conformance assertions passed.")
+	return nil
+}
+
+// ensureNodes provisions two kwok-managed fake nodes for a class, enough for
+// avoidance to have somewhere to drain.
+func (r *conformanceRunner) ensureNodes(ctx context.Context, podClass string) error {
+	for i := 0; i < 2; i++ {
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: fmt.Sprintf("conformance-%s-%d", podClass, i),
+				Labels: map[string]string{
+					scheduling.LabelName:         podClass,
+					scheduling.HostnameLabelName: fmt.Sprintf("conformance-%s-%d", podClass, i),
+					"type":                       "kwok",
+				},
+				Annotations: map[string]string{
+					"kwok.x-k8s.io/node": "fake",
+				},
+			},
+			Status: corev1.NodeStatus{
+				Allocatable: corev1.ResourceList{corev1.ResourcePods: *resource.NewQuantity(110, resource.DecimalSI)},
+				Capacity:    corev1.ResourceList{corev1.ResourcePods: *resource.NewQuantity(110, resource.DecimalSI)},
+			},
+		}
+		if err := r.client.Create(ctx, node); err != nil && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkNodeTaints waits for every pool node to carry the class taint the node
+// mutator injects.
+func (r *conformanceRunner) checkNodeTaints(ctx context.Context, podClass string) {
+	err := wait.PollUntilContextTimeout(ctx, time.Second, r.timeout, true, func(ctx context.Context) (bool, error) {
+		nodeList := &corev1.NodeList{}
+		if err := r.client.List(ctx, nodeList, ctrlruntimeclient.MatchingLabels{scheduling.LabelName: podClass}); err != nil {
+			return false, err
+		}
+		if len(nodeList.Items) == 0 {
+			return true, nil
+		}
+		for _, node := range nodeList.Items {
+			tainted := false
+			for _, taint := range node.Spec.Taints {
+				if taint.Key == scheduling.LabelName && taint.Value == podClass {
+					tainted = true
+				}
+			}
+			if !tainted {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		r.failf("class %s: pool nodes did not receive the class taint: %v", podClass, err)
+	}
+}
+
+// representativePods builds one pod per class the way production workloads
+// look, in namespaces that classify correctly.
+func representativePods() map[string]*corev1.Pod {
+	container := []corev1.Container{{Name: "test", Image: "registry.k8s.io/pause:3.9"}}
+	return map[string]*corev1.Pod{
+		scheduling.ClassBuilds: {
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "ci-op-conformance",
+				Name:        "conformance-build",
+				Annotations: map[string]string{buildv1.BuildLabel: "conformance"},
+			},
+			Spec: corev1.PodSpec{Containers: container, RestartPolicy: corev1.RestartPolicyNever},
+		},
+		scheduling.ClassTests: {
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-conformance", Name: "conformance-test"},
+			Spec:       corev1.PodSpec{Containers: container, RestartPolicy: corev1.RestartPolicyNever},
+		},
+		scheduling.ClassProwjobs: {
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ci-conformance",
+				Name:      "conformance-prowjob",
+				Labels:    map[string]string{"created-by-prow": "true"},
+			},
+			Spec: corev1.PodSpec{Containers: container, RestartPolicy: corev1.RestartPolicyNever},
+		},
+	}
+}
+
+// checkPodMutation creates representative pods and asserts the webhook's
+// observable mutations and the resulting scheduling outcome.
+func (r *conformanceRunner) checkPodMutation(ctx context.Context) {
+	for _, namespace := range []string{"ci-op-conformance", "ci-conformance"} {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		if err := r.client.Create(ctx, ns); err != nil && !apierrors.IsAlreadyExists(err) {
+			r.failf("could not create namespace %s: %v", namespace, err)
+			return
+		}
+	}
+	for podClass, pod := range representativePods() {
+		if err := r.client.Create(ctx, pod); err != nil && !apierrors.IsAlreadyExists(err) {
+			r.failf("class %s: could not create representative pod: %v", podClass, err)
+			continue
+		}
+		created := &corev1.Pod{}
+		if err := r.client.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, created); err != nil {
+			r.failf("class %s: could not read back representative pod: %v", podClass, err)
+			continue
+		}
+		if created.Spec.NodeSelector[scheduling.LabelName] != podClass {
+			r.failf("class %s: pod was not steered to its pool, nodeSelector=%v", podClass, created.Spec.NodeSelector)
+		}
+		tolerated := false
+		for _, toleration := range created.Spec.Tolerations {
+			if toleration.Key == scheduling.LabelName && toleration.Value == podClass {
+				tolerated = true
+			}
+		}
+		if !tolerated {
+			r.failf("class %s: pod did not receive the class toleration", podClass)
+		}
+		err := wait.PollUntilContextTimeout(ctx, time.Second, r.timeout, true, func(ctx context.Context) (bool, error) {
+			scheduled := &corev1.Pod{}
+			if err := r.client.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, scheduled); err != nil {
+				return false, err
+			}
+			return scheduled.Spec.NodeName != "", nil
+		})
+		if err != nil {
+			r.failf("class %s: pod never scheduled onto the pool: %v", podClass, err)
+		}
+	}
+}
+
+// checkAvoidanceFlips waits for the avoidance taint to appear on an empty
+// pool node, proving the scale-down machinery reacts to utilization.
+func (r *conformanceRunner) checkAvoidanceFlips(ctx context.Context) {
+	err := wait.PollUntilContextTimeout(ctx, time.Second, r.timeout, true, func(ctx context.Context) (bool, error) {
+		nodeList := &corev1.NodeList{}
+		if err := r.client.List(ctx, nodeList); err != nil {
+			return false, err
+		}
+		for _, node := range nodeList.Items {
+			for _, taint := range node.Spec.Taints {
+				if taint.Key == scheduling.AvoidanceTaintName {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		r.failf("no avoidance taint ever appeared on an empty pool node: %v", err)
+	}
+}
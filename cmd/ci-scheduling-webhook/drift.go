@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+// driftTaintName is the soft taint applied to nodes whose allocatable has
+// drifted below what their machine type should provide. Silent allocatable
+// shrinkage (kubelet reserved changes, memory gone bad, missing hugepages)
+// has caused mysterious packing regressions twice.
+const driftTaintName = "ci-workload-allocatable-drift"
+
+// driftResources are the allocatable resources compared across nodes of the
+// same instance type.
+var driftResources = []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourcePods}
+
+// driftDetector periodically compares each CI node's allocatable against the
+// best value its machine type provides elsewhere in the pool, precluding and
+// alerting on drift.
+type driftDetector struct {
+	logger         *logrus.Entry
+	client         ctrlruntimeclient.Client
+	prioritization *scheduling.Prioritization
+	interval       time.Duration
+}
+
+func (d *driftDetector) run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, podClass := range scheduling.PodClasses {
+				if err := d.reconcileClass(ctx, podClass); err != nil {
+					d.logger.WithError(err).WithField("class", podClass).Error("Failed to detect allocatable drift.")
+				}
+			}
+		}
+	}
+}
+
+// expectedAllocatable determines, per instance type, the best allocatable
+// values seen in the pool; those are what every node of the type should
+// provide.
+func expectedAllocatable(utilization []scheduling.NodeUtilization) map[string]corev1.ResourceList {
+	expected := map[string]corev1.ResourceList{}
+	for _, entry := range utilization {
+		instanceType := entry.Node.Labels[scheduling.InstanceTypeLabel]
+		if instanceType == "" {
+			continue
+		}
+		best, seen := expected[instanceType]
+		if !seen {
+			best = corev1.ResourceList{}
+			expected[instanceType] = best
+		}
+		for _, name := range driftResources {
+			value := entry.Node.Status.Allocatable[name]
+			if current, has := best[name]; !has || value.Cmp(current) > 0 {
+				best[name] = value
+			}
+		}
+	}
+	return expected
+}
+
+// driftedResources lists the resources on which a node falls short of its
+// instance type's expected allocatable by more than the tolerance.
+func driftedResources(node *corev1.Node, expected corev1.ResourceList) []corev1.ResourceName {
+	var drifted []corev1.ResourceName
+	for _, name := range driftResources {
+		expectedValue, has := expected[name]
+		if !has {
+			continue
+		}
+		actual := node.Status.Allocatable[name]
+		// Tolerate 1% of jitter between otherwise identical machines.
+		tolerated := resource.NewMilliQuantity(expectedValue.MilliValue()*99/100, expectedValue.Format)
+		if actual.Cmp(*tolerated) < 0 {
+			drifted = append(drifted, name)
+		}
+	}
+	return drifted
+}
+
+func (d *driftDetector) reconcileClass(ctx context.Context, podClass string) error {
+	utilization, err := d.prioritization.ClassNodeUtilization(ctx, podClass)
+	if err != nil {
+		return err
+	}
+	expected := expectedAllocatable(utilization)
+	for _, entry := range utilization {
+		instanceType := entry.Node.Labels[scheduling.InstanceTypeLabel]
+		drifted := driftedResources(entry.Node, expected[instanceType])
+		if len(drifted) > 0 {
+			for _, name := range drifted {
+				actual, best := entry.Node.Status.Allocatable[name], expected[instanceType][name]
+				d.logger.WithField("node", entry.Node.Name).WithField("instanceType", instanceType).
+					Warningf("node allocatable %s drifted: %s, expected %s", name, actual.String(), best.String())
+			}
+		}
+		if err := reconcileTaint(ctx, d.client, entry.Node, corev1.Taint{
+			Key:    driftTaintName,
+			Value:  podClass,
+			Effect: corev1.TaintEffectPreferNoSchedule,
+		}, len(drifted) > 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reconcileTaint ensures the presence or absence of a taint on a node,
+// patching only when something has to change.
+func reconcileTaint(ctx context.Context, client ctrlruntimeclient.Client, node *corev1.Node, taint corev1.Taint, desired bool) error {
+	index := -1
+	for i, existing := range node.Spec.Taints {
+		if existing.Key == taint.Key && existing.Effect == taint.Effect {
+			index = i
+			break
+		}
+	}
+	if desired == (index >= 0) {
+		return nil
+	}
+	updated := node.DeepCopy()
+	if desired {
+		updated.Spec.Taints = append(updated.Spec.Taints, taint)
+	} else {
+		updated.Spec.Taints = append(updated.Spec.Taints[:index], updated.Spec.Taints[index+1:]...)
+	}
+	return client.Patch(ctx, updated, ctrlruntimeclient.MergeFrom(node))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+func TestDriftDetector(t *testing.T) {
+	healthy := func(name string) *corev1.Node {
+		node := poolNode(name, scheduling.ClassTests, 250)
+		node.Labels[scheduling.InstanceTypeLabel] = "m5.2xlarge"
+		node.Status.Allocatable[corev1.ResourceCPU] = resource.MustParse("7500m")
+		node.Status.Allocatable[corev1.ResourceMemory] = resource.MustParse("30Gi")
+		return node
+	}
+	drifted := healthy("node-drifted")
+	drifted.Status.Allocatable[corev1.ResourceMemory] = resource.MustParse("20Gi")
+
+	client := fakectrlruntimeclient.NewClientBuilder().
+		WithObjects(healthy("node-0"), healthy("node-1"), drifted).
+		Build()
+	detector := &driftDetector{
+		logger:         logrus.WithField("test", t.Name()),
+		client:         client,
+		prioritization: &scheduling.Prioritization{Client: client},
+	}
+	if err := detector.reconcileClass(context.Background(), scheduling.ClassTests); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	hasDriftTaint := func(name string) bool {
+		node := &corev1.Node{}
+		if err := client.Get(context.Background(), types.NamespacedName{Name: name}, node); err != nil {
+			t.Fatalf("could not get node: %v", err)
+		}
+		for _, taint := range node.Spec.Taints {
+			if taint.Key == driftTaintName {
+				return true
+			}
+		}
+		return false
+	}
+	if !hasDriftTaint("node-drifted") {
+		t.Error("expected drifted node to be tainted")
+	}
+	if hasDriftTaint("node-0") || hasDriftTaint("node-1") {
+		t.Error("expected healthy nodes to stay untainted")
+	}
+
+	// Once allocatable recovers, the taint is removed again.
+	recovered := &corev1.Node{}
+	if err := client.Get(context.Background(), types.NamespacedName{Name: "node-drifted"}, recovered); err != nil {
+		t.Fatalf("could not get node: %v", err)
+	}
+	updated := recovered.DeepCopy()
+	updated.Status.Allocatable[corev1.ResourceMemory] = resource.MustParse("30Gi")
+	if err := client.Status().Update(context.Background(), updated); err != nil {
+		t.Fatalf("could not update node: %v", err)
+	}
+	if err := detector.reconcileClass(context.Background(), scheduling.ClassTests); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hasDriftTaint("node-drifted") {
+		t.Error("expected recovered node to be untainted")
+	}
+}
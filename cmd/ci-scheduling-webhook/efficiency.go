@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+// efficiencySample is one observation of a class pool's packing state.
+type efficiencySample struct {
+	Timestamp time.Time `json:"timestamp"`
+	Class     string    `json:"class"`
+	// PackingEfficiency is the fraction of pool pod capacity in use.
+	PackingEfficiency float64 `json:"packingEfficiency"`
+	// PendingPods counts classified pods that have no node yet.
+	PendingPods int `json:"pendingPods"`
+	// ConfigHash identifies the configuration active when the sample was
+	// taken, so regressions can be correlated with config changes.
+	ConfigHash string `json:"configHash,omitempty"`
+}
+
+// efficiencyFinding is a flagged regression.
+type efficiencyFinding struct {
+	Class   string `json:"class"`
+	Metric  string `json:"metric"`
+	Summary string `json:"summary"`
+	// CorrelatedConfigChange names the config hash transition inside the
+	// regression window, if any.
+	CorrelatedConfigChange string `json:"correlatedConfigChange,omitempty"`
+}
+
+// efficiencyAnalyzer samples per-class packing efficiency and pending pods,
+// persists history, and flags week-over-week regressions. We only discover
+// efficiency regressions when the cloud bill arrives.
+type efficiencyAnalyzer struct {
+	logger         *logrus.Entry
+	prioritization *scheduling.Prioritization
+	historyFile    string
+	configFile     string
+	interval       time.Duration
+
+	lock     sync.Mutex
+	samples  []efficiencySample
+	findings []efficiencyFinding
+}
+
+func (a *efficiencyAnalyzer) run(ctx context.Context) {
+	a.loadHistory()
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.sample(ctx); err != nil {
+				a.logger.WithError(err).Error("Failed to sample packing efficiency.")
+				continue
+			}
+			a.analyze(time.Now())
+		}
+	}
+}
+
+func (a *efficiencyAnalyzer) loadHistory() {
+	if a.historyFile == "" {
+		return
+	}
+	raw, err := os.ReadFile(a.historyFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			a.logger.WithError(err).Warning("Could not load efficiency history.")
+		}
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if err := json.Unmarshal(raw, &a.samples); err != nil {
+		a.logger.WithError(err).Warning("Could not parse efficiency history.")
+	}
+}
+
+func (a *efficiencyAnalyzer) configHash() string {
+	if a.configFile == "" {
+		return ""
+	}
+	raw, err := os.ReadFile(a.configFile)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:8])
+}
+
+func (a *efficiencyAnalyzer) sample(ctx context.Context) error {
+	now := time.Now()
+	configHash := a.configHash()
+	podList := &corev1.PodList{}
+	if err := a.prioritization.Client.List(ctx, podList); err != nil {
+		return err
+	}
+	pendingByClass := map[string]int{}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Spec.NodeName == "" && pod.Status.Phase == corev1.PodPending {
+			pendingByClass[pod.Spec.NodeSelector[scheduling.LabelName]]++
+		}
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for _, podClass := range scheduling.PodClasses {
+		utilization, err := a.prioritization.ClassNodeUtilization(ctx, podClass)
+		if err != nil {
+			return err
+		}
+		capacity, used := 0, 0
+		for _, entry := range utilization {
+			capacity += int(entry.Node.Status.Allocatable.Pods().Value())
+			used += entry.PodCount
+		}
+		if capacity == 0 {
+			continue
+		}
+		a.samples = append(a.samples, efficiencySample{
+			Timestamp:         now,
+			Class:             podClass,
+			PackingEfficiency: float64(used) / float64(capacity),
+			PendingPods:       pendingByClass[podClass],
+			ConfigHash:        configHash,
+		})
+	}
+	// Drop samples past two analysis windows.
+	cutoff := now.Add(-14 * 24 * time.Hour)
+	var kept []efficiencySample
+	for _, sample := range a.samples {
+		if sample.Timestamp.After(cutoff) {
+			kept = append(kept, sample)
+		}
+	}
+	a.samples = kept
+	if a.historyFile != "" {
+		raw, err := json.Marshal(a.samples)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(a.historyFile, raw, 0o644)
+	}
+	return nil
+}
+
+func meanAndStddev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	mean := 0.0
+	for _, value := range values {
+		mean += value
+	}
+	mean /= float64(len(values))
+	variance := 0.0
+	for _, value := range values {
+		variance += (value - mean) * (value - mean)
+	}
+	variance /= float64(len(values))
+	return mean, math.Sqrt(variance)
+}
+
+// analyze compares the last week of samples against the week before, per
+// class, and flags statistically significant efficiency drops.
+func (a *efficiencyAnalyzer) analyze(now time.Time) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	weekAgo, twoWeeksAgo := now.Add(-7*24*time.Hour), now.Add(-14*24*time.Hour)
+	a.findings = nil
+	for _, podClass := range scheduling.PodClasses {
+		var currentPacking, previousPacking []float64
+		var currentPending, previousPending []float64
+		var configChange string
+		var lastHash string
+		for _, sample := range a.samples {
+			if sample.Class != podClass {
+				continue
+			}
+			switch {
+			case sample.Timestamp.After(weekAgo):
+				currentPacking = append(currentPacking, sample.PackingEfficiency)
+				currentPending = append(currentPending, float64(sample.PendingPods))
+				if lastHash != "" && sample.ConfigHash != lastHash {
+					configChange = fmt.Sprintf("%s -> %s", lastHash, sample.ConfigHash)
+				}
+			case sample.Timestamp.After(twoWeeksAgo):
+				previousPacking = append(previousPacking, sample.PackingEfficiency)
+				previousPending = append(previousPending, float64(sample.PendingPods))
+			}
+			lastHash = sample.ConfigHash
+		}
+		if len(currentPacking) < 5 || len(previousPacking) < 5 {
+			continue
+		}
+		// A shift beyond two standard deviations of last week's behavior is
+		// a regression worth a human look: packing efficiency regresses
+		// downward, pending pods regress upward.
+		currentMean, _ := meanAndStddev(currentPacking)
+		previousMean, previousStddev := meanAndStddev(previousPacking)
+		if previousStddev > 0 && previousMean-currentMean > 2*previousStddev {
+			finding := efficiencyFinding{
+				Class:                  podClass,
+				Metric:                 "packing-efficiency",
+				Summary:                fmt.Sprintf("packing efficiency dropped from %.2f to %.2f week-over-week", previousMean, currentMean),
+				CorrelatedConfigChange: configChange,
+			}
+			a.findings = append(a.findings, finding)
+			a.logger.WithField("class", podClass).WithField("configChange", configChange).Warning(finding.Summary)
+		}
+		currentMean, _ = meanAndStddev(currentPending)
+		previousMean, previousStddev = meanAndStddev(previousPending)
+		if previousStddev > 0 && currentMean-previousMean > 2*previousStddev {
+			finding := efficiencyFinding{
+				Class:                  podClass,
+				Metric:                 "pending-pods",
+				Summary:                fmt.Sprintf("pending pods rose from %.1f to %.1f week-over-week", previousMean, currentMean),
+				CorrelatedConfigChange: configChange,
+			}
+			a.findings = append(a.findings, finding)
+			a.logger.WithField("class", podClass).WithField("configChange", configChange).Warning(finding.Summary)
+		}
+	}
+}
+
+// currentFindings returns the latest flagged regressions.
+func (a *efficiencyAnalyzer) currentFindings() []efficiencyFinding {
+	if a == nil {
+		return nil
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return append([]efficiencyFinding(nil), a.findings...)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+func TestPendingPodsRegression(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	analyzer := &efficiencyAnalyzer{logger: logrus.WithField("test", t.Name())}
+	sampleWeek := func(start time.Time, pending int) {
+		for day := 0; day < 7; day++ {
+			jitter := day % 2
+			analyzer.samples = append(analyzer.samples, efficiencySample{
+				Timestamp:         start.Add(time.Duration(day) * 24 * time.Hour),
+				Class:             scheduling.ClassTests,
+				PackingEfficiency: 0.8,
+				PendingPods:       pending + jitter,
+			})
+		}
+	}
+	sampleWeek(now.Add(-14*24*time.Hour), 2)
+	sampleWeek(now.Add(-7*24*time.Hour+time.Hour), 40)
+	analyzer.analyze(now)
+	findings := analyzer.currentFindings()
+	if len(findings) != 1 || findings[0].Metric != "pending-pods" {
+		t.Fatalf("expected a pending-pods finding, got %+v", findings)
+	}
+}
+
+func TestEfficiencyAnalyzer(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	analyzer := &efficiencyAnalyzer{logger: logrus.WithField("test", t.Name())}
+	sampleWeek := func(start time.Time, efficiency float64, jitter float64, configHash string) {
+		for day := 0; day < 7; day++ {
+			value := efficiency
+			pending := 2
+			if day%2 == 0 {
+				value += jitter
+				pending++
+			} else {
+				value -= jitter
+				pending--
+			}
+			analyzer.samples = append(analyzer.samples, efficiencySample{
+				Timestamp:         start.Add(time.Duration(day) * 24 * time.Hour),
+				Class:             scheduling.ClassTests,
+				PackingEfficiency: value,
+				PendingPods:       pending,
+				ConfigHash:        configHash,
+			})
+		}
+	}
+	// A healthy previous week, then a significant drop after a config change.
+	sampleWeek(now.Add(-14*24*time.Hour), 0.8, 0.02, "aaaa")
+	sampleWeek(now.Add(-7*24*time.Hour+time.Hour), 0.5, 0.02, "bbbb")
+
+	analyzer.analyze(now)
+	findings := analyzer.currentFindings()
+	if len(findings) != 1 {
+		t.Fatalf("expected one finding, got %+v", findings)
+	}
+	if findings[0].Class != scheduling.ClassTests || findings[0].Metric != "packing-efficiency" {
+		t.Errorf("unexpected finding: %+v", findings[0])
+	}
+	if findings[0].CorrelatedConfigChange != "aaaa -> bbbb" {
+		t.Errorf("expected the config change to be correlated, got %q", findings[0].CorrelatedConfigChange)
+	}
+
+	// A stable week-over-week profile yields no findings.
+	stable := &efficiencyAnalyzer{logger: logrus.WithField("test", t.Name())}
+	analyzer.samples = nil
+	stable.samples = nil
+	sampleStable := func(start time.Time) {
+		for day := 0; day < 7; day++ {
+			stable.samples = append(stable.samples, efficiencySample{
+				Timestamp:         start.Add(time.Duration(day) * 24 * time.Hour),
+				Class:             scheduling.ClassTests,
+				PackingEfficiency: 0.8,
+			})
+		}
+	}
+	sampleStable(now.Add(-14 * 24 * time.Hour))
+	sampleStable(now.Add(-7*24*time.Hour + time.Hour))
+	stable.analyze(now)
+	if findings := stable.currentFindings(); len(findings) != 0 {
+		t.Errorf("expected no findings for stable efficiency, got %+v", findings)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+// unschedulableCauseAnnotation carries a human-readable summary of why the
+// scheduler cannot place a pod. Users cannot otherwise tell whether our
+// webhook, quota, or genuine capacity is blocking them.
+const unschedulableCauseAnnotation = "ci-workload.openshift.io/unschedulable-cause"
+
+var unschedulableCauses = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "ci_scheduling_webhook_unschedulable_pods_total",
+	Help: "FailedScheduling occurrences on classified pods, by parsed cause.",
+}, []string{"class", "cause"})
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(unschedulableCauses)
+}
+
+// schedulingCauses maps scheduler predicate failure fragments to stable cause
+// names.
+var schedulingCauses = map[string]string{
+	"Insufficient cpu":                          "insufficient-cpu",
+	"Insufficient memory":                       "insufficient-memory",
+	"Too many pods":                             "pod-capacity",
+	"didn't match Pod's node affinity/selector": "affinity-preclusion",
+	"untolerated taint":                         "taints",
+	"exceeded quota":                            "quota",
+	"unbound immediate PersistentVolumeClaims":  "storage",
+}
+
+// parseFailedSchedulingCauses extracts stable cause names from a scheduler
+// FailedScheduling event message.
+func parseFailedSchedulingCauses(message string) []string {
+	found := map[string]bool{}
+	for fragment, cause := range schedulingCauses {
+		if strings.Contains(message, fragment) {
+			found[cause] = true
+		}
+	}
+	if len(found) == 0 {
+		found["other"] = true
+	}
+	var causes []string
+	for cause := range found {
+		causes = append(causes, cause)
+	}
+	sort.Strings(causes)
+	return causes
+}
+
+// unschedulableAnnotator inspects FailedScheduling events for classified pods
+// and writes a human-readable cause summary onto the pod, plus an aggregate
+// metric by cause.
+type unschedulableAnnotator struct {
+	logger   *logrus.Entry
+	client   ctrlruntimeclient.Client
+	interval time.Duration
+
+	// seen deduplicates events already counted, by UID and count, with
+	// timestamps so entries age out instead of accumulating forever.
+	seen map[string]seenEvent
+
+	// now stands in for time.Now in tests.
+	now func() time.Time
+}
+
+type seenEvent struct {
+	count    int32
+	observed time.Time
+}
+
+func (a *unschedulableAnnotator) run(ctx context.Context) {
+	a.seen = map[string]seenEvent{}
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.reconcile(ctx); err != nil {
+				a.logger.WithError(err).Error("Failed to annotate unschedulable pods.")
+			}
+		}
+	}
+}
+
+func (a *unschedulableAnnotator) reconcile(ctx context.Context) error {
+	eventList := &corev1.EventList{}
+	if err := a.client.List(ctx, eventList); err != nil {
+		return err
+	}
+	now := time.Now()
+	if a.now != nil {
+		now = a.now()
+	}
+	for uid, entry := range a.seen {
+		if now.Sub(entry.observed) > seenRetention {
+			delete(a.seen, uid)
+		}
+	}
+	for i := range eventList.Items {
+		event := &eventList.Items[i]
+		if event.Reason != "FailedScheduling" || event.InvolvedObject.Kind != "Pod" {
+			continue
+		}
+		if entry, counted := a.seen[string(event.UID)]; counted && entry.count == event.Count {
+			continue
+		}
+		a.seen[string(event.UID)] = seenEvent{count: event.Count, observed: now}
+		if err := a.annotatePod(ctx, event); err != nil {
+			a.logger.WithError(err).WithField("pod", event.InvolvedObject.Name).Warning("Failed to annotate unschedulable pod.")
+		}
+	}
+	return nil
+}
+
+func (a *unschedulableAnnotator) annotatePod(ctx context.Context, event *corev1.Event) error {
+	pod := &corev1.Pod{}
+	if err := a.client.Get(ctx, types.NamespacedName{Namespace: event.InvolvedObject.Namespace, Name: event.InvolvedObject.Name}, pod); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	podClass, classified := pod.Spec.NodeSelector[scheduling.LabelName]
+	if !classified {
+		return nil
+	}
+	causes := parseFailedSchedulingCauses(event.Message)
+	for _, cause := range causes {
+		unschedulableCauses.WithLabelValues(podClass, cause).Inc()
+	}
+	summary := fmt.Sprintf("pod cannot schedule (%s): %s", strings.Join(causes, ", "), event.Message)
+	if pod.Annotations[unschedulableCauseAnnotation] == summary {
+		return nil
+	}
+	updated := pod.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	updated.Annotations[unschedulableCauseAnnotation] = summary
+	return a.client.Patch(ctx, updated, ctrlruntimeclient.MergeFrom(pod))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+func TestParseFailedSchedulingCauses(t *testing.T) {
+	message := "0/10 nodes are available: 3 Insufficient cpu, 7 node(s) didn't match Pod's node affinity/selector."
+	if diff := cmp.Diff([]string{"affinity-preclusion", "insufficient-cpu"}, parseFailedSchedulingCauses(message)); diff != "" {
+		t.Errorf("incorrect causes: %v", diff)
+	}
+	if diff := cmp.Diff([]string{"other"}, parseFailedSchedulingCauses("something novel")); diff != "" {
+		t.Errorf("expected unknown messages to map to other: %v", diff)
+	}
+}
+
+func TestUnschedulableAnnotator(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "test-pod"},
+		Spec:       corev1.PodSpec{NodeSelector: map[string]string{scheduling.LabelName: scheduling.ClassTests}},
+	}
+	event := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "test-pod.1", UID: "event-uid"},
+		Reason:         "FailedScheduling",
+		Count:          1,
+		Message:        "0/10 nodes are available: 10 Insufficient cpu.",
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "ci-op-12345", Name: "test-pod"},
+	}
+	client := fakectrlruntimeclient.NewClientBuilder().WithObjects(pod, event).Build()
+	annotator := &unschedulableAnnotator{
+		logger: logrus.WithField("test", t.Name()),
+		client: client,
+		seen:   map[string]seenEvent{},
+	}
+	if err := annotator.reconcile(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	annotated := &corev1.Pod{}
+	if err := client.Get(context.Background(), types.NamespacedName{Namespace: "ci-op-12345", Name: "test-pod"}, annotated); err != nil {
+		t.Fatalf("could not get pod: %v", err)
+	}
+	summary := annotated.Annotations[unschedulableCauseAnnotation]
+	if !strings.Contains(summary, "insufficient-cpu") {
+		t.Errorf("expected cause summary on pod, got %q", summary)
+	}
+}
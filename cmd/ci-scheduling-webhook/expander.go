@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// priorityExpanderConfigMap is the well-known ConfigMap the cluster
+// autoscaler's priority expander reads.
+const priorityExpanderConfigMap = "cluster-autoscaler-priority-expander"
+
+// expanderReconciler generates and maintains the priority expander ConfigMap
+// from the pod-class to machineset mapping, so the autoscaler prefers the
+// correct machinesets for each class without manual config drift.
+type expanderReconciler struct {
+	logger    *logrus.Entry
+	client    ctrlruntimeclient.Client
+	namespace string
+	// machineSets is the class to namespace/name mapping the priorities
+	// derive from.
+	machineSets map[string]string
+	interval    time.Duration
+}
+
+// expanderPriorities renders the priorities document: managed machinesets at
+// high priority, everything else at the floor.
+func expanderPriorities(machineSets map[string]string) string {
+	var names []string
+	for _, reference := range machineSets {
+		if _, name, valid := strings.Cut(reference, "/"); valid {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var builder strings.Builder
+	builder.WriteString("10:\n  - .*\n")
+	if len(names) > 0 {
+		builder.WriteString("100:\n")
+		for _, name := range names {
+			fmt.Fprintf(&builder, "  - ^%s.*\n", name)
+		}
+	}
+	return builder.String()
+}
+
+func (r *expanderReconciler) run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if err := r.reconcile(ctx); err != nil {
+			r.logger.WithError(err).Error("Failed to reconcile the priority expander ConfigMap.")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *expanderReconciler) reconcile(ctx context.Context) error {
+	priorities := expanderPriorities(r.machineSets)
+	configMap := &corev1.ConfigMap{}
+	name := types.NamespacedName{Namespace: r.namespace, Name: priorityExpanderConfigMap}
+	if err := r.client.Get(ctx, name, configMap); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: r.namespace, Name: priorityExpanderConfigMap},
+			Data:       map[string]string{"priorities": priorities},
+		}
+		return r.client.Create(ctx, configMap)
+	}
+	if configMap.Data["priorities"] == priorities {
+		return nil
+	}
+	updated := configMap.DeepCopy()
+	if updated.Data == nil {
+		updated.Data = map[string]string{}
+	}
+	updated.Data["priorities"] = priorities
+	r.logger.Debug("updating priority expander priorities")
+	return r.client.Patch(ctx, updated, ctrlruntimeclient.MergeFrom(configMap))
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+// schedulingGateName is the Pod Scheduling Readiness gate applied to
+// classified pods; the gate controller removes it once the target class has
+// headroom. This gives real admission control without rejecting pods
+// outright.
+const schedulingGateName = "scheduling.ci.openshift.io/class-headroom"
+
+// addSchedulingGate defers scheduling of the pod until the gate controller
+// decides its class has headroom.
+func addSchedulingGate(pod *corev1.Pod) {
+	for _, gate := range pod.Spec.SchedulingGates {
+		if gate.Name == schedulingGateName {
+			return
+		}
+	}
+	pod.Spec.SchedulingGates = append(pod.Spec.SchedulingGates, corev1.PodSchedulingGate{Name: schedulingGateName})
+}
+
+// gateReconciler releases the scheduling gates of classified pods as their
+// class pools develop headroom, oldest pods first.
+type gateReconciler struct {
+	logger         *logrus.Entry
+	client         ctrlruntimeclient.Client
+	prioritization *scheduling.Prioritization
+	interval       time.Duration
+
+	// concurrencyLimits cap how many pods of a class may run at once; gates
+	// release only while the class is under its limit, giving real queueing
+	// instead of a pile of Pending pods that confuse the autoscaler.
+	concurrencyLimits map[string]int
+}
+
+func (r *gateReconciler) run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, podClass := range scheduling.PodClasses {
+				if err := r.reconcileClass(ctx, podClass); err != nil {
+					r.logger.WithError(err).WithField("class", podClass).Error("Failed to release scheduling gates.")
+				}
+			}
+		}
+	}
+}
+
+// classHeadroom counts the free pod slots on schedulable nodes of the class.
+func classHeadroom(utilization []scheduling.NodeUtilization) int {
+	headroom := 0
+	for _, entry := range utilization {
+		if entry.Node.Spec.Unschedulable {
+			continue
+		}
+		if free := int(entry.Node.Status.Allocatable.Pods().Value()) - entry.PodCount; free > 0 {
+			headroom += free
+		}
+	}
+	return headroom
+}
+
+func (r *gateReconciler) reconcileClass(ctx context.Context, podClass string) error {
+	utilization, err := r.prioritization.ClassNodeUtilization(ctx, podClass)
+	if err != nil {
+		return err
+	}
+	podList := &corev1.PodList{}
+	if err := r.client.List(ctx, podList); err != nil {
+		return err
+	}
+	// Released-but-not-yet-bound pods are neither gated nor counted by node
+	// utilization; without counting them here every tick would release
+	// another budget's worth of gates whenever binding is slow - precisely
+	// the contention scenario queueing exists for.
+	var gated []*corev1.Pod
+	releasedPending := 0
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Spec.NodeSelector[scheduling.LabelName] != podClass {
+			continue
+		}
+		isGated := false
+		for _, gate := range pod.Spec.SchedulingGates {
+			if gate.Name == schedulingGateName {
+				isGated = true
+				break
+			}
+		}
+		if isGated {
+			gated = append(gated, pod)
+		} else if pod.Spec.NodeName == "" && pod.Status.Phase == corev1.PodPending {
+			releasedPending++
+		}
+	}
+	budget := classHeadroom(utilization) - releasedPending
+	if limit, limited := r.concurrencyLimits[podClass]; limited {
+		active := releasedPending
+		for _, entry := range utilization {
+			active += entry.PodCount
+		}
+		if remaining := limit - active; remaining < budget {
+			budget = remaining
+		}
+	}
+	if budget <= 0 {
+		return nil
+	}
+	sort.Slice(gated, func(i, j int) bool {
+		return gated[i].CreationTimestamp.Before(&gated[j].CreationTimestamp)
+	})
+	if len(gated) > budget {
+		gated = gated[:budget]
+	}
+	for _, pod := range gated {
+		updated := pod.DeepCopy()
+		var remaining []corev1.PodSchedulingGate
+		for _, gate := range updated.Spec.SchedulingGates {
+			if gate.Name != schedulingGateName {
+				remaining = append(remaining, gate)
+			}
+		}
+		updated.Spec.SchedulingGates = remaining
+		r.logger.WithField("namespace", pod.Namespace).WithField("name", pod.Name).WithField("class", podClass).Debug("releasing scheduling gate")
+		if err := r.client.Patch(ctx, updated, ctrlruntimeclient.MergeFrom(pod)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+func TestNeededNodes(t *testing.T) {
+	node := poolNode("node-0", scheduling.ClassTests, 10)
+	node.Status.Allocatable[corev1.ResourceCPU] = resource.MustParse("8")
+	node.Status.Allocatable[corev1.ResourceMemory] = resource.MustParse("32Gi")
+	utilization := []scheduling.NodeUtilization{{Node: node, PodCount: 1}}
+	// Six of eight cores are spoken for; two pending cores fit the free
+	// capacity, six do not.
+	if needed := neededNodes(utilization, 2000, 0, 6000, 0); needed != 0 {
+		t.Errorf("expected free capacity to absorb the queue, got %d", needed)
+	}
+	if needed := neededNodes(utilization, 18000, 0, 6000, 0); needed != 2 {
+		t.Errorf("expected two more nodes for sixteen unserved cores, got %d", needed)
+	}
+	// A single huge build exceeds free memory even though it is one pod.
+	if needed := neededNodes(utilization, 0, 48<<30, 0, 16<<30); needed != 1 {
+		t.Errorf("expected the memory demand to force a node, got %d", needed)
+	}
+	if needed := neededNodes(nil, 16000, 0, 0, 0); needed != 2 {
+		t.Errorf("expected the conservative empty-pool estimate, got %d", needed)
+	}
+}
+
+func TestAddSchedulingGate(t *testing.T) {
+	pod := &corev1.Pod{}
+	addSchedulingGate(pod)
+	addSchedulingGate(pod)
+	if len(pod.Spec.SchedulingGates) != 1 || pod.Spec.SchedulingGates[0].Name != schedulingGateName {
+		t.Errorf("expected exactly one scheduling gate, got %+v", pod.Spec.SchedulingGates)
+	}
+}
+
+func TestGateReconcilerHonorsConcurrencyLimit(t *testing.T) {
+	builder := fakectrlruntimeclient.NewClientBuilder()
+	builder.WithObjects(poolNode("node-0", scheduling.ClassTests, 10))
+	for _, pod := range poolPods("node-0", scheduling.ClassTests, 2) {
+		builder.WithObjects(pod)
+	}
+	for i := 0; i < 3; i++ {
+		builder.WithObjects(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "ci-op-12345",
+				Name:              fmt.Sprintf("queued-%d", i),
+				CreationTimestamp: metav1.NewTime(time.Date(2024, 1, 1, i, 0, 0, 0, time.UTC)),
+			},
+			Spec: corev1.PodSpec{
+				NodeSelector:    map[string]string{scheduling.LabelName: scheduling.ClassTests},
+				SchedulingGates: []corev1.PodSchedulingGate{{Name: schedulingGateName}},
+				Containers:      []corev1.Container{{Name: "test", Image: "image"}},
+			},
+		})
+	}
+	client := builder.Build()
+	reconciler := &gateReconciler{
+		logger:            logrus.WithField("test", t.Name()),
+		client:            client,
+		prioritization:    &scheduling.Prioritization{Client: client},
+		concurrencyLimits: map[string]int{scheduling.ClassTests: 3},
+	}
+	if err := reconciler.reconcileClass(context.Background(), scheduling.ClassTests); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	released := 0
+	for i := 0; i < 3; i++ {
+		pod := &corev1.Pod{}
+		if err := client.Get(context.Background(), types.NamespacedName{Namespace: "ci-op-12345", Name: fmt.Sprintf("queued-%d", i)}, pod); err != nil {
+			t.Fatalf("could not get pod: %v", err)
+		}
+		if len(pod.Spec.SchedulingGates) == 0 {
+			released++
+		}
+	}
+	// Two active pods against a limit of three leaves one slot, despite the
+	// node having eight free pod slots.
+	if released != 1 {
+		t.Errorf("expected exactly one gate released under the concurrency limit, got %d", released)
+	}
+}
+
+func TestGateReconcilerCountsReleasedPendingPods(t *testing.T) {
+	builder := fakectrlruntimeclient.NewClientBuilder()
+	builder.WithObjects(poolNode("node-0", scheduling.ClassTests, 10))
+	// Two pods already released but not yet bound, plus two still gated.
+	for i := 0; i < 2; i++ {
+		builder.WithObjects(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: fmt.Sprintf("released-%d", i)},
+			Spec: corev1.PodSpec{
+				NodeSelector: map[string]string{scheduling.LabelName: scheduling.ClassTests},
+				Containers:   []corev1.Container{{Name: "test", Image: "image"}},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodPending},
+		})
+	}
+	for i := 0; i < 2; i++ {
+		builder.WithObjects(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: fmt.Sprintf("gated-%d", i)},
+			Spec: corev1.PodSpec{
+				NodeSelector:    map[string]string{scheduling.LabelName: scheduling.ClassTests},
+				SchedulingGates: []corev1.PodSchedulingGate{{Name: schedulingGateName}},
+				Containers:      []corev1.Container{{Name: "test", Image: "image"}},
+			},
+		})
+	}
+	client := builder.Build()
+	reconciler := &gateReconciler{
+		logger:            logrus.WithField("test", t.Name()),
+		client:            client,
+		prioritization:    &scheduling.Prioritization{Client: client},
+		concurrencyLimits: map[string]int{scheduling.ClassTests: 3},
+	}
+	if err := reconciler.reconcileClass(context.Background(), scheduling.ClassTests); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	released := 0
+	for i := 0; i < 2; i++ {
+		pod := &corev1.Pod{}
+		if err := client.Get(context.Background(), types.NamespacedName{Namespace: "ci-op-12345", Name: fmt.Sprintf("gated-%d", i)}, pod); err != nil {
+			t.Fatalf("could not get pod: %v", err)
+		}
+		if len(pod.Spec.SchedulingGates) == 0 {
+			released++
+		}
+	}
+	// Two released-but-unbound pods against a limit of three leave exactly
+	// one slot; without counting them the reconciler would release both.
+	if released != 1 {
+		t.Errorf("expected exactly one more gate released while two pods are still binding, got %d", released)
+	}
+}
+
+func TestGateReconcilerReleasesWithinHeadroom(t *testing.T) {
+	builder := fakectrlruntimeclient.NewClientBuilder()
+	// One schedulable node with room for 2 more pods.
+	builder.WithObjects(poolNode("node-0", scheduling.ClassTests, 3))
+	builder.WithObjects(poolNode("node-1", scheduling.ClassTests, 3))
+	for _, pod := range poolPods("node-0", scheduling.ClassTests, 3) {
+		builder.WithObjects(pod)
+	}
+	for _, pod := range poolPods("node-1", scheduling.ClassTests, 1) {
+		builder.WithObjects(pod)
+	}
+	// Three gated pods, admitted at different times.
+	for i := 0; i < 3; i++ {
+		builder.WithObjects(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "ci-op-12345",
+				Name:              fmt.Sprintf("gated-%d", i),
+				CreationTimestamp: metav1.NewTime(time.Date(2024, 1, 1, i, 0, 0, 0, time.UTC)),
+			},
+			Spec: corev1.PodSpec{
+				NodeSelector:    map[string]string{scheduling.LabelName: scheduling.ClassTests},
+				SchedulingGates: []corev1.PodSchedulingGate{{Name: schedulingGateName}},
+				Containers:      []corev1.Container{{Name: "test", Image: "image"}},
+			},
+		})
+	}
+	client := builder.Build()
+	reconciler := &gateReconciler{
+		logger:         logrus.WithField("test", t.Name()),
+		client:         client,
+		prioritization: &scheduling.Prioritization{Client: client},
+	}
+	if err := reconciler.reconcileClass(context.Background(), scheduling.ClassTests); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	gated := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		pod := &corev1.Pod{}
+		if err := client.Get(context.Background(), types.NamespacedName{Namespace: "ci-op-12345", Name: fmt.Sprintf("gated-%d", i)}, pod); err != nil {
+			t.Fatalf("could not get pod: %v", err)
+		}
+		gated[pod.Name] = len(pod.Spec.SchedulingGates) > 0
+	}
+	// Headroom is 2, so the two oldest pods are released and the newest stays
+	// gated.
+	if gated["gated-0"] || gated["gated-1"] || !gated["gated-2"] {
+		t.Errorf("expected the two oldest pods released and the newest still gated, got %+v", gated)
+	}
+}
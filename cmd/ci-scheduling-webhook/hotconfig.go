@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+	schedulingconfig "github.com/openshift/ci-tools/pkg/scheduling/config"
+	schedulingv1 "github.com/openshift/ci-tools/pkg/scheduling/config/v1"
+)
+
+// hotConfig is the subset of configuration that can change at runtime
+// without restarting the webhook and dropping in-flight admissions:
+// classification rules and CPU shrink behavior.
+type hotConfig struct {
+	classifier    scheduling.Classifier
+	shrinkFactors map[string]float64
+	shrinkWindows []shrinkWindow
+	tenants       []schedulingv1.TenantOverride
+}
+
+// resolveHotConfig extracts the reloadable knobs from a validated
+// configuration document.
+func resolveHotConfig(configuration *schedulingv1.Configuration) (*hotConfig, error) {
+	rules, err := scheduling.ParseImageClassRules(configuration.Classification.ImageRules)
+	if err != nil {
+		return nil, err
+	}
+	hot := &hotConfig{
+		classifier: scheduling.Classifier{
+			ImageClassRules: rules,
+			IOIntensiveJobs: setOf(configuration.Classification.IOIntensiveJobs),
+		},
+		shrinkFactors: map[string]float64{},
+	}
+	var windowSpecs []string
+	for name, class := range configuration.Classes {
+		if class.ShrinkCPUFactor != nil {
+			hot.shrinkFactors[name] = *class.ShrinkCPUFactor
+		}
+		for _, window := range class.ShrinkWindows {
+			windowSpecs = append(windowSpecs, fmt.Sprintf("%s=%g@%s-%s", name, window.Factor, window.Start, window.End))
+		}
+	}
+	if hot.shrinkWindows, err = parseShrinkWindows(windowSpecs); err != nil {
+		return nil, err
+	}
+	hot.tenants = configuration.Tenants
+	return hot, nil
+}
+
+// configWatcher reloads the configuration document when the file changes, so
+// ConfigMap-mounted config propagates without a restart. Mutators read the
+// current snapshot through an atomic pointer; a failed reload keeps the last
+// good configuration.
+type configWatcher struct {
+	logger  *logrus.Entry
+	path    string
+	current atomic.Pointer[hotConfig]
+}
+
+func newConfigWatcher(logger *logrus.Entry, path string, initial *hotConfig) *configWatcher {
+	watcher := &configWatcher{logger: logger, path: path}
+	watcher.current.Store(initial)
+	return watcher
+}
+
+func (w *configWatcher) load() *hotConfig {
+	if w == nil {
+		return nil
+	}
+	return w.current.Load()
+}
+
+func (w *configWatcher) reload() {
+	configuration, err := schedulingconfig.Load(w.path)
+	if err != nil {
+		w.logger.WithError(err).Error("Failed to reload configuration, keeping the previous one.")
+		return
+	}
+	hot, err := resolveHotConfig(configuration)
+	if err != nil {
+		w.logger.WithError(err).Error("Failed to resolve reloaded configuration, keeping the previous one.")
+		return
+	}
+	w.current.Store(hot)
+	w.logger.Info("Reloaded configuration.")
+}
+
+func (w *configWatcher) run(ctx context.Context) {
+	notifier, err := fsnotify.NewWatcher()
+	if err != nil {
+		w.logger.WithError(err).Error("Could not create filesystem watcher, configuration will not hot-reload.")
+		return
+	}
+	defer notifier.Close()
+	if err := notifier.Add(w.path); err != nil {
+		w.logger.WithError(err).Error("Could not watch configuration file, configuration will not hot-reload.")
+		return
+	}
+	// ConfigMap mounts update through symlink swaps that some notifiers miss;
+	// a slow periodic reload backstops the watcher.
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-notifier.Events:
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove) != 0 {
+				// Re-add after remove, the typical ConfigMap update sequence.
+				_ = notifier.Add(w.path)
+				w.reload()
+			}
+		case err := <-notifier.Errors:
+			w.logger.WithError(err).Warning("Filesystem watcher error.")
+		case <-ticker.C:
+			w.reload()
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+	schedulingconfig "github.com/openshift/ci-tools/pkg/scheduling/config"
+)
+
+func TestConfigWatcherReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("could not write configuration: %v", err)
+		}
+	}
+	write(`apiVersion: scheduling.ci.openshift.io/v1
+kind: Configuration
+classes:
+  tests:
+    shrinkCPUFactor: 0.5
+`)
+	configuration, err := schedulingconfig.Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	initial, err := resolveHotConfig(configuration)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	watcher := newConfigWatcher(logrus.WithField("test", t.Name()), path, initial)
+	mutator := &podMutator{hot: watcher}
+
+	if factor, _ := mutator.shrinkFactorFor(scheduling.ClassTests); factor != 0.5 {
+		t.Errorf("expected initial factor 0.5, got %f", factor)
+	}
+
+	write(`apiVersion: scheduling.ci.openshift.io/v1
+kind: Configuration
+classes:
+  tests:
+    shrinkCPUFactor: 0.25
+classification:
+  ioIntensiveJobs:
+  - periodic-io-heavy
+`)
+	watcher.reload()
+	if factor, _ := mutator.shrinkFactorFor(scheduling.ClassTests); factor != 0.25 {
+		t.Errorf("expected reloaded factor 0.25, got %f", factor)
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "ci",
+		Labels:    map[string]string{"created-by-prow": "true", "prow.k8s.io/job": "periodic-io-heavy"},
+	}}
+	if got := mutator.currentClassifier().Classify(pod); got != scheduling.ClassIOIntensive {
+		t.Errorf("expected reloaded classification rules to apply, got %q", got)
+	}
+	// Flag-configured classifier inputs survive the hot overlay.
+	mutator.classifier.AcceleratorResources = map[string]bool{"habana.ai/gaudi": true}
+	gpuPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345"},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "test",
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+				"habana.ai/gaudi": resource.MustParse("1"),
+			}},
+		}}},
+	}
+	if got := mutator.currentClassifier().Classify(gpuPod); got != scheduling.ClassGPU {
+		t.Errorf("expected flag-configured accelerator rules to survive the reload, got %q", got)
+	}
+
+	// Tenant overrides resolve through the same snapshot.
+	write(`apiVersion: scheduling.ci.openshift.io/v1
+kind: Configuration
+classes:
+  tests:
+    shrinkCPUFactor: 0.25
+classification:
+  ioIntensiveJobs:
+  - periodic-io-heavy
+tenants:
+- org: openshift
+  repo: origin
+  podClass: longtests
+- org: openshift
+  podClass: tests
+`)
+	watcher.reload()
+	repoPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "ci-op-12345",
+		Labels:    map[string]string{metadataOrgLabel: "openshift", metadataRepoLabel: "origin"},
+	}}
+	if tenant := mutator.configTenantFor(repoPod); tenant == nil || tenant.PodClass != scheduling.ClassLongTests {
+		t.Errorf("expected the repo-scoped tenant override, got %+v", tenant)
+	}
+	orgPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "ci-op-12345",
+		Labels:    map[string]string{metadataOrgLabel: "openshift", metadataRepoLabel: "other"},
+	}}
+	if tenant := mutator.configTenantFor(orgPod); tenant == nil || tenant.PodClass != scheduling.ClassTests {
+		t.Errorf("expected the org-wide tenant override, got %+v", tenant)
+	}
+
+	// A broken reload keeps the last good configuration.
+	write(`apiVersion: scheduling.ci.openshift.io/v1
+kind: Configuration
+classes:
+  tests:
+    shrinkCPUFactor: 9.0
+`)
+	watcher.reload()
+	if factor, _ := mutator.shrinkFactorFor(scheduling.ClassTests); factor != 0.25 {
+		t.Errorf("expected the last good configuration to survive a broken reload, got %f", factor)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+// machineAnnotation links a node to its Machine, as maintained by the
+// machine API.
+const machineAnnotation = "machine.openshift.io/machine"
+
+var (
+	machineGVK     = schema.GroupVersionKind{Group: "machine.openshift.io", Version: "v1beta1", Kind: "Machine"}
+	machineSetGVK2 = schema.GroupVersionKind{Group: "machine.openshift.io", Version: "v1beta1", Kind: "MachineSet"}
+)
+
+// machineScaleDown completes the scale-down loop: once a node has drained
+// under avoidance, its Machine is annotated for deletion and the owning
+// MachineSet's replica count decremented, instead of waiting for the cluster
+// autoscaler to notice the empty node.
+type machineScaleDown struct {
+	logger         *logrus.Entry
+	client         ctrlruntimeclient.Client
+	prioritization *scheduling.Prioritization
+	interval       time.Duration
+}
+
+func (c *machineScaleDown) run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, podClass := range scheduling.PodClasses {
+				if err := c.reconcileClass(ctx, podClass); err != nil {
+					c.logger.WithError(err).WithField("class", podClass).Error("Failed to complete scale-down.")
+				}
+			}
+		}
+	}
+}
+
+func (c *machineScaleDown) reconcileClass(ctx context.Context, podClass string) error {
+	utilization, err := c.prioritization.ClassNodeUtilization(ctx, podClass)
+	if err != nil {
+		return err
+	}
+	candidates := map[string]bool{}
+	for _, candidate := range c.prioritization.ScaleDownCandidatesOf(podClass, utilization) {
+		candidates[candidate.Node.Name] = true
+	}
+	for _, entry := range utilization {
+		if !candidates[entry.Node.Name] || entry.PodCount > 0 {
+			continue
+		}
+		if err := c.releaseMachine(ctx, entry); err != nil {
+			c.logger.WithError(err).WithField("node", entry.Node.Name).Warning("Failed to release drained node's machine.")
+		}
+	}
+	return nil
+}
+
+func (c *machineScaleDown) releaseMachine(ctx context.Context, entry scheduling.NodeUtilization) error {
+	reference := entry.Node.Annotations[machineAnnotation]
+	namespace, name, valid := strings.Cut(reference, "/")
+	if !valid {
+		// Nodes without machine backing (bare metal, kind) stay with the
+		// autoscaler.
+		return nil
+	}
+	machine := &unstructured.Unstructured{}
+	machine.SetGroupVersionKind(machineGVK)
+	if err := c.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, machine); err != nil {
+		return fmt.Errorf("could not get machine: %w", err)
+	}
+	deletionAnnotation := scheduling.ProfileForProviderID(entry.Node.Spec.ProviderID).MachineDeletionAnnotation
+	if deletionAnnotation == "" {
+		return nil
+	}
+	annotations := machine.GetAnnotations()
+	if annotations[deletionAnnotation] == "true" {
+		// Already released; the machineset was decremented then.
+		return nil
+	}
+	updated := machine.DeepCopy()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[deletionAnnotation] = "true"
+	updated.SetAnnotations(annotations)
+	if err := c.client.Patch(ctx, updated, ctrlruntimeclient.MergeFrom(machine)); err != nil {
+		return fmt.Errorf("could not annotate machine for deletion: %w", err)
+	}
+	c.logger.WithField("node", entry.Node.Name).WithField("machine", reference).Info("annotated drained node's machine for deletion")
+	return c.decrementOwningMachineSet(ctx, machine)
+}
+
+func (c *machineScaleDown) decrementOwningMachineSet(ctx context.Context, machine *unstructured.Unstructured) error {
+	var owner *metav1.OwnerReference
+	references := machine.GetOwnerReferences()
+	for i := range references {
+		if references[i].Kind == machineSetGVK2.Kind {
+			owner = &references[i]
+			break
+		}
+	}
+	if owner == nil {
+		return nil
+	}
+	machineSet := &unstructured.Unstructured{}
+	machineSet.SetGroupVersionKind(machineSetGVK2)
+	if err := c.client.Get(ctx, types.NamespacedName{Namespace: machine.GetNamespace(), Name: owner.Name}, machineSet); err != nil {
+		return fmt.Errorf("could not get machineset: %w", err)
+	}
+	replicas, found, err := unstructured.NestedInt64(machineSet.Object, "spec", "replicas")
+	if err != nil || !found || replicas < 1 {
+		return err
+	}
+	updated := machineSet.DeepCopy()
+	if err := unstructured.SetNestedField(updated.Object, replicas-1, "spec", "replicas"); err != nil {
+		return err
+	}
+	c.logger.WithField("machineset", owner.Name).Infof("decrementing replicas from %d to %d", replicas, replicas-1)
+	return c.client.Patch(ctx, updated, ctrlruntimeclient.MergeFrom(machineSet))
+}
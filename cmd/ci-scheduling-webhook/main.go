@@ -0,0 +1,1475 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bombsimon/logrusr/v3"
+	prometheusclient "github.com/prometheus/client_golang/api"
+	prometheusapi "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	pjapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	prowflagutil "k8s.io/test-infra/prow/flagutil"
+	"k8s.io/test-infra/prow/interrupts"
+	"k8s.io/test-infra/prow/logrusutil"
+	"k8s.io/test-infra/prow/pjutil"
+	"k8s.io/test-infra/prow/version"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+	schedulingconfig "github.com/openshift/ci-tools/pkg/scheduling/config"
+	"github.com/openshift/ci-tools/pkg/util"
+)
+
+const (
+	// modeWebhook serves the stateless pod and node mutation webhooks and
+	// needs no write permissions on cluster state.
+	modeWebhook = "webhook"
+	// modeNodeController runs the stateful avoidance machinery that writes
+	// taints onto nodes.
+	modeNodeController = "node-controller"
+	// modeCombined runs both in one process, the original deployment shape.
+	modeCombined = "combined"
+	// modeOperator continuously reconciles all the cluster state the webhook
+	// depends on from a declarative spec.
+	modeOperator = "operator"
+	// modeAggregator scrapes the status endpoints of every build farm's
+	// webhook and serves one consolidated capacity picture.
+	modeAggregator = "aggregator"
+	// modeConformance runs the acceptance suite against a kind/kwok cluster
+	// that has the webhook installed, then exits.
+	modeConformance = "conformance"
+)
+
+type options struct {
+	mode string
+
+	// configFile, when set, supplies the versioned configuration document;
+	// it takes precedence over the equivalent individual flags.
+	configFile string
+
+	// shrinkFactors, memoryScratchSet, and ioIntensiveJobSet are the resolved
+	// forms of the corresponding flags or configuration stanzas.
+	shrinkFactors     map[string]float64
+	memoryScratchSet  map[string]bool
+	ioIntensiveJobSet map[string]bool
+	classTolerations  map[string][]corev1.Toleration
+
+	port         int
+	certDir      string
+	unixSocket   string
+	clientCAFile string
+	adminPort    int
+
+	instrumentationOptions prowflagutil.InstrumentationOptions
+
+	loglevel string
+
+	shrinkCPURequestsBuilds float64
+	shrinkCPURequestsTests  float64
+	shrinkCPURequestSpecs   prowflagutil.Strings
+
+	annotateUnschedulablePods    bool
+	mutationStageBudget          time.Duration
+	admitProwJobs                bool
+	enableTenantPolicies         bool
+	enableClassificationPolicies bool
+
+	avoidanceMode           string
+	tolerationMode          string
+	workloadLabelKey        string
+	namespaceLabelKey       string
+	avoidanceTaintKey       string
+	avoidanceStateLabelKey  string
+	manageRuntimeClasses    bool
+	runtimeClassHandler     string
+	runtimeClassOverhead    string
+	maxPrecludedHostnames   int
+	avoidanceInterval       time.Duration
+	driftDetectionInterval  time.Duration
+	completeScaleDown       bool
+	maxNodeAge              time.Duration
+	drainAfter              time.Duration
+	escalateAfter           time.Duration
+	classDrainAfterSpecs    prowflagutil.Strings
+	classDrainAfter         map[string]time.Duration
+	maxCandidateUtilization float64
+	minIdleNodeSpecs        prowflagutil.Strings
+	minIdleNodes            map[string]int
+	capacityScheduleSpecs   prowflagutil.Strings
+	capacityScheduleEntries []scheduleEntry
+	classMachineSetSpecs    prowflagutil.Strings
+	classMachineSets        map[string]string
+	scaleUpMaxReplicas      int64
+	managePriorityExpander  bool
+	expanderNamespace       string
+
+	patchGuardCategorySpec string
+	patchGuards            []*regexp.Regexp
+
+	operatorConfig          string
+	operatorStatusNamespace string
+	operatorStatusName      string
+	operatorInterval        time.Duration
+
+	classBandwidths prowflagutil.Strings
+	classBandwidth  map[string]bandwidthLimits
+
+	classEmptyDirSizeLimits prowflagutil.Strings
+	classEmptyDirSizeLimit  map[string]resource.Quantity
+
+	classEphemeralStorageRequests prowflagutil.Strings
+	classEphemeralStorageRequest  map[string]resource.Quantity
+
+	classDefaultRequestSpecs prowflagutil.Strings
+	classDefaultRequests     map[string]defaultRequests
+
+	containerShrinkSpecs prowflagutil.Strings
+	containerShrinkRules []containerShrinkRule
+
+	classTolerationSpecs prowflagutil.Strings
+
+	memoryScratchClasses    string
+	stripCPULimitClasses    string
+	guaranteedQoSClasses    string
+	schedulingGateClasses   string
+	classConcurrencySpecs   prowflagutil.Strings
+	classConcurrencyLimits  map[string]int
+	statefulSetPodPolicy    string
+	ioIntensiveJobs         prowflagutil.Strings
+	acceleratorResources    prowflagutil.Strings
+	stepClasses             prowflagutil.Strings
+	metalSteps              prowflagutil.Strings
+	prowJobLookupNamespace  string
+	prowJobTypeClasses      prowflagutil.Strings
+	prowJobTypeClassRules   map[string]string
+	targetClasses           prowflagutil.Strings
+	spotJobTypes            string
+	presubmitTiering        bool
+	defaultClass            string
+	xlBuildPatterns         prowflagutil.Strings
+	xlBuildRegexps          []*regexp.Regexp
+	xlBuildRepos            prowflagutil.Strings
+	classPriorityClasses    prowflagutil.Strings
+	classPriorityClassMap   map[string]string
+	harmlessResources       prowflagutil.Strings
+	resourceClasses         prowflagutil.Strings
+	resourceClassRules      map[string]string
+	mutateNamespaces        prowflagutil.Strings
+	skipNamespaces          prowflagutil.Strings
+	stepClassRules          map[string]string
+	targetClassRules        map[string]string
+	heavyMemoryThreshold    string
+	largeDiskThreshold      string
+	largeDiskQuantity       *resource.Quantity
+	heavyMemoryQuantity     *resource.Quantity
+	cpuRequestIncrement     string
+	classZoneSpreadSpecs    prowflagutil.Strings
+	classZoneSpread         map[string]int32
+	packingPreferenceNodes  int
+	colocateNamespaces      bool
+	pinNamespaceZones       bool
+	excludeControlPlane     bool
+	spotNodeLabel           string
+	spotForbiddenClasses    string
+	buildSpreadClasses      string
+	maxBuildsPerNodePerRepo int
+
+	classActiveDeadlineSpecs     prowflagutil.Strings
+	classActiveDeadlineSeconds   map[string]int64
+	classTerminationGraceSpecs   prowflagutil.Strings
+	classTerminationGraceSeconds map[string]int64
+
+	classLimitPolicies        prowflagutil.Strings
+	classLimitPolicyMap       map[string]string
+	shrinkExemptNamespaces    prowflagutil.Strings
+	shrinkExemptLabelSpecs    prowflagutil.Strings
+	shrinkExemptLabels        map[string]string
+	initContainerShrinkFactor float64
+	initContainerCPUCap       string
+	initContainerCPUCapMilli  int64
+	minCPURequest             string
+	maxCPURequest             string
+	minMemoryRequest          string
+	maxMemoryRequest          string
+	requestBounds             map[corev1.ResourceName]requestBound
+	cpuRequestIncrementMilli  int64
+	imageClasses              prowflagutil.Strings
+	imageClassRules           []scheduling.ImageClassRule
+	shrinkWindowSpecs         prowflagutil.Strings
+	shrinkWindows             []shrinkWindow
+
+	usageProfileFile string
+
+	throttlePrometheusURL string
+	throttleQuery         string
+	throttleClass         string
+	throttleSLO           float64
+	throttleMinFactor     float64
+	throttleMaxFactor     float64
+	throttleStep          float64
+	throttleInterval      time.Duration
+	oomFeedback           bool
+	oomScanInterval       time.Duration
+	retryBoost            bool
+	retryBoostWindow      time.Duration
+	retryBoostStep        float64
+	retryBoostCap         float64
+
+	efficiencyHistoryFile string
+	efficiencyInterval    time.Duration
+
+	prepullNamespace  string
+	prepullTopImages  int
+	prepullPauseImage string
+	prepullInterval   time.Duration
+
+	overflowPendingThreshold int
+	overflowDrainThreshold   int
+
+	auditRecords int
+
+	conformanceCreateNodes bool
+	conformanceTimeout     time.Duration
+
+	farms               prowflagutil.Strings
+	aggregatorPort      int
+	farmTokenFile       string
+	aggregationInterval time.Duration
+}
+
+func bindOptions(fs *flag.FlagSet) *options {
+	o := options{}
+	o.instrumentationOptions.AddFlags(fs)
+	fs.StringVar(&o.mode, "mode", modeCombined, "Which mode to run in: \"webhook\" serves the stateless mutation webhooks with no node write permissions, \"node-controller\" runs the avoidance machinery, \"combined\" runs both in one process, \"operator\" reconciles the cluster state declared in --operator-config, \"aggregator\" serves a consolidated capacity picture scraped from every farm's status endpoint, \"conformance\" runs the acceptance suite against a test cluster and exits.")
+	fs.IntVar(&o.port, "port", 0, "Port to serve admission webhooks on.")
+	fs.StringVar(&o.certDir, "serving-cert-dir", "", "Path to directory with serving certificate and key for the admission webhook server.")
+	fs.IntVar(&o.adminPort, "admin-port", 0, "Port to serve admin and status endpoints on; 0 disables them. Requests are authenticated via TokenReview and authorized via SubjectAccessReview.")
+	fs.StringVar(&o.clientCAFile, "client-ca-file", "", "Require and verify client certificates on the webhook port against this CA bundle, typically the cluster's extension-apiserver CA, so only the kube-apiserver can invoke mutation endpoints.")
+	fs.StringVar(&o.unixSocket, "listen-unix-socket", "", "Serve admission webhooks over plain HTTP on this unix domain socket instead of a TLS port, for deployments that terminate TLS in a sidecar. Pass \"systemd\" to accept a socket passed through systemd socket activation.")
+	fs.StringVar(&o.loglevel, "loglevel", "debug", "Logging level.")
+	fs.Float64Var(&o.shrinkCPURequestsBuilds, "shrink-cpu-requests-builds", 1.0, "Factor by which CPU requests of build pods are multiplied at admission. Deprecated: use --shrink-cpu-request builds=factor.")
+	fs.Float64Var(&o.shrinkCPURequestsTests, "shrink-cpu-requests-tests", 1.0, "Factor by which CPU requests of test pods are multiplied at admission. Deprecated: use --shrink-cpu-request tests=factor.")
+	fs.Var(&o.shrinkCPURequestSpecs, "shrink-cpu-request", "Factor by which CPU requests of a class are multiplied at admission, in class=factor form (e.g. longtests=0.7). Can be passed multiple times and covers every class, replacing the two legacy per-class flags.")
+	fs.BoolVar(&o.manageRuntimeClasses, "manage-runtimeclasses", false, "Create and keep in sync the per-class "+runtimeClassPrefix+"<class> RuntimeClass objects (tolerations, scheduling, overhead), so new pod classes need no hand-managed cluster objects.")
+	fs.StringVar(&o.runtimeClassHandler, "runtimeclass-handler", "runc", "Container runtime handler of the managed RuntimeClasses.")
+	fs.StringVar(&o.runtimeClassOverhead, "runtimeclass-pod-overhead", "", "Pod overhead of the managed RuntimeClasses, in cpu:memory form (e.g. 10m:32Mi); empty declares no overhead.")
+	fs.StringVar(&o.workloadLabelKey, "ci-workload-label", "", "Override the ci-workload node/pod label key, for build farms that already use different labeling conventions.")
+	fs.StringVar(&o.namespaceLabelKey, "ci-workload-namespace-label", "", "Override the ci-workload-namespace label key.")
+	fs.StringVar(&o.avoidanceTaintKey, "avoidance-taint-key", "", "Override the ci-workload-avoiding taint key.")
+	fs.StringVar(&o.avoidanceStateLabelKey, "avoidance-state-label", "", "Override the ci-workload-avoidance state label key.")
+	fs.StringVar(&o.tolerationMode, "toleration-mode", tolerationModeDirect, "How pods get onto tainted pools: \"direct\" injects the class toleration straight into pod.spec, \"runtimeclass\" points pods at the per-class "+runtimeClassPrefix+"<class> RuntimeClass.")
+	fs.StringVar(&o.avoidanceMode, "avoidance-mode", avoidanceModeAffinity, "How scale-down candidates are avoided: \"affinity\" injects bounded hostname anti-affinity into pods, \"taints\" relies solely on node taints, \"label\" labels precluded nodes and injects one small DoesNotExist affinity term per pod.")
+	fs.IntVar(&o.maxPrecludedHostnames, "max-precluded-hostnames", 25, "Maximum number of hostnames injected into any one pod's scale-down avoidance affinity; further candidates are avoided only through taints.")
+	fs.DurationVar(&o.avoidanceInterval, "avoidance-interval", 30*time.Second, "Interval at which scale-down avoidance taints are reconciled.")
+	fs.BoolVar(&o.enableClassificationPolicies, "enable-classification-policies", false, "Consume PodClassificationPolicy resources declaring name/label/namespace matchers, target pod class, CPU shrink factor, and tolerations. Requires the CRD to be installed.")
+	fs.BoolVar(&o.enableTenantPolicies, "enable-tenant-policies", false, "Consume TenantSchedulingPolicy resources to grant tenants per-org/repo priorities, shrink exemptions, or dedicated pool access. Requires the CRD to be installed.")
+	fs.BoolVar(&o.admitProwJobs, "admit-prowjobs", false, "Serve a /prowjobs admission handler that stamps the scheduling-class label onto ProwJob objects, so downstream pod admission is a cheap label read instead of heuristic classification.")
+	fs.DurationVar(&o.mutationStageBudget, "mutation-stage-budget", 100*time.Millisecond, "Time budget for each non-essential pod mutation stage (preclusion lookup, capacity lookup); stages falling over budget are skipped with a fallback so the handler responds promptly. 0 disables the budget.")
+	fs.BoolVar(&o.annotateUnschedulablePods, "annotate-unschedulable-pods", true, "Inspect FailedScheduling events for classified pods and annotate them with a parsed, human-readable cause summary.")
+	fs.Var(&o.capacityScheduleSpecs, "capacity-schedule", "Adjust a class's warm buffer during a recurring UTC window, in class=minIdle@days:HH:MM-HH:MM form where days is all, weekdays, or weekends (e.g. tests=5@weekdays:06:00-18:00, tests=0@weekends:00:00-23:59). The last matching entry wins; outside every window --class-min-idle-nodes applies. Can be passed multiple times.")
+	fs.Var(&o.minIdleNodeSpecs, "class-min-idle-nodes", "Warm buffer per class, in class=N form: that many of the emptiest nodes are exempted from avoidance so they absorb load spikes without cold scale-up latency. Can be passed multiple times.")
+	fs.DurationVar(&o.maxNodeAge, "max-node-age", 0, "Recycle pool nodes older than this by treating them as avoidance candidates even when busy-capable, so long-lived nodes with leaked disk/images/kernel issues get replaced; 0 disables recycling.")
+	fs.Float64Var(&o.maxCandidateUtilization, "max-candidate-utilization", 1.0, "Nodes busier than this fraction of their pod capacity never become avoidance candidates, so busy nodes are not drained just because the pool could theoretically absorb them; 1.0 keeps candidacy purely structural.")
+	fs.Var(&o.classDrainAfterSpecs, "class-drain-after", "Per-class override of --avoidance-drain-after, in class=duration form (e.g. longtests=4h). Can be passed multiple times.")
+	fs.DurationVar(&o.drainAfter, "avoidance-drain-after", 0, "How long a node stays an avoidance candidate before its safe-to-evict pods are drained through the Eviction API; 0 disables the drain phase.")
+	fs.DurationVar(&o.escalateAfter, "avoidance-escalate-after", 0, "How long after candidacy the avoidance taint hardens to NoSchedule on drained nodes; 0 disables escalation.")
+	fs.Var(&o.classMachineSetSpecs, "class-machineset", "MachineSet backing a pod class, in class=namespace/name form, scaled up directly from the Pending queue. Can be passed multiple times; empty disables direct scale-up.")
+	fs.BoolVar(&o.managePriorityExpander, "manage-priority-expander", false, "Generate and maintain the cluster-autoscaler priority expander ConfigMap from the --class-machineset mapping, so the autoscaler prefers the correct machinesets per class without manual config drift.")
+	fs.StringVar(&o.expanderNamespace, "priority-expander-namespace", "kube-system", "Namespace of the priority expander ConfigMap.")
+	fs.Int64Var(&o.scaleUpMaxReplicas, "scale-up-max-replicas", 50, "Upper bound on replicas of any directly scaled MachineSet.")
+	fs.BoolVar(&o.completeScaleDown, "complete-scale-down", false, "Annotate the Machine of drained avoidance candidates for deletion and decrement the owning MachineSet, completing the scale-down loop instead of waiting for the cluster autoscaler.")
+	fs.DurationVar(&o.driftDetectionInterval, "drift-detection-interval", 15*time.Minute, "Interval at which node allocatable is compared against the machine type's expected values; 0 disables drift detection.")
+	fs.Var(&o.classBandwidths, "class-bandwidth", "Bandwidth annotations injected into pods of a class, in class=ingress:egress form (either side may be empty, e.g. tests=:200M). Can be passed multiple times.")
+	fs.Var(&o.shrinkWindowSpecs, "shrink-window", "A time-of-day override of a class's CPU shrink factor, in class=factor@HH:MM-HH:MM form with UTC times that may wrap midnight (e.g. tests=0.4@06:00-18:00). Can be passed multiple times; the first matching window wins.")
+	fs.StringVar(&o.statefulSetPodPolicy, "statefulset-pod-policy", statefulSetPolicyLabelOnly, "How StatefulSet-owned pods in matched namespaces are handled: \"label-only\" labels them with their class but leaves scheduling untouched (relocation breaks PV node affinity), \"ignore\" leaves them entirely alone, \"full\" treats them like any other pod.")
+	fs.Var(&o.imageClasses, "image-class", "A classification rule keyed on container image references, in pattern=class form where pattern is a regular expression matched against every image in the pod (e.g. 'registry\\.ci\\.openshift\\.org/ci/cluster-bot-.*=longtests'). Can be passed multiple times; the first matching rule wins.")
+	fs.StringVar(&o.prowJobLookupNamespace, "prowjob-lookup-namespace", "", "Namespace to look up owning ProwJob resources in for prow-created pods, refining their class from the ProwJob's stamped label or type; empty disables the lookup.")
+	fs.Var(&o.prowJobTypeClasses, "prowjob-type-class", "Map a ProwJob type (presubmit, postsubmit, periodic, batch) to a pod class, in type=class form. Only consulted when --prowjob-lookup-namespace is set. Can be passed multiple times.")
+	fs.Var(&o.metalSteps, "metal-step", "Name of a ci-operator step known to need /dev/kvm; its pods route to the "+scheduling.ClassMetal+" bare-metal pool alongside pods requesting the KVM device resource. Can be passed multiple times.")
+	fs.Var(&o.stepClasses, "step-class", "Map a ci-operator step (metadata.step label) to a pod class, in step=class form (e.g. ipi-install=longtests). Can be passed multiple times; a step rule wins over a target rule.")
+	fs.StringVar(&o.defaultClass, "default-class", "", "Catch-all pod class applied to in-scope pods no other rule classified; empty leaves them unmanaged. Pair with --mutate-namespace to bound the scope.")
+	fs.Var(&o.xlBuildPatterns, "xl-build-pattern", "A regular expression matched against openshift.io/build.name; matching builds route to the "+scheduling.ClassBuildsXL+" big-instance pool. Can be passed multiple times.")
+	fs.Var(&o.xlBuildRepos, "xl-build-repo", "An org/repo whose builds all route to the "+scheduling.ClassBuildsXL+" pool. Can be passed multiple times.")
+	fs.BoolVar(&o.presubmitTiering, "presubmit-tiering", false, "Split test-class presubmits into "+scheduling.ClassTestsHigh+" and "+scheduling.ClassTestsLow+" by the prow.k8s.io/is-optional label.")
+	fs.Var(&o.classPriorityClasses, "class-priority-class", "PriorityClass assigned to pods of a class that declare none, in class=priorityClassName form. Can be passed multiple times.")
+	fs.Var(&o.resourceClasses, "resource-class", "Route pods requesting an extended resource to a known pool, in resource=class form (e.g. 'nvidia.com/mig-=gpu' routes all MIG profiles by prefix, 'hugepages-2Mi=heavy'). Can be passed multiple times.")
+	fs.Var(&o.harmlessResources, "harmless-extended-resource", "An extended resource name that should not cause a pod to be left unmanaged (e.g. ephemeral kata resources); hugepages are always harmless. Can be passed multiple times.")
+	fs.Var(&o.mutateNamespaces, "mutate-namespace", "Restrict pod mutation to this namespace; entries ending in * match as prefixes (e.g. ci-op-*). Can be passed multiple times; empty mutates everywhere not denied.")
+	fs.Var(&o.skipNamespaces, "skip-namespace", "Never mutate pods in this namespace; entries ending in * match as prefixes. Wins over --mutate-namespace. Can be passed multiple times.")
+	fs.StringVar(&o.spotJobTypes, "spot-job-types", "", "Comma-separated prow job types (e.g. periodic) routed to the "+scheduling.ClassSpot+" spot/preemptible pool; empty disables spot routing.")
+	fs.Var(&o.targetClasses, "target-class", "Map a ci-operator target (metadata.target label) to a pod class, in target=class form. Can be passed multiple times.")
+	fs.BoolVar(&o.excludeControlPlane, "exclude-control-plane", true, "Hard-exclude control-plane and infra nodes from every managed class, so a mislabeled node or missing taint can never put CI builds on them.")
+	fs.StringVar(&o.spotNodeLabel, "spot-node-label", "machine.openshift.io/interruptible-instance", "Node label marking spot/preemptible capacity; interruption-sensitive classes are kept off labeled nodes. Empty disables the exclusion.")
+	fs.StringVar(&o.spotForbiddenClasses, "spot-forbidden-classes", scheduling.ClassLongTests+","+scheduling.ClassRelease, "Comma-separated pod classes forbidden from spot nodes.")
+	fs.BoolVar(&o.pinNamespaceZones, "pin-namespace-zones", false, "Pin all pods of a ci-op namespace to the zone chosen by its first scheduled pod, recorded via a namespace annotation the zone pinner maintains.")
+	fs.StringVar(&o.buildSpreadClasses, "build-spread-classes", scheduling.ClassBuilds, "Comma-separated classes whose pods of the same repo are kept apart per --max-builds-per-node-per-repo.")
+	fs.IntVar(&o.maxBuildsPerNodePerRepo, "max-builds-per-node-per-repo", 0, "How many concurrent builds of one repo may share a node: 1 injects required pod anti-affinity, larger values a hard topology spread; 0 disables the separation.")
+	fs.BoolVar(&o.colocateNamespaces, "colocate-namespaces", false, "Make all pods of a ci-op namespace prefer the same node and zone through pod affinity on the namespace label, reducing cross-node image pulls for multi-pod test workflows.")
+	fs.IntVar(&o.packingPreferenceNodes, "packing-preference-nodes", 0, "Bias pods toward this many of the most-packed eligible nodes through preferred affinity, improving bin packing beyond default scheduler spreading; 0 disables the bias.")
+	fs.Var(&o.classZoneSpreadSpecs, "class-zone-spread", "Inject a soft zone topologySpreadConstraint for pods of a class, in class=maxSkew form (e.g. tests=5), so the autoscaler expands evenly across zones. Can be passed multiple times.")
+	fs.Var(&o.classActiveDeadlineSpecs, "class-active-deadline", "activeDeadlineSeconds defaulted onto pods of a class that declare none, in class=duration form (e.g. tests=4h), keeping leaked pods from squatting on capacity forever. Can be passed multiple times.")
+	fs.Var(&o.classTerminationGraceSpecs, "class-termination-grace", "Cap on terminationGracePeriodSeconds for pods of a class, in class=duration form (e.g. tests=5m); values above the cap are clamped, bounding runaway teardown, while shorter declared values are kept. Can be passed multiple times.")
+	fs.Var(&o.shrinkExemptNamespaces, "shrink-exempt-namespace", "Namespace whose pods are exempt from request reduction while routing mutations still apply; entries ending in * match as prefixes. Can be passed multiple times.")
+	fs.Var(&o.shrinkExemptLabelSpecs, "shrink-exempt-label", "key=value label exempting matching pods from request reduction. Can be passed multiple times.")
+	fs.Var(&o.classLimitPolicies, "class-limit-policy", "What happens to CPU limits of shrunk containers per class, in class=keep|proportional|strip form; keep is the default. Can be passed multiple times.")
+	fs.Float64Var(&o.initContainerShrinkFactor, "init-container-shrink-factor", 0, "CPU shrink factor applied to init containers instead of the class factor; they run sequentially and briefly, so a more aggressive reduction is usually safe. 0 uses the class factor.")
+	fs.StringVar(&o.initContainerCPUCap, "init-container-cpu-cap", "", "Cap init container CPU requests at this tiny fixed quantity (e.g. 100m); empty disables the cap.")
+	fs.StringVar(&o.cpuRequestIncrement, "cpu-request-increment", "", "Round adjusted CPU requests up to multiples of this quantity (e.g. 250m) so per-node packing is deterministic; empty disables rounding.")
+	fs.StringVar(&o.minCPURequest, "min-cpu-request", "", "Minimum CPU request clamped onto containers that declare one (e.g. 10m); empty disables the bound.")
+	fs.StringVar(&o.maxCPURequest, "max-cpu-request", "", "Maximum CPU request clamped onto containers (e.g. 10); empty disables the bound.")
+	fs.StringVar(&o.minMemoryRequest, "min-memory-request", "", "Minimum memory request clamped onto containers that declare one (e.g. 64Mi); empty disables the bound.")
+	fs.StringVar(&o.maxMemoryRequest, "max-memory-request", "", "Maximum memory request clamped onto containers (e.g. 20Gi); empty disables the bound.")
+	fs.StringVar(&o.largeDiskThreshold, "large-disk-threshold", "", "Aggregate ephemeral-storage request above which pods route to the "+scheduling.ClassLargeDisk+" pool backed by larger disks (e.g. 100Gi); empty disables the class.")
+	fs.StringVar(&o.heavyMemoryThreshold, "heavy-memory-threshold", "", "Aggregate container memory request above which pods route to the "+scheduling.ClassHeavy+" large-memory pool (e.g. 24Gi); empty disables the class.")
+	fs.Var(&o.acceleratorResources, "accelerator-resource", "An extended resource name that routes requesting pods to the "+scheduling.ClassGPU+" pool; nvidia.com/gpu is always recognized. Can be passed multiple times.")
+	fs.Var(&o.ioIntensiveJobs, "io-intensive-job", "Name of a prowjob known to be IO-heavy; its pods are rerouted to the "+scheduling.ClassIOIntensive+" local-SSD pool. Can be passed multiple times.")
+	fs.Var(&o.classConcurrencySpecs, "class-concurrency-limit", "Maximum pods of a class running at once, in class=N form; the gate controller releases scheduling gates only while the class is under its limit. Can be passed multiple times.")
+	fs.StringVar(&o.schedulingGateClasses, "scheduling-gate-classes", "", "Comma-separated pod classes admitted with a Pod Scheduling Readiness gate that the gate controller releases once the class has headroom; empty disables gating. Requires a cluster supporting schedulingGates.")
+	fs.StringVar(&o.guaranteedQoSClasses, "guaranteed-qos-classes", "", "Comma-separated pod classes whose containers get limits equal to requests, putting the pods in the Guaranteed QoS class so payload-critical work is last in line for eviction.")
+	fs.StringVar(&o.stripCPULimitClasses, "strip-cpu-limit-classes", "", "Comma-separated pod classes whose containers have CPU limits removed; CFS throttling hurts test latency far more than the occasional noisy neighbor. Empty disables stripping.")
+	fs.StringVar(&o.memoryScratchClasses, "memory-scratch-classes", scheduling.ClassBuilds, "Comma-separated pod classes allowed to opt into memory-backed scratch volumes via the "+scratchVolumeAnnotation+" annotation; empty disables the conversion.")
+	fs.Var(&o.classTolerationSpecs, "class-toleration", "An additional toleration injected into pods of a class, in class=key=value:Effect form (e.g. tests=dedicated=ci:NoSchedule). Can be passed multiple times.")
+	fs.Var(&o.containerShrinkSpecs, "container-shrink", "Override the CPU shrink factor for containers whose name matches a pattern, in pattern=factor form (e.g. '^(sidecar|entrypoint)$=0.1'), so sidecars shrink aggressively while test containers keep more CPU. Can be passed multiple times; the first matching rule wins.")
+	fs.Var(&o.classDefaultRequestSpecs, "class-default-requests", "CPU and memory requests injected into containers of a class that declare no resources at all, in class=cpu:memory form (e.g. tests=100m:200Mi). Can be passed multiple times.")
+	fs.Var(&o.classEphemeralStorageRequests, "class-ephemeral-storage-request", "Default ephemeral-storage request for containers of a class that declare none, in class=quantity form (e.g. builds=20Gi). Can be passed multiple times.")
+	fs.Var(&o.classEmptyDirSizeLimits, "class-emptydir-size-limit", "Default sizeLimit for emptyDir volumes of pods of a class that declare none, in class=quantity form (e.g. tests=100Gi). Can be passed multiple times.")
+	fs.StringVar(&o.throttlePrometheusURL, "throttle-feedback-prometheus-url", "", "Prometheus base URL for CPU throttling feedback; empty disables the feedback loop.")
+	fs.StringVar(&o.throttleQuery, "throttle-query", `sum(rate(container_cpu_cfs_throttled_periods_total{namespace=~"ci-op-.*"}[10m])) / sum(rate(container_cpu_cfs_periods_total{namespace=~"ci-op-.*"}[10m]))`, "PromQL expression yielding the fraction of throttled CFS periods for the adjusted class.")
+	fs.StringVar(&o.throttleClass, "throttle-class", scheduling.ClassTests, "Pod class whose shrink factor the throttling feedback adjusts.")
+	fs.Float64Var(&o.throttleSLO, "throttle-slo", 0.05, "Tolerated fraction of throttled CFS periods before the shrink factor relaxes.")
+	fs.Float64Var(&o.throttleMinFactor, "throttle-min-factor", 0.3, "Lower bound of the throttling-adjusted shrink factor.")
+	fs.Float64Var(&o.throttleMaxFactor, "throttle-max-factor", 1.0, "Upper bound of the throttling-adjusted shrink factor.")
+	fs.Float64Var(&o.throttleStep, "throttle-step", 0.05, "Per-tick adjustment applied to the shrink factor.")
+	fs.DurationVar(&o.throttleInterval, "throttle-interval", 5*time.Minute, "Interval at which the throttling ratio is evaluated.")
+	fs.DurationVar(&o.retryBoostWindow, "retry-boost-window", time.Hour, "How long a recorded failure keeps boosting future runs of the job.")
+	fs.Float64Var(&o.retryBoostStep, "retry-boost-step", 0.25, "Additional request multiplier per recorded failure inside the window.")
+	fs.Float64Var(&o.retryBoostCap, "retry-boost-cap", 2.0, "Upper bound of the retry boost multiplier.")
+	fs.BoolVar(&o.retryBoost, "retry-boost", false, "Escalate CPU and memory requests of pods whose job failed recently (a quarter more per failure, capped at 2x); many flakes are resource starvation in disguise.")
+	fs.BoolVar(&o.oomFeedback, "oom-feedback", false, "Watch ci-op namespaces for OOM-killed containers and bump memory requests of future admissions of the same workload, breaking the retry-OOM-retry loop on large builds.")
+	fs.DurationVar(&o.oomScanInterval, "oom-scan-interval", time.Minute, "Interval at which pods are scanned for OOM-killed containers.")
+	fs.StringVar(&o.usageProfileFile, "usage-profile-file", "", "JSON file of historical P95 usage keyed by org/repo/container (as produced from Prometheus data by the pod-scaler pipeline); when present, matching containers get right-sized requests instead of factor-based shrinking. Empty disables right-sizing.")
+	fs.StringVar(&o.efficiencyHistoryFile, "efficiency-history-file", "", "File persisting packing-efficiency samples across restarts for week-over-week regression analysis; empty keeps history in memory only.")
+	fs.DurationVar(&o.efficiencyInterval, "efficiency-interval", time.Hour, "Interval at which packing efficiency is sampled and analyzed; 0 disables the analyzer.")
+	fs.StringVar(&o.prepullNamespace, "prepull-namespace", "", "Namespace to maintain per-class image pre-pull DaemonSets in; empty disables pre-pulling.")
+	fs.IntVar(&o.prepullTopImages, "prepull-top-images", 5, "How many of the most admitted images per class to pre-pull onto pool nodes.")
+	fs.StringVar(&o.prepullPauseImage, "prepull-pause-image", "registry.k8s.io/pause:3.9", "Image keeping pre-pull pods alive so pulled images stay referenced.")
+	fs.DurationVar(&o.prepullInterval, "prepull-interval", 24*time.Hour, "Interval at which the pre-pulled image list is refreshed.")
+	fs.IntVar(&o.overflowPendingThreshold, "overflow-pending-threshold", 0, "Pending test pods at which newly admitted test pods route to the overflow pool; 0 disables overflow routing.")
+	fs.IntVar(&o.overflowDrainThreshold, "overflow-drain-threshold", 0, "Pending test pods below which overflow routing deactivates again; defaults to half the activation threshold.")
+	fs.IntVar(&o.auditRecords, "audit-records", 1000, "How many recent mutation records to retain for the /explain admin endpoint.")
+	fs.BoolVar(&o.conformanceCreateNodes, "conformance-create-nodes", true, "Provision kwok-managed fake nodes per class in conformance mode; disable on kind clusters with pre-labeled real nodes.")
+	fs.DurationVar(&o.conformanceTimeout, "conformance-timeout", 2*time.Minute, "How long each conformance assertion may wait for the cluster to converge.")
+	fs.Var(&o.farms, "farm", "A build farm webhook to aggregate, in name=url form where url points at the farm's admin endpoints. Can be passed multiple times.")
+	fs.IntVar(&o.aggregatorPort, "aggregator-port", 0, "Port to serve the consolidated cross-farm status on in aggregator mode.")
+	fs.StringVar(&o.farmTokenFile, "farm-token-file", "", "File holding the bearer token used to authenticate against farm admin endpoints.")
+	fs.DurationVar(&o.aggregationInterval, "aggregation-interval", time.Minute, "Interval at which farm status endpoints are scraped in aggregator mode.")
+	fs.StringVar(&o.operatorConfig, "operator-config", "", "Path to the declarative spec of cluster state reconciled in operator mode.")
+	fs.StringVar(&o.operatorStatusNamespace, "operator-status-namespace", "ci", "Namespace of the ConfigMap operator mode writes status conditions to.")
+	fs.StringVar(&o.operatorStatusName, "operator-status-configmap", "", "Name of the ConfigMap operator mode writes status conditions to; empty disables status reporting.")
+	fs.DurationVar(&o.operatorInterval, "operator-interval", time.Minute, "Interval at which operator mode reconciles owned cluster state.")
+	fs.StringVar(&o.configFile, "config", "", "Path to a versioned configuration document (scheduling.ci.openshift.io/v1 or v1alpha1); takes precedence over the equivalent individual flags.")
+	fs.StringVar(&o.patchGuardCategorySpec, "patch-guard-categories", "resources", "Comma-separated patch categories to protect with JSON-Patch test operations against concurrent webhook mutations; one or more of \"resources\", \"metadata\", \"scheduling\", or \"none\".")
+	return &o
+}
+
+func (o *options) servesWebhooks() bool {
+	return o.mode == modeWebhook || o.mode == modeCombined
+}
+
+func (o *options) runsNodeController() bool {
+	return o.mode == modeNodeController || o.mode == modeCombined
+}
+
+func (o *options) validate() error {
+	scheduling.ConfigureWellKnownNames(o.workloadLabelKey, o.namespaceLabelKey, o.avoidanceTaintKey, o.avoidanceStateLabelKey)
+	switch o.mode {
+	case modeWebhook, modeNodeController, modeCombined:
+	case modeOperator:
+		if o.operatorConfig == "" {
+			return errors.New("--operator-config is required in operator mode")
+		}
+	case modeAggregator:
+		if len(o.farms.Strings()) == 0 {
+			return errors.New("at least one --farm is required in aggregator mode")
+		}
+		if o.aggregatorPort == 0 {
+			return errors.New("--aggregator-port is required in aggregator mode")
+		}
+	case modeConformance:
+	default:
+		return fmt.Errorf("--mode must be one of %q, %q, %q, %q, %q, or %q", modeWebhook, modeNodeController, modeCombined, modeOperator, modeAggregator, modeConformance)
+	}
+	if o.servesWebhooks() {
+		if o.unixSocket == "" {
+			if o.port == 0 {
+				return errors.New("--port is required")
+			}
+			if o.certDir == "" {
+				return errors.New("--serving-cert-dir is required")
+			}
+		} else if o.port != 0 || o.certDir != "" {
+			return errors.New("--listen-unix-socket cannot be combined with --port or --serving-cert-dir")
+		}
+	}
+	if o.clientCAFile != "" && o.unixSocket != "" {
+		return errors.New("--client-ca-file cannot be combined with --listen-unix-socket, TLS is terminated outside of this process")
+	}
+	for _, factor := range []struct {
+		name  string
+		value float64
+	}{
+		{name: "--shrink-cpu-requests-builds", value: o.shrinkCPURequestsBuilds},
+		{name: "--shrink-cpu-requests-tests", value: o.shrinkCPURequestsTests},
+	} {
+		if factor.value <= 0 || factor.value > 1.0 {
+			return fmt.Errorf("%s must be in (0.0,1.0], not %f", factor.name, factor.value)
+		}
+	}
+	if o.tolerationMode != tolerationModeDirect && o.tolerationMode != tolerationModeRuntimeClass {
+		return fmt.Errorf("--toleration-mode must be either %q or %q, not %q", tolerationModeDirect, tolerationModeRuntimeClass, o.tolerationMode)
+	}
+	switch o.avoidanceMode {
+	case avoidanceModeAffinity, avoidanceModeTaints, avoidanceModeLabel, avoidanceModeTiered:
+	default:
+		return fmt.Errorf("--avoidance-mode must be one of %q, %q, %q, or %q, not %q", avoidanceModeAffinity, avoidanceModeTaints, avoidanceModeLabel, avoidanceModeTiered, o.avoidanceMode)
+	}
+	if o.maxPrecludedHostnames < 0 {
+		return errors.New("--max-precluded-hostnames must not be negative")
+	}
+	o.classBandwidth = map[string]bandwidthLimits{}
+	for _, spec := range o.classBandwidths.Strings() {
+		class, values, valid := strings.Cut(spec, "=")
+		if !valid {
+			return fmt.Errorf("--class-bandwidth must be in class=ingress:egress form, not %q", spec)
+		}
+		ingress, egress, valid := strings.Cut(values, ":")
+		if !valid {
+			return fmt.Errorf("--class-bandwidth must be in class=ingress:egress form, not %q", spec)
+		}
+		for _, value := range []string{ingress, egress} {
+			if value == "" {
+				continue
+			}
+			if _, err := resource.ParseQuantity(value); err != nil {
+				return fmt.Errorf("--class-bandwidth %q is not a valid quantity: %w", value, err)
+			}
+		}
+		o.classBandwidth[class] = bandwidthLimits{ingress: ingress, egress: egress}
+	}
+	switch o.statefulSetPodPolicy {
+	case statefulSetPolicyLabelOnly, statefulSetPolicyIgnore, statefulSetPolicyFull:
+	default:
+		return fmt.Errorf("--statefulset-pod-policy must be one of %q, %q, or %q", statefulSetPolicyLabelOnly, statefulSetPolicyIgnore, statefulSetPolicyFull)
+	}
+	var err error
+	if o.heavyMemoryThreshold != "" {
+		threshold, err := resource.ParseQuantity(o.heavyMemoryThreshold)
+		if err != nil {
+			return fmt.Errorf("--heavy-memory-threshold %q is not a valid quantity: %w", o.heavyMemoryThreshold, err)
+		}
+		o.heavyMemoryQuantity = &threshold
+	}
+	if o.largeDiskThreshold != "" {
+		threshold, err := resource.ParseQuantity(o.largeDiskThreshold)
+		if err != nil {
+			return fmt.Errorf("--large-disk-threshold %q is not a valid quantity: %w", o.largeDiskThreshold, err)
+		}
+		o.largeDiskQuantity = &threshold
+	}
+	if o.imageClassRules, err = scheduling.ParseImageClassRules(o.imageClasses.Strings()); err != nil {
+		return err
+	}
+	if o.stepClassRules, err = scheduling.ParseClassMap(o.stepClasses.Strings()); err != nil {
+		return fmt.Errorf("--step-class: %w", err)
+	}
+	if o.prowJobTypeClassRules, err = scheduling.ParseClassMap(o.prowJobTypeClasses.Strings()); err != nil {
+		return fmt.Errorf("--prowjob-type-class: %w", err)
+	}
+	if o.resourceClassRules, err = scheduling.ParseClassMap(o.resourceClasses.Strings()); err != nil {
+		return fmt.Errorf("--resource-class: %w", err)
+	}
+	o.classMachineSets = map[string]string{}
+	for _, spec := range o.classMachineSetSpecs.Strings() {
+		class, reference, valid := strings.Cut(spec, "=")
+		if !valid || !strings.Contains(reference, "/") || !scheduling.SetOf(scheduling.PodClasses)[class] {
+			return fmt.Errorf("--class-machineset must be in class=namespace/name form with a known class, not %q", spec)
+		}
+		o.classMachineSets[class] = reference
+	}
+	o.classConcurrencyLimits = map[string]int{}
+	for _, spec := range o.classConcurrencySpecs.Strings() {
+		class, value, valid := strings.Cut(spec, "=")
+		limit, parseErr := strconv.Atoi(value)
+		if !valid || parseErr != nil || limit < 1 || !scheduling.SetOf(scheduling.PodClasses)[class] {
+			return fmt.Errorf("--class-concurrency-limit must be in class=N form with a known class and positive limit, not %q", spec)
+		}
+		o.classConcurrencyLimits[class] = limit
+	}
+	o.classZoneSpread = map[string]int32{}
+	for _, spec := range o.classZoneSpreadSpecs.Strings() {
+		class, value, valid := strings.Cut(spec, "=")
+		skew, parseErr := strconv.Atoi(value)
+		if !valid || parseErr != nil || skew < 1 || !scheduling.SetOf(scheduling.PodClasses)[class] {
+			return fmt.Errorf("--class-zone-spread must be in class=maxSkew form with a known class and positive skew, not %q", spec)
+		}
+		o.classZoneSpread[class] = int32(skew)
+	}
+	parseClassDurations := func(flag string, specs []string) (map[string]int64, error) {
+		durations := map[string]int64{}
+		for _, spec := range specs {
+			class, value, valid := strings.Cut(spec, "=")
+			if !valid || !scheduling.SetOf(scheduling.PodClasses)[class] {
+				return nil, fmt.Errorf("%s must be in class=duration form with a known class, not %q", flag, spec)
+			}
+			duration, err := time.ParseDuration(value)
+			if err != nil || duration <= 0 {
+				return nil, fmt.Errorf("%s %q is not a positive duration", flag, value)
+			}
+			durations[class] = int64(duration.Seconds())
+		}
+		return durations, nil
+	}
+	if o.classActiveDeadlineSeconds, err = parseClassDurations("--class-active-deadline", o.classActiveDeadlineSpecs.Strings()); err != nil {
+		return err
+	}
+	o.classDrainAfter = map[string]time.Duration{}
+	for _, spec := range o.classDrainAfterSpecs.Strings() {
+		class, value, valid := strings.Cut(spec, "=")
+		duration, parseErr := time.ParseDuration(value)
+		if !valid || parseErr != nil || duration < 0 || !scheduling.SetOf(scheduling.PodClasses)[class] {
+			return fmt.Errorf("--class-drain-after must be in class=duration form with a known class, not %q", spec)
+		}
+		o.classDrainAfter[class] = duration
+	}
+	o.minIdleNodes = map[string]int{}
+	for _, spec := range o.minIdleNodeSpecs.Strings() {
+		class, value, valid := strings.Cut(spec, "=")
+		floor, parseErr := strconv.Atoi(value)
+		if !valid || parseErr != nil || floor < 1 || !scheduling.SetOf(scheduling.PodClasses)[class] {
+			return fmt.Errorf("--class-min-idle-nodes must be in class=N form with a known class and positive floor, not %q", spec)
+		}
+		o.minIdleNodes[class] = floor
+	}
+	if o.capacityScheduleEntries, err = parseCapacitySchedules(o.capacityScheduleSpecs.Strings(), scheduling.SetOf(scheduling.PodClasses)); err != nil {
+		return fmt.Errorf("--capacity-schedule: %w", err)
+	}
+	if o.maxCandidateUtilization <= 0 || o.maxCandidateUtilization > 1.0 {
+		return fmt.Errorf("--max-candidate-utilization must be in (0.0,1.0], not %f", o.maxCandidateUtilization)
+	}
+	if o.classTerminationGraceSeconds, err = parseClassDurations("--class-termination-grace", o.classTerminationGraceSpecs.Strings()); err != nil {
+		return err
+	}
+	o.shrinkExemptLabels = map[string]string{}
+	for _, spec := range o.shrinkExemptLabelSpecs.Strings() {
+		key, value, valid := strings.Cut(spec, "=")
+		if !valid || key == "" {
+			return fmt.Errorf("--shrink-exempt-label must be in key=value form, not %q", spec)
+		}
+		o.shrinkExemptLabels[key] = value
+	}
+	o.classLimitPolicyMap = map[string]string{}
+	for _, spec := range o.classLimitPolicies.Strings() {
+		class, policy, valid := strings.Cut(spec, "=")
+		if !valid || !scheduling.SetOf(scheduling.PodClasses)[class] {
+			return fmt.Errorf("--class-limit-policy must be in class=policy form with a known class, not %q", spec)
+		}
+		switch policy {
+		case limitPolicyKeep, limitPolicyProportional, limitPolicyStrip:
+		default:
+			return fmt.Errorf("--class-limit-policy policy must be one of %q, %q, or %q, not %q", limitPolicyKeep, limitPolicyProportional, limitPolicyStrip, policy)
+		}
+		o.classLimitPolicyMap[class] = policy
+	}
+	if o.initContainerShrinkFactor != 0 && (o.initContainerShrinkFactor <= 0 || o.initContainerShrinkFactor > 1.0) {
+		return fmt.Errorf("--init-container-shrink-factor must be in (0.0,1.0], not %f", o.initContainerShrinkFactor)
+	}
+	if o.initContainerCPUCap != "" {
+		cap, err := resource.ParseQuantity(o.initContainerCPUCap)
+		if err != nil || cap.MilliValue() <= 0 {
+			return fmt.Errorf("--init-container-cpu-cap %q is not a positive quantity", o.initContainerCPUCap)
+		}
+		o.initContainerCPUCapMilli = cap.MilliValue()
+	}
+	if o.cpuRequestIncrement != "" {
+		increment, err := resource.ParseQuantity(o.cpuRequestIncrement)
+		if err != nil || increment.MilliValue() <= 0 {
+			return fmt.Errorf("--cpu-request-increment %q is not a positive quantity", o.cpuRequestIncrement)
+		}
+		o.cpuRequestIncrementMilli = increment.MilliValue()
+	}
+	o.requestBounds = map[corev1.ResourceName]requestBound{}
+	for _, bound := range []struct {
+		name     corev1.ResourceName
+		min, max string
+	}{
+		{name: corev1.ResourceCPU, min: o.minCPURequest, max: o.maxCPURequest},
+		{name: corev1.ResourceMemory, min: o.minMemoryRequest, max: o.maxMemoryRequest},
+	} {
+		entry := requestBound{}
+		for _, side := range []struct {
+			raw    string
+			target **resource.Quantity
+		}{{bound.min, &entry.min}, {bound.max, &entry.max}} {
+			if side.raw == "" {
+				continue
+			}
+			quantity, err := resource.ParseQuantity(side.raw)
+			if err != nil {
+				return fmt.Errorf("request bound %q is not a valid quantity: %w", side.raw, err)
+			}
+			*side.target = &quantity
+		}
+		if entry.min != nil && entry.max != nil && entry.min.Cmp(*entry.max) > 0 {
+			return fmt.Errorf("minimum %s request %s exceeds the maximum %s", bound.name, entry.min.String(), entry.max.String())
+		}
+		if entry.min != nil || entry.max != nil {
+			o.requestBounds[bound.name] = entry
+		}
+	}
+	if o.defaultClass != "" && !scheduling.SetOf(scheduling.PodClasses)[o.defaultClass] {
+		return fmt.Errorf("--default-class names unknown class %q", o.defaultClass)
+	}
+	for _, pattern := range o.xlBuildPatterns.Strings() {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("--xl-build-pattern %q does not compile: %w", pattern, err)
+		}
+		o.xlBuildRegexps = append(o.xlBuildRegexps, compiled)
+	}
+	o.classPriorityClassMap = map[string]string{}
+	classes := scheduling.SetOf(scheduling.PodClasses)
+	for _, spec := range o.classPriorityClasses.Strings() {
+		class, priorityClass, valid := strings.Cut(spec, "=")
+		if !valid || priorityClass == "" || !classes[class] {
+			return fmt.Errorf("--class-priority-class must be in class=priorityClassName form with a known class, not %q", spec)
+		}
+		o.classPriorityClassMap[class] = priorityClass
+	}
+	if o.targetClassRules, err = scheduling.ParseClassMap(o.targetClasses.Strings()); err != nil {
+		return fmt.Errorf("--target-class: %w", err)
+	}
+	if o.shrinkWindows, err = parseShrinkWindows(o.shrinkWindowSpecs.Strings()); err != nil {
+		return err
+	}
+	for _, spec := range o.containerShrinkSpecs.Strings() {
+		index := strings.LastIndex(spec, "=")
+		if index < 1 {
+			return fmt.Errorf("--container-shrink must be in pattern=factor form, not %q", spec)
+		}
+		pattern, err := regexp.Compile(spec[:index])
+		if err != nil {
+			return fmt.Errorf("--container-shrink pattern %q does not compile: %w", spec[:index], err)
+		}
+		factor, err := strconv.ParseFloat(spec[index+1:], 64)
+		if err != nil || factor <= 0 || factor > 1.0 {
+			return fmt.Errorf("--container-shrink factor must be in (0.0,1.0], not %q", spec[index+1:])
+		}
+		o.containerShrinkRules = append(o.containerShrinkRules, containerShrinkRule{pattern: pattern, factor: factor})
+	}
+	o.classDefaultRequests = map[string]defaultRequests{}
+	for _, spec := range o.classDefaultRequestSpecs.Strings() {
+		malformed := fmt.Errorf("--class-default-requests must be in class=cpu:memory form, not %q", spec)
+		class, values, valid := strings.Cut(spec, "=")
+		if !valid {
+			return malformed
+		}
+		rawCPU, rawMemory, valid := strings.Cut(values, ":")
+		if !valid {
+			return malformed
+		}
+		cpu, err := resource.ParseQuantity(rawCPU)
+		if err != nil {
+			return fmt.Errorf("--class-default-requests cpu %q is not a valid quantity: %w", rawCPU, err)
+		}
+		memory, err := resource.ParseQuantity(rawMemory)
+		if err != nil {
+			return fmt.Errorf("--class-default-requests memory %q is not a valid quantity: %w", rawMemory, err)
+		}
+		o.classDefaultRequests[class] = defaultRequests{cpu: cpu, memory: memory}
+	}
+	o.classEphemeralStorageRequest = map[string]resource.Quantity{}
+	for _, spec := range o.classEphemeralStorageRequests.Strings() {
+		class, value, valid := strings.Cut(spec, "=")
+		if !valid {
+			return fmt.Errorf("--class-ephemeral-storage-request must be in class=quantity form, not %q", spec)
+		}
+		request, err := resource.ParseQuantity(value)
+		if err != nil {
+			return fmt.Errorf("--class-ephemeral-storage-request %q is not a valid quantity: %w", value, err)
+		}
+		o.classEphemeralStorageRequest[class] = request
+	}
+	o.classEmptyDirSizeLimit = map[string]resource.Quantity{}
+	for _, spec := range o.classEmptyDirSizeLimits.Strings() {
+		class, value, valid := strings.Cut(spec, "=")
+		if !valid {
+			return fmt.Errorf("--class-emptydir-size-limit must be in class=quantity form, not %q", spec)
+		}
+		limit, err := resource.ParseQuantity(value)
+		if err != nil {
+			return fmt.Errorf("--class-emptydir-size-limit %q is not a valid quantity: %w", value, err)
+		}
+		o.classEmptyDirSizeLimit[class] = limit
+	}
+	if o.patchGuardCategorySpec != "none" {
+		for _, category := range strings.Split(o.patchGuardCategorySpec, ",") {
+			guard, known := scheduling.PatchGuardCategories[category]
+			if !known {
+				return fmt.Errorf("--patch-guard-categories contains unknown category %q", category)
+			}
+			o.patchGuards = append(o.patchGuards, guard)
+		}
+	}
+	o.shrinkFactors = map[string]float64{
+		scheduling.ClassBuilds: o.shrinkCPURequestsBuilds,
+		scheduling.ClassTests:  o.shrinkCPURequestsTests,
+	}
+	knownClasses := scheduling.SetOf(scheduling.PodClasses)
+	for _, spec := range o.shrinkCPURequestSpecs.Strings() {
+		class, rawFactor, valid := strings.Cut(spec, "=")
+		if !valid || !knownClasses[class] {
+			return fmt.Errorf("--shrink-cpu-request must be in class=factor form with a known class, not %q", spec)
+		}
+		factor, err := strconv.ParseFloat(rawFactor, 64)
+		if err != nil || factor <= 0 || factor > 1.0 {
+			return fmt.Errorf("--shrink-cpu-request factor must be in (0.0,1.0], not %q", rawFactor)
+		}
+		o.shrinkFactors[class] = factor
+	}
+	o.memoryScratchSet = commaSet(o.memoryScratchClasses)
+	o.ioIntensiveJobSet = setOf(o.ioIntensiveJobs.Strings())
+	o.classTolerations = map[string][]corev1.Toleration{}
+	for _, spec := range o.classTolerationSpecs.Strings() {
+		malformed := fmt.Errorf("--class-toleration must be in class=key=value:Effect form, not %q", spec)
+		class, taint, valid := strings.Cut(spec, "=")
+		if !valid {
+			return malformed
+		}
+		key, assignment, valid := strings.Cut(taint, "=")
+		if !valid || key == "" {
+			return malformed
+		}
+		value, effect, valid := strings.Cut(assignment, ":")
+		if !valid || effect == "" {
+			return malformed
+		}
+		o.classTolerations[class] = append(o.classTolerations[class], corev1.Toleration{
+			Key:      key,
+			Operator: corev1.TolerationOpEqual,
+			Value:    value,
+			Effect:   corev1.TaintEffect(effect),
+		})
+	}
+	if o.configFile != "" {
+		if err := o.applyConfiguration(); err != nil {
+			return err
+		}
+	}
+	if level, err := logrus.ParseLevel(o.loglevel); err != nil {
+		return fmt.Errorf("--loglevel invalid: %w", err)
+	} else {
+		logrus.SetLevel(level)
+	}
+	return o.instrumentationOptions.Validate(false)
+}
+
+// applyConfiguration resolves the versioned configuration document into the
+// runtime knobs, overriding whatever the individual flags said.
+func (o *options) applyConfiguration() error {
+	configuration, err := schedulingconfig.Load(o.configFile)
+	if err != nil {
+		return fmt.Errorf("--config: %w", err)
+	}
+	o.avoidanceMode = configuration.Avoidance.Mode
+	o.maxPrecludedHostnames = *configuration.Avoidance.MaxPrecludedHostnames
+	o.statefulSetPodPolicy = configuration.StatefulSetPodPolicy
+	o.shrinkFactors = map[string]float64{}
+	o.shrinkWindows = nil
+	o.classTolerations = map[string][]corev1.Toleration{}
+	o.classBandwidth = map[string]bandwidthLimits{}
+	o.classEmptyDirSizeLimit = map[string]resource.Quantity{}
+	o.memoryScratchSet = map[string]bool{}
+	var windowSpecs []string
+	for name, class := range configuration.Classes {
+		if class.ShrinkCPUFactor != nil {
+			o.shrinkFactors[name] = *class.ShrinkCPUFactor
+		}
+		for _, window := range class.ShrinkWindows {
+			windowSpecs = append(windowSpecs, fmt.Sprintf("%s=%g@%s-%s", name, window.Factor, window.Start, window.End))
+		}
+		if class.Bandwidth != nil {
+			o.classBandwidth[name] = bandwidthLimits{ingress: class.Bandwidth.Ingress, egress: class.Bandwidth.Egress}
+		}
+		if class.EmptyDirSizeLimit != "" {
+			// Validation already proved the quantity parses.
+			o.classEmptyDirSizeLimit[name] = resource.MustParse(class.EmptyDirSizeLimit)
+		}
+		if class.MemoryScratch {
+			o.memoryScratchSet[name] = true
+		}
+		if len(class.ExtraTolerations) > 0 {
+			o.classTolerations[name] = class.ExtraTolerations
+		}
+	}
+	if o.shrinkWindows, err = parseShrinkWindows(windowSpecs); err != nil {
+		return fmt.Errorf("--config: %w", err)
+	}
+	if o.imageClassRules, err = scheduling.ParseImageClassRules(configuration.Classification.ImageRules); err != nil {
+		return fmt.Errorf("--config: %w", err)
+	}
+	o.ioIntensiveJobSet = setOf(configuration.Classification.IOIntensiveJobs)
+	o.patchGuards = nil
+	for _, category := range configuration.PatchGuardCategories {
+		if category == "none" {
+			continue
+		}
+		o.patchGuards = append(o.patchGuards, scheduling.PatchGuardCategories[category])
+	}
+	return nil
+}
+
+func main() {
+	logrusutil.ComponentInit()
+	flagSet := flag.NewFlagSet("", flag.ExitOnError)
+	opts := bindOptions(flagSet)
+	if err := flagSet.Parse(os.Args[1:]); err != nil {
+		logrus.WithError(err).Fatal("failed to parse flags")
+	}
+	if err := opts.validate(); err != nil {
+		logrus.WithError(err).Fatal("Failed to validate flags")
+	}
+	logrus.Infof("%s version %s", version.Name, version.Version)
+	controllerruntime.SetLogger(logrusr.New(logrus.StandardLogger()))
+
+	if opts.mode == modeAggregator {
+		farms, err := parseFarms(opts.farms.Strings())
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to parse farms.")
+		}
+		aggregator := &aggregator{
+			logger:    logrus.WithField("component", "aggregator"),
+			client:    &http.Client{Timeout: 30 * time.Second},
+			farms:     farms,
+			tokenFile: opts.farmTokenFile,
+			interval:  opts.aggregationInterval,
+			statuses:  map[string]farmStatus{},
+		}
+		interrupts.Run(aggregator.run)
+		aggregator.serve(opts.aggregatorPort)
+		interrupts.WaitForGracefulShutdown()
+		return
+	}
+
+	if err := pjapi.AddToScheme(scheme.Scheme); err != nil {
+		logrus.WithError(err).Fatal("Failed to add ProwJob types to scheme.")
+	}
+	restConfig, err := util.LoadClusterConfig()
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load cluster config.")
+	}
+	if opts.mode == modeConformance {
+		client, err := ctrlruntimeclient.New(restConfig, ctrlruntimeclient.Options{})
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to construct client.")
+		}
+		runner := &conformanceRunner{
+			logger:      logrus.WithField("component", "conformance"),
+			client:      client,
+			createNodes: opts.conformanceCreateNodes,
+			timeout:     opts.conformanceTimeout,
+		}
+		if err := runner.run(interrupts.Context()); err != nil {
+			logrus.WithError(err).Fatal("Conformance suite failed.")
+		}
+		return
+	}
+	mgr, err := controllerruntime.NewManager(restConfig, controllerruntime.Options{
+		MetricsBindAddress: "0",
+	})
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to construct manager.")
+	}
+
+	prioritization := &scheduling.Prioritization{
+		Client:                  mgr.GetClient(),
+		MaxPrecludedHostnames:   opts.maxPrecludedHostnames,
+		MaxCandidateUtilization: opts.maxCandidateUtilization,
+		MinIdleNodes:            opts.minIdleNodes,
+	}
+	if len(opts.capacityScheduleEntries) > 0 {
+		schedule := &capacitySchedule{entries: opts.capacityScheduleEntries, base: opts.minIdleNodes}
+		prioritization.MinIdleNodesFn = schedule.minIdleFor
+	}
+	health := pjutil.NewHealthOnPort(opts.instrumentationOptions.HealthPort)
+	if opts.mode == modeOperator {
+		spec, err := loadOperatorSpec(opts.operatorConfig)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to load operator spec.")
+		}
+		operator := &operatorReconciler{
+			logger:          logrus.WithField("component", "operator"),
+			client:          mgr.GetClient(),
+			spec:            spec,
+			interval:        opts.operatorInterval,
+			statusNamespace: opts.operatorStatusNamespace,
+			statusName:      opts.operatorStatusName,
+		}
+		interrupts.Run(func(ctx context.Context) {
+			if !mgr.GetCache().WaitForCacheSync(ctx) {
+				logrus.Fatal("Failed to sync informer cache.")
+			}
+			health.ServeReady()
+			operator.run(ctx)
+		})
+		if err := mgr.Start(interrupts.Context()); err != nil {
+			logrus.WithError(err).Fatal("Manager ended with error.")
+		}
+		return
+	}
+	if opts.runsNodeController() {
+		reconciler := &avoidanceReconciler{
+			logger:          logrus.WithField("component", "avoidance-reconciler"),
+			client:          mgr.GetClient(),
+			prioritization:  prioritization,
+			interval:        opts.avoidanceInterval,
+			labelCandidates: opts.avoidanceMode == avoidanceModeLabel,
+			maxNodeAge:      opts.maxNodeAge,
+			drainAfter:      opts.drainAfter,
+			escalateAfter:   opts.escalateAfter,
+			classDrainAfter: opts.classDrainAfter,
+		}
+		var gates *gateReconciler
+		if opts.schedulingGateClasses != "" {
+			gates = &gateReconciler{
+				logger:            logrus.WithField("component", "gate-controller"),
+				client:            mgr.GetClient(),
+				prioritization:    prioritization,
+				interval:          opts.avoidanceInterval,
+				concurrencyLimits: opts.classConcurrencyLimits,
+			}
+		}
+		var annotator *unschedulableAnnotator
+		if opts.annotateUnschedulablePods {
+			annotator = &unschedulableAnnotator{
+				logger:   logrus.WithField("component", "unschedulable-annotator"),
+				client:   mgr.GetClient(),
+				interval: opts.avoidanceInterval,
+			}
+		}
+		var scaler *scaleUp
+		if len(opts.classMachineSets) > 0 {
+			scaler = &scaleUp{
+				logger:         logrus.WithField("component", "scale-up"),
+				client:         mgr.GetClient(),
+				prioritization: prioritization,
+				interval:       opts.avoidanceInterval,
+				machineSets:    opts.classMachineSets,
+				maxReplicas:    opts.scaleUpMaxReplicas,
+			}
+		}
+		var expander *expanderReconciler
+		if opts.managePriorityExpander {
+			expander = &expanderReconciler{
+				logger:      logrus.WithField("component", "priority-expander"),
+				client:      mgr.GetClient(),
+				namespace:   opts.expanderNamespace,
+				machineSets: opts.classMachineSets,
+				interval:    opts.operatorInterval,
+			}
+		}
+		var machines *machineScaleDown
+		if opts.completeScaleDown {
+			machines = &machineScaleDown{
+				logger:         logrus.WithField("component", "machine-scale-down"),
+				client:         mgr.GetClient(),
+				prioritization: prioritization,
+				interval:       opts.avoidanceInterval,
+			}
+		}
+		classTaints := &classTaintReconciler{
+			logger:         logrus.WithField("component", "class-taint-reconciler"),
+			client:         mgr.GetClient(),
+			prioritization: prioritization,
+			interval:       opts.avoidanceInterval,
+		}
+		var zones *zonePinner
+		if opts.pinNamespaceZones {
+			zones = &zonePinner{
+				logger:   logrus.WithField("component", "zone-pinner"),
+				client:   mgr.GetClient(),
+				interval: opts.avoidanceInterval,
+			}
+		}
+		var runtimeClasses *runtimeClassReconciler
+		if opts.manageRuntimeClasses {
+			overhead := corev1.ResourceList{}
+			if opts.runtimeClassOverhead != "" {
+				rawCPU, rawMemory, valid := strings.Cut(opts.runtimeClassOverhead, ":")
+				if !valid {
+					logrus.Fatal("--runtimeclass-pod-overhead must be in cpu:memory form")
+				}
+				overhead[corev1.ResourceCPU] = resource.MustParse(rawCPU)
+				overhead[corev1.ResourceMemory] = resource.MustParse(rawMemory)
+			}
+			runtimeClasses = &runtimeClassReconciler{
+				logger:   logrus.WithField("component", "runtimeclass-reconciler"),
+				client:   mgr.GetClient(),
+				handler:  opts.runtimeClassHandler,
+				overhead: overhead,
+				interval: opts.operatorInterval,
+			}
+		}
+		var drift *driftDetector
+		if opts.driftDetectionInterval > 0 {
+			drift = &driftDetector{
+				logger:         logrus.WithField("component", "drift-detector"),
+				client:         mgr.GetClient(),
+				prioritization: prioritization,
+				interval:       opts.driftDetectionInterval,
+			}
+		}
+		interrupts.Run(func(ctx context.Context) {
+			if !mgr.GetCache().WaitForCacheSync(ctx) {
+				logrus.Fatal("Failed to sync informer cache.")
+			}
+			health.ServeReady()
+			if gates != nil {
+				go gates.run(ctx)
+			}
+			if drift != nil {
+				go drift.run(ctx)
+			}
+			if runtimeClasses != nil {
+				go runtimeClasses.run(ctx)
+			}
+			if zones != nil {
+				go zones.run(ctx)
+			}
+			go classTaints.run(ctx)
+			if machines != nil {
+				go machines.run(ctx)
+			}
+			if scaler != nil {
+				go scaler.run(ctx)
+			}
+			if expander != nil {
+				go expander.run(ctx)
+			}
+			if annotator != nil {
+				go annotator.run(ctx)
+			}
+			reconciler.run(ctx)
+		})
+	} else {
+		interrupts.Run(func(ctx context.Context) {
+			if !mgr.GetCache().WaitForCacheSync(ctx) {
+				logrus.Fatal("Failed to sync informer cache.")
+			}
+			health.ServeReady()
+		})
+	}
+
+	saved := &savings{}
+	audit := newAuditLog(opts.auditRecords)
+	var mutator *podMutator
+	var retries *retryTracker
+	if opts.retryBoost {
+		retries = newRetryTracker(opts.retryBoostWindow, opts.retryBoostStep, opts.retryBoostCap)
+		watcher := &retryWatcher{
+			logger:   logrus.WithField("component", "retry-watcher"),
+			client:   mgr.GetClient(),
+			tracker:  retries,
+			interval: opts.oomScanInterval,
+		}
+		interrupts.Run(func(ctx context.Context) {
+			if !mgr.GetCache().WaitForCacheSync(ctx) {
+				return
+			}
+			watcher.run(ctx)
+		})
+	}
+	var oom *oomTracker
+	if opts.oomFeedback {
+		oom = newOOMTracker()
+		watcher := &oomWatcher{
+			logger:   logrus.WithField("component", "oom-watcher"),
+			client:   mgr.GetClient(),
+			tracker:  oom,
+			interval: opts.oomScanInterval,
+		}
+		interrupts.Run(func(ctx context.Context) {
+			if !mgr.GetCache().WaitForCacheSync(ctx) {
+				return
+			}
+			watcher.run(ctx)
+		})
+	}
+	var efficiency *efficiencyAnalyzer
+	if opts.efficiencyInterval > 0 {
+		efficiency = &efficiencyAnalyzer{
+			logger:         logrus.WithField("component", "efficiency-analyzer"),
+			prioritization: prioritization,
+			historyFile:    opts.efficiencyHistoryFile,
+			configFile:     opts.configFile,
+			interval:       opts.efficiencyInterval,
+		}
+		analyzer := efficiency
+		interrupts.Run(func(ctx context.Context) {
+			if !mgr.GetCache().WaitForCacheSync(ctx) {
+				return
+			}
+			analyzer.run(ctx)
+		})
+	}
+	var usage *imageUsage
+	if opts.prepullNamespace != "" {
+		usage = newImageUsage()
+		prepull := &prePullReconciler{
+			logger:     logrus.WithField("component", "prepull"),
+			client:     mgr.GetClient(),
+			usage:      usage,
+			namespace:  opts.prepullNamespace,
+			topN:       opts.prepullTopImages,
+			pauseImage: opts.prepullPauseImage,
+			interval:   opts.prepullInterval,
+		}
+		interrupts.Run(func(ctx context.Context) {
+			if !mgr.GetCache().WaitForCacheSync(ctx) {
+				return
+			}
+			prepull.run(ctx)
+		})
+	}
+	var shrinkOverlay *dynamicShrink
+	if opts.throttlePrometheusURL != "" {
+		shrinkOverlay = &dynamicShrink{}
+		promClient, err := prometheusclient.NewClient(prometheusclient.Config{Address: opts.throttlePrometheusURL})
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to construct Prometheus client.")
+		}
+		adjuster := &throttleAdjuster{
+			logger:    logrus.WithField("component", "throttle-adjuster"),
+			querier:   &prometheusThrottleQuerier{api: prometheusapi.NewAPI(promClient), query: opts.throttleQuery},
+			overlay:   shrinkOverlay,
+			podClass:  opts.throttleClass,
+			slo:       opts.throttleSLO,
+			minFactor: opts.throttleMinFactor,
+			maxFactor: opts.throttleMaxFactor,
+			step:      opts.throttleStep,
+			base:      opts.shrinkFactors[opts.throttleClass],
+			interval:  opts.throttleInterval,
+		}
+		if adjuster.base == 0 {
+			adjuster.base = opts.throttleMaxFactor
+		}
+		interrupts.Run(adjuster.run)
+	}
+	if opts.servesWebhooks() {
+		mutator = serve(opts, prioritization, saved, usage, audit, oom, retries, shrinkOverlay)
+	}
+
+	if opts.adminPort != 0 {
+		client, err := kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to construct client.")
+		}
+		admin := &adminServer{
+			logger:         logrus.WithField("component", "admin"),
+			client:         client,
+			prioritization: prioritization,
+			avoidanceMode:  opts.avoidanceMode,
+			savings:        saved,
+			efficiency:     efficiency,
+			audit:          audit,
+			mutator:        mutator,
+		}
+		admin.serve(opts.adminPort)
+	}
+
+	if err := mgr.Start(interrupts.Context()); err != nil {
+		logrus.WithError(err).Fatal("Manager ended with error.")
+	}
+}
+
+// usageProfileFor builds the usage profiler when a profile file is
+// configured.
+func usageProfileFor(opts *options, logger *logrus.Entry) *usageProfiler {
+	if opts.usageProfileFile == "" {
+		return nil
+	}
+	return &usageProfiler{logger: logger.WithField("component", "usage-profiler"), path: opts.usageProfileFile}
+}
+
+// commaSet splits a comma-separated flag value into a membership set.
+func commaSet(value string) map[string]bool {
+	return setOf(strings.Split(value, ","))
+}
+
+func setOf(entries []string) map[string]bool {
+	set := map[string]bool{}
+	for _, entry := range entries {
+		if entry != "" {
+			set[entry] = true
+		}
+	}
+	return set
+}
+
+func serve(opts *options, prioritization *scheduling.Prioritization, saved *savings, usage *imageUsage, audit *auditLog, oom *oomTracker, retries *retryTracker, shrinkOverlay *dynamicShrink) *podMutator {
+	logger := logrus.WithField("component", "ci-scheduling-webhook")
+	var tenantPolicies *tenantPolicyResolver
+	if opts.enableTenantPolicies {
+		tenantPolicies = &tenantPolicyResolver{client: prioritization.Client}
+	}
+	var hot *configWatcher
+	if opts.configFile != "" {
+		configuration, err := schedulingconfig.Load(opts.configFile)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to load configuration.")
+		}
+		initial, err := resolveHotConfig(configuration)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to resolve configuration.")
+		}
+		hot = newConfigWatcher(logger.WithField("component", "config-watcher"), opts.configFile, initial)
+		interrupts.Run(hot.run)
+	}
+	var classPolicies *classificationPolicyResolver
+	if opts.enableClassificationPolicies {
+		classPolicies = &classificationPolicyResolver{client: prioritization.Client}
+	}
+	var overflow *overflowTracker
+	if opts.overflowPendingThreshold > 0 {
+		drainBelow := opts.overflowDrainThreshold
+		if drainBelow <= 0 {
+			drainBelow = opts.overflowPendingThreshold / 2
+		}
+		overflow = &overflowTracker{
+			logger:     logger.WithField("component", "overflow"),
+			client:     prioritization.Client,
+			activateAt: opts.overflowPendingThreshold,
+			drainBelow: drainBelow,
+			cacheFor:   5 * time.Second,
+		}
+	}
+	webhookScheme := runtime.NewScheme()
+	if err := scheme.AddToScheme(webhookScheme); err != nil {
+		logrus.WithError(err).Fatal("Failed to build webhook scheme.")
+	}
+	if err := pjapi.AddToScheme(webhookScheme); err != nil {
+		logrus.WithError(err).Fatal("Failed to add ProwJob types to webhook scheme.")
+	}
+	decoder := admission.NewDecoder(webhookScheme)
+	mutator := &podMutator{
+		logger:                       logger,
+		decoder:                      decoder,
+		prioritization:               prioritization,
+		avoidanceMode:                opts.avoidanceMode,
+		tolerationMode:               opts.tolerationMode,
+		shrinkCPURequests:            opts.shrinkFactors,
+		patchGuards:                  opts.patchGuards,
+		savings:                      saved,
+		classBandwidth:               opts.classBandwidth,
+		classEmptyDirSizeLimit:       opts.classEmptyDirSizeLimit,
+		classEphemeralStorageRequest: opts.classEphemeralStorageRequest,
+		classDefaultRequests:         opts.classDefaultRequests,
+		containerShrinkRules:         opts.containerShrinkRules,
+		memoryScratchClasses:         opts.memoryScratchSet,
+		stripCPULimitClasses:         commaSet(opts.stripCPULimitClasses),
+		guaranteedQoSClasses:         commaSet(opts.guaranteedQoSClasses),
+		classTolerations:             opts.classTolerations,
+		schedulingGateClasses:        commaSet(opts.schedulingGateClasses),
+		imageUsage:                   usage,
+		usageProfile:                 usageProfileFor(opts, logger),
+		oom:                          oom,
+		retries:                      retries,
+		dynamicShrink:                shrinkOverlay,
+		stageBudget:                  opts.mutationStageBudget,
+		classifier: scheduling.Classifier{
+			ImageClassRules:           opts.imageClassRules,
+			IOIntensiveJobs:           opts.ioIntensiveJobSet,
+			AcceleratorResources:      setOf(opts.acceleratorResources.Strings()),
+			HeavyMemoryThreshold:      opts.heavyMemoryQuantity,
+			LargeDiskThreshold:        opts.largeDiskQuantity,
+			StepClassRules:            opts.stepClassRules,
+			TargetClassRules:          opts.targetClassRules,
+			SpotJobTypes:              commaSet(opts.spotJobTypes),
+			PresubmitTiering:          opts.presubmitTiering,
+			DefaultClass:              opts.defaultClass,
+			XLBuildPatterns:           opts.xlBuildRegexps,
+			XLBuildRepos:              setOf(opts.xlBuildRepos.Strings()),
+			HarmlessExtendedResources: setOf(opts.harmlessResources.Strings()),
+			ResourceClassRules:        opts.resourceClassRules,
+			MetalStepNames:            setOf(opts.metalSteps.Strings()),
+		},
+		client:                       prioritization.Client,
+		statefulSetPodPolicy:         opts.statefulSetPodPolicy,
+		shrinkWindows:                opts.shrinkWindows,
+		tenantPolicies:               tenantPolicies,
+		classPolicies:                classPolicies,
+		overflow:                     overflow,
+		classPriorityClasses:         opts.classPriorityClassMap,
+		requestBounds:                opts.requestBounds,
+		cpuRequestIncrementMilli:     opts.cpuRequestIncrementMilli,
+		initShrinkFactor:             opts.initContainerShrinkFactor,
+		classLimitPolicy:             opts.classLimitPolicyMap,
+		shrinkExemptNamespaces:       opts.shrinkExemptNamespaces.Strings(),
+		packingPreferenceNodes:       opts.packingPreferenceNodes,
+		colocateNamespaces:           opts.colocateNamespaces,
+		pinNamespaceZones:            opts.pinNamespaceZones,
+		excludeControlPlane:          opts.excludeControlPlane,
+		spotNodeLabel:                opts.spotNodeLabel,
+		spotForbiddenClasses:         commaSet(opts.spotForbiddenClasses),
+		buildSpreadClasses:           commaSet(opts.buildSpreadClasses),
+		maxBuildsPerNodePerRepo:      opts.maxBuildsPerNodePerRepo,
+		classZoneSpread:              opts.classZoneSpread,
+		classActiveDeadlineSeconds:   opts.classActiveDeadlineSeconds,
+		classTerminationGraceSeconds: opts.classTerminationGraceSeconds,
+		shrinkExemptLabels:           opts.shrinkExemptLabels,
+		initCPUCapMilli:              opts.initContainerCPUCapMilli,
+		namespaceAllowlist:           opts.mutateNamespaces.Strings(),
+		namespaceDenylist:            opts.skipNamespaces.Strings(),
+		prowJobNamespace:             opts.prowJobLookupNamespace,
+		prowJobTypeClasses:           opts.prowJobTypeClassRules,
+		audit:                        audit,
+		hot:                          hot,
+	}
+	handlers := map[string]*webhook.Admission{
+		"/pods": {Handler: mutator},
+		"/nodes": {Handler: &nodeMutator{
+			logger:  logger,
+			decoder: decoder,
+		}},
+	}
+	if opts.admitProwJobs {
+		handlers["/prowjobs"] = &webhook.Admission{Handler: &prowJobMutator{
+			logger:  logger,
+			decoder: decoder,
+			classifier: scheduling.Classifier{
+				ImageClassRules:      opts.imageClassRules,
+				IOIntensiveJobs:      opts.ioIntensiveJobSet,
+				AcceleratorResources: setOf(opts.acceleratorResources.Strings()),
+			},
+			shrinkFactors:        opts.shrinkFactors,
+			initShrinkFactor:     opts.initContainerShrinkFactor,
+			containerShrinkRules: opts.containerShrinkRules,
+		}}
+	}
+	if opts.unixSocket != "" {
+		serveUnixSocket(opts.unixSocket, handlers, logger)
+		return mutator
+	}
+	serverOptions := webhook.Options{
+		Port:    opts.port,
+		CertDir: opts.certDir,
+	}
+	if opts.clientCAFile != "" {
+		clientCAs, err := clientCAPool(opts.clientCAFile)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to load client CA bundle.")
+		}
+		serverOptions.TLSOpts = append(serverOptions.TLSOpts, func(config *tls.Config) {
+			config.ClientCAs = clientCAs
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		})
+	}
+	server := webhook.NewServer(serverOptions)
+	for path, handler := range handlers {
+		server.Register(path, handler)
+	}
+	logger.Info("Serving admission webhooks.")
+	interrupts.Run(func(ctx context.Context) {
+		if err := server.Start(ctx); err != nil {
+			logrus.WithError(err).Fatal("Failed to serve webhooks.")
+		}
+	})
+	return mutator
+}
+
+// clientCAPool loads the CA bundle the webhook port verifies client
+// certificates against.
+func clientCAPool(path string) (*x509.CertPool, error) {
+	bundle, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read client CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("no certificates found in client CA bundle %s", path)
+	}
+	return pool, nil
+}
+
+// serveUnixSocket serves the admission handlers over plain HTTP on a unix
+// domain socket, leaving TLS termination (and certificate handling entirely)
+// to a hardened sidecar. The magic value "systemd" accepts a listener passed
+// through systemd socket activation instead of binding one ourselves.
+func serveUnixSocket(socket string, handlers map[string]*webhook.Admission, logger *logrus.Entry) {
+	mux := http.NewServeMux()
+	for path, handler := range handlers {
+		mux.Handle(path, handler)
+	}
+	var listener net.Listener
+	var err error
+	if socket == "systemd" {
+		listener, err = systemdListener()
+	} else {
+		// A stale socket from a previous instance would make the bind fail.
+		if removeErr := os.Remove(socket); removeErr != nil && !os.IsNotExist(removeErr) {
+			logrus.WithError(removeErr).Fatal("Failed to remove stale unix socket.")
+		}
+		listener, err = net.Listen("unix", socket)
+	}
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to listen on unix socket.")
+	}
+	server := &http.Server{Handler: mux}
+	logger.WithField("socket", listener.Addr().String()).Info("Serving admission webhooks on unix socket.")
+	interrupts.Run(func(ctx context.Context) {
+		go func() {
+			<-ctx.Done()
+			if err := server.Close(); err != nil {
+				logrus.WithError(err).Error("Failed to close unix socket server.")
+			}
+		}()
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.WithError(err).Fatal("Failed to serve webhooks on unix socket.")
+		}
+	})
+}
+
+// systemdListener adopts the first socket passed by systemd socket
+// activation, per the LISTEN_FDS protocol (fds start at 3).
+func systemdListener() (net.Listener, error) {
+	if pid := os.Getenv("LISTEN_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return nil, fmt.Errorf("LISTEN_PID %s does not match our pid %d", pid, os.Getpid())
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, errors.New("LISTEN_FDS does not describe any passed socket")
+	}
+	file := os.NewFile(3, "systemd-socket")
+	defer file.Close()
+	return net.FileListener(file)
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+type nodeMutator struct {
+	logger  *logrus.Entry
+	decoder *admission.Decoder
+}
+
+func (m *nodeMutator) Handle(_ context.Context, req admission.Request) admission.Response {
+	node := &corev1.Node{}
+	if err := m.decoder.Decode(req, node); err != nil {
+		logrus.WithError(err).Error("Failed to decode raw object as Node.")
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	logger := m.logger.WithField("name", node.Name)
+
+	podClass, dedicated := node.Labels[scheduling.LabelName]
+	if !dedicated {
+		return admission.Allowed("ignoring node outside of CI workload pools")
+	}
+	if len(req.OldObject.Raw) > 0 {
+		oldNode := &corev1.Node{}
+		if err := m.decoder.DecodeRaw(req.OldObject, oldNode); err != nil {
+			logrus.WithError(err).Error("Failed to decode raw old object as Node.")
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		// Nodes are updated constantly for status heartbeats and the like;
+		// only run the mutation when a field we act on actually changed.
+		if !schedulingRelevantChange(oldNode, node) {
+			return admission.Allowed("node change does not affect scheduling-relevant fields")
+		}
+	}
+	mutateNode(node, podClass, logger)
+
+	marshaledNode, err := json.Marshal(node)
+	if err != nil {
+		logger.WithError(err).Error("Could not marshal mutated Node.")
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	response := admission.PatchResponseFromRaw(req.Object.Raw, marshaledNode)
+	response.Patches = scheduling.MinimizePatches(req.Object.Raw, response.Patches)
+	// we need these to be deterministically ordered for testing
+	sort.Slice(response.Patches, func(i, j int) bool {
+		if response.Patches[i].Operation != response.Patches[j].Operation {
+			return response.Patches[i].Operation < response.Patches[j].Operation
+		}
+		return response.Patches[i].Path < response.Patches[j].Path
+	})
+	return response
+}
+
+// schedulingRelevantChange reports whether an update to a node touched any of
+// the fields the mutator and the avoidance machinery base decisions on: our
+// class label, the taints, or allocatable capacity.
+func schedulingRelevantChange(oldNode, newNode *corev1.Node) bool {
+	if oldNode.Labels[scheduling.LabelName] != newNode.Labels[scheduling.LabelName] {
+		return true
+	}
+	if !apiequality.Semantic.DeepEqual(oldNode.Spec.Taints, newNode.Spec.Taints) {
+		return true
+	}
+	if !apiequality.Semantic.DeepEqual(oldNode.Status.Allocatable, newNode.Status.Allocatable) {
+		return true
+	}
+	return false
+}
+
+// mutateNode ensures a node dedicated to a CI workload class carries the
+// class taint, so that only pods admitted with the matching toleration land
+// on it.
+func mutateNode(node *corev1.Node, podClass string, logger *logrus.Entry) {
+	taint := corev1.Taint{
+		Key:    scheduling.LabelName,
+		Value:  podClass,
+		Effect: corev1.TaintEffectNoSchedule,
+	}
+	for _, existing := range node.Spec.Taints {
+		if existing.Key == taint.Key && existing.Effect == taint.Effect {
+			return
+		}
+	}
+	logger.Debugf("adding class taint %s=%s to node", taint.Key, taint.Value)
+	node.Spec.Taints = append(node.Spec.Taints, taint)
+}
+
+// classTaintReconciler continuously converges the class NoSchedule taint on
+// every pool node. The node webhook only fires on node admission events, so
+// without this controller a taint removed out-of-band would stay missing
+// until the next node API write.
+type classTaintReconciler struct {
+	logger         *logrus.Entry
+	client         ctrlruntimeclient.Client
+	prioritization *scheduling.Prioritization
+	interval       time.Duration
+}
+
+func (r *classTaintReconciler) run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, podClass := range scheduling.PodClasses {
+				if err := r.reconcileClass(ctx, podClass); err != nil {
+					r.logger.WithError(err).WithField("class", podClass).Error("Failed to reconcile class taints.")
+				}
+			}
+		}
+	}
+}
+
+func (r *classTaintReconciler) reconcileClass(ctx context.Context, podClass string) error {
+	utilization, err := r.prioritization.ClassNodeUtilization(ctx, podClass)
+	if err != nil {
+		return err
+	}
+	taint := corev1.Taint{
+		Key:    scheduling.LabelName,
+		Value:  podClass,
+		Effect: corev1.TaintEffectNoSchedule,
+	}
+	for _, entry := range utilization {
+		if err := reconcileTaint(ctx, r.client, entry.Node, taint, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// avoidanceReconciler periodically reconciles the soft avoidance taint across
+// all pool nodes: every scale-down candidate is tainted, whether or not it
+// fits in the bounded preclusion affinity list, and the taint is removed once
+// a node is no longer a candidate.
+type avoidanceReconciler struct {
+	logger         *logrus.Entry
+	client         ctrlruntimeclient.Client
+	prioritization *scheduling.Prioritization
+	interval       time.Duration
+
+	// labelCandidates additionally maintains the avoidance state label on
+	// candidates, for label-based preclusion.
+	labelCandidates bool
+
+	// drainAfter starts evicting safe-to-evict pods from candidates that
+	// stayed candidates this long; escalateAfter then hardens the avoidance
+	// taint to NoSchedule once the node drained. Zero disables the phase.
+	// classDrainAfter overrides the delay per class, e.g. to avoid longtest
+	// pools far more conservatively than test pools.
+	drainAfter      time.Duration
+	escalateAfter   time.Duration
+	classDrainAfter map[string]time.Duration
+
+	// maxNodeAge recycles long-lived nodes: pool nodes older than this are
+	// treated as avoidance candidates even when busy-capable, so leaked
+	// disk, images, and kernel issues get replaced automatically. Zero
+	// disables recycling.
+	maxNodeAge time.Duration
+
+	// now stands in for time.Now in tests.
+	now func() time.Time
+}
+
+// avoidanceSinceAnnotation records when a node became an avoidance
+// candidate, driving the drain and escalation phases.
+const avoidanceSinceAnnotation = "ci-workload.openshift.io/avoidance-since"
+
+// safeToEvictAnnotation is the cluster-autoscaler convention opting a pod
+// out of eviction.
+const safeToEvictAnnotation = "cluster-autoscaler.kubernetes.io/safe-to-evict"
+
+var nodesBlockedByUnevictable = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "ci_scheduling_webhook_nodes_blocked_by_unevictable_pods_total",
+	Help: "Avoidance escalations skipped because a pod on the node is protected by a PodDisruptionBudget or a do-not-disrupt annotation.",
+}, []string{"class"})
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(nodesBlockedByUnevictable)
+}
+
+// unevictablePodOn reports whether any pod on the node is protected by a
+// do-not-disrupt annotation or an exhausted PodDisruptionBudget, naming the
+// first such pod.
+func unevictablePodOn(ctx context.Context, client ctrlruntimeclient.Client, nodeName string) (bool, string, error) {
+	podList := &corev1.PodList{}
+	if err := client.List(ctx, podList); err != nil {
+		return false, "", err
+	}
+	budgetList := &policyv1.PodDisruptionBudgetList{}
+	if err := client.List(ctx, budgetList); err != nil {
+		return false, "", err
+	}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Spec.NodeName != nodeName {
+			continue
+		}
+		if pod.Annotations[safeToEvictAnnotation] == "false" {
+			return true, pod.Namespace + "/" + pod.Name, nil
+		}
+		for j := range budgetList.Items {
+			budget := &budgetList.Items[j]
+			if budget.Namespace != pod.Namespace || budget.Status.DisruptionsAllowed > 0 {
+				continue
+			}
+			selector, err := metav1.LabelSelectorAsSelector(budget.Spec.Selector)
+			if err != nil {
+				continue
+			}
+			if selector.Matches(labels.Set(pod.Labels)) {
+				return true, pod.Namespace + "/" + pod.Name, nil
+			}
+		}
+	}
+	return false, "", nil
+}
+
+func (r *avoidanceReconciler) run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, podClass := range scheduling.PodClasses {
+				if err := r.reconcileClass(ctx, podClass); err != nil {
+					r.logger.WithError(err).WithField("class", podClass).Error("Failed to reconcile avoidance taints.")
+				}
+			}
+		}
+	}
+}
+
+func (r *avoidanceReconciler) reconcileClass(ctx context.Context, podClass string) error {
+	utilization, err := r.prioritization.ClassNodeUtilization(ctx, podClass)
+	if err != nil {
+		return err
+	}
+	candidateNames := map[string]bool{}
+	for _, candidate := range r.prioritization.ScaleDownCandidatesOf(podClass, utilization) {
+		candidateNames[candidate.Node.Name] = true
+	}
+	if r.maxNodeAge > 0 {
+		now := time.Now()
+		if r.now != nil {
+			now = r.now()
+		}
+		for _, entry := range utilization {
+			if now.Sub(entry.Node.CreationTimestamp.Time) > r.maxNodeAge && !candidateNames[entry.Node.Name] {
+				r.logger.WithField("node", entry.Node.Name).WithField("class", podClass).Debug("recycling node past the maximum age")
+				candidateNames[entry.Node.Name] = true
+			}
+		}
+	}
+	taint := corev1.Taint{
+		Key:    scheduling.AvoidanceTaintName,
+		Value:  podClass,
+		Effect: corev1.TaintEffectPreferNoSchedule,
+	}
+	for _, entry := range utilization {
+		if err := reconcileTaint(ctx, r.client, entry.Node, taint, candidateNames[entry.Node.Name]); err != nil {
+			return err
+		}
+		if r.labelCandidates {
+			if err := reconcileAvoidanceLabel(ctx, r.client, entry.Node, candidateNames[entry.Node.Name]); err != nil {
+				return err
+			}
+		}
+		if r.drainDelayFor(podClass) > 0 {
+			if err := r.reconcileDrainPhase(ctx, entry, podClass, candidateNames[entry.Node.Name]); err != nil {
+				r.logger.WithError(err).WithField("node", entry.Node.Name).Warning("Failed to advance the drain phase.")
+			}
+		}
+	}
+	return nil
+}
+
+// drainDelayFor returns the effective drain delay for a class.
+func (r *avoidanceReconciler) drainDelayFor(podClass string) time.Duration {
+	if delay, overridden := r.classDrainAfter[podClass]; overridden {
+		return delay
+	}
+	return r.drainAfter
+}
+
+// reconcileDrainPhase tracks candidacy age, evicts safe-to-evict pods after
+// the drain delay, and hardens the avoidance taint to NoSchedule once the
+// node drained, so nodes empty faster and more predictably.
+func (r *avoidanceReconciler) reconcileDrainPhase(ctx context.Context, entry scheduling.NodeUtilization, podClass string, candidate bool) error {
+	now := time.Now()
+	if r.now != nil {
+		now = r.now()
+	}
+	node := entry.Node
+	hardTaint := corev1.Taint{
+		Key:    scheduling.AvoidanceTaintName,
+		Value:  podClass,
+		Effect: corev1.TaintEffectNoSchedule,
+	}
+	if !candidate {
+		if node.Annotations[avoidanceSinceAnnotation] != "" {
+			updated := node.DeepCopy()
+			delete(updated.Annotations, avoidanceSinceAnnotation)
+			if err := r.client.Patch(ctx, updated, ctrlruntimeclient.MergeFrom(node)); err != nil {
+				return err
+			}
+		}
+		return reconcileTaint(ctx, r.client, node, hardTaint, false)
+	}
+	since := node.Annotations[avoidanceSinceAnnotation]
+	if since == "" {
+		updated := node.DeepCopy()
+		if updated.Annotations == nil {
+			updated.Annotations = map[string]string{}
+		}
+		updated.Annotations[avoidanceSinceAnnotation] = now.Format(time.RFC3339)
+		return r.client.Patch(ctx, updated, ctrlruntimeclient.MergeFrom(node))
+	}
+	began, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return err
+	}
+	age := now.Sub(began)
+	if age < r.drainDelayFor(podClass) {
+		return nil
+	}
+	if blocked, blocker, err := unevictablePodOn(ctx, r.client, node.Name); err != nil {
+		return err
+	} else if blocked {
+		nodesBlockedByUnevictable.WithLabelValues(podClass).Inc()
+		r.logger.WithField("node", node.Name).WithField("pod", blocker).Debug("avoidance escalation blocked by an unevictable pod")
+		return nil
+	}
+	if entry.PodCount > 0 {
+		return r.evictClassPods(ctx, node.Name, podClass)
+	}
+	if r.escalateAfter > 0 && age >= r.escalateAfter {
+		return reconcileTaint(ctx, r.client, node, hardTaint, true)
+	}
+	return nil
+}
+
+// evictClassPods evicts the node's class pods through the Eviction API, so
+// PodDisruptionBudgets are respected; pods opted out via the safe-to-evict
+// annotation are skipped.
+func (r *avoidanceReconciler) evictClassPods(ctx context.Context, nodeName, podClass string) error {
+	podList := &corev1.PodList{}
+	if err := r.client.List(ctx, podList); err != nil {
+		return err
+	}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Spec.NodeName != nodeName || pod.Spec.NodeSelector[scheduling.LabelName] != podClass {
+			continue
+		}
+		if pod.Annotations[safeToEvictAnnotation] == "false" {
+			continue
+		}
+		eviction := &policyv1.Eviction{ObjectMeta: metav1.ObjectMeta{Namespace: pod.Namespace, Name: pod.Name}}
+		if err := r.client.SubResource("eviction").Create(ctx, pod, eviction); err != nil {
+			// PDB-protected pods return 429; try again next tick.
+			r.logger.WithError(err).WithField("pod", pod.Namespace+"/"+pod.Name).Debug("eviction deferred")
+			continue
+		}
+		r.logger.WithField("pod", pod.Namespace+"/"+pod.Name).WithField("node", nodeName).Debug("evicted pod from draining node")
+	}
+	return nil
+}
+
+// reconcileAvoidanceLabel keeps the avoidance state label in sync with
+// candidacy.
+func reconcileAvoidanceLabel(ctx context.Context, client ctrlruntimeclient.Client, node *corev1.Node, candidate bool) error {
+	_, labeled := node.Labels[scheduling.AvoidanceStateLabelName]
+	if candidate == labeled {
+		return nil
+	}
+	updated := node.DeepCopy()
+	if candidate {
+		if updated.Labels == nil {
+			updated.Labels = map[string]string{}
+		}
+		updated.Labels[scheduling.AvoidanceStateLabelName] = scheduling.AvoidanceStateCordoned
+	} else {
+		delete(updated.Labels, scheduling.AvoidanceStateLabelName)
+	}
+	return client.Patch(ctx, updated, ctrlruntimeclient.MergeFrom(node))
+}
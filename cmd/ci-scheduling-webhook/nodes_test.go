@@ -0,0 +1,404 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	nodev1 "k8s.io/api/node/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+func TestRuntimeClassReconciler(t *testing.T) {
+	client := fakectrlruntimeclient.NewClientBuilder().Build()
+	reconciler := &runtimeClassReconciler{
+		logger:   logrus.WithField("test", t.Name()),
+		client:   client,
+		handler:  "runc",
+		overhead: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("10m")},
+	}
+	if err := reconciler.reconcileClass(context.Background(), scheduling.ClassTests); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	created := &nodev1.RuntimeClass{}
+	if err := client.Get(context.Background(), types.NamespacedName{Name: runtimeClassPrefix + scheduling.ClassTests}, created); err != nil {
+		t.Fatalf("expected the RuntimeClass to be created: %v", err)
+	}
+	if created.Scheduling == nil || created.Scheduling.NodeSelector[scheduling.LabelName] != scheduling.ClassTests || len(created.Scheduling.Tolerations) != 1 {
+		t.Errorf("unexpected scheduling stanza: %+v", created.Scheduling)
+	}
+	if created.Overhead == nil || created.Overhead.PodFixed.Cpu().String() != "10m" {
+		t.Errorf("unexpected overhead: %+v", created.Overhead)
+	}
+	// Drift in the scheduling stanza is repaired.
+	drifted := created.DeepCopy()
+	drifted.Scheduling.Tolerations = nil
+	if err := client.Update(context.Background(), drifted); err != nil {
+		t.Fatalf("could not update: %v", err)
+	}
+	if err := reconciler.reconcileClass(context.Background(), scheduling.ClassTests); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	repaired := &nodev1.RuntimeClass{}
+	if err := client.Get(context.Background(), types.NamespacedName{Name: runtimeClassPrefix + scheduling.ClassTests}, repaired); err != nil {
+		t.Fatalf("could not get: %v", err)
+	}
+	if len(repaired.Scheduling.Tolerations) != 1 {
+		t.Errorf("expected the toleration to be restored, got %+v", repaired.Scheduling)
+	}
+}
+
+func TestExpanderReconciler(t *testing.T) {
+	client := fakectrlruntimeclient.NewClientBuilder().Build()
+	reconciler := &expanderReconciler{
+		logger:      logrus.WithField("test", t.Name()),
+		client:      client,
+		namespace:   "kube-system",
+		machineSets: map[string]string{scheduling.ClassTests: "openshift-machine-api/ci-tests", scheduling.ClassBuilds: "openshift-machine-api/ci-builds"},
+	}
+	if err := reconciler.reconcile(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	configMap := &corev1.ConfigMap{}
+	if err := client.Get(context.Background(), types.NamespacedName{Namespace: "kube-system", Name: priorityExpanderConfigMap}, configMap); err != nil {
+		t.Fatalf("expected the ConfigMap to be created: %v", err)
+	}
+	priorities := configMap.Data["priorities"]
+	for _, expected := range []string{"100:", "^ci-builds.*", "^ci-tests.*", "10:", "- .*"} {
+		if !strings.Contains(priorities, expected) {
+			t.Errorf("expected priorities to contain %q, got:\n%s", expected, priorities)
+		}
+	}
+}
+
+func TestAvoidanceHonorsUtilizationCutoff(t *testing.T) {
+	// Both nodes are structurally drainable into each other, but one is
+	// busier than the cutoff allows; only the other may be tainted.
+	quiet := poolNode("node-quiet", scheduling.ClassTests, 10)
+	busy := poolNode("node-busy", scheduling.ClassTests, 10)
+	spare := poolNode("node-spare", scheduling.ClassTests, 100)
+	builder := fakectrlruntimeclient.NewClientBuilder().WithObjects(quiet, busy, spare)
+	for _, pod := range poolPods("node-quiet", scheduling.ClassTests, 1) {
+		builder.WithObjects(pod)
+	}
+	for _, pod := range poolPods("node-busy", scheduling.ClassTests, 6) {
+		builder.WithObjects(pod)
+	}
+	client := builder.Build()
+	reconciler := &avoidanceReconciler{
+		logger:         logrus.WithField("test", t.Name()),
+		client:         client,
+		prioritization: &scheduling.Prioritization{Client: client, MaxCandidateUtilization: 0.3},
+	}
+	if err := reconciler.reconcileClass(context.Background(), scheduling.ClassTests); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tainted := func(name string) bool {
+		node := &corev1.Node{}
+		if err := client.Get(context.Background(), types.NamespacedName{Name: name}, node); err != nil {
+			t.Fatalf("could not get node: %v", err)
+		}
+		for _, taint := range node.Spec.Taints {
+			if taint.Key == scheduling.AvoidanceTaintName {
+				return true
+			}
+		}
+		return false
+	}
+	if !tainted("node-quiet") {
+		t.Error("expected the quiet node to carry the avoidance taint")
+	}
+	if tainted("node-busy") {
+		t.Error("expected the node above the utilization cutoff to stay untainted")
+	}
+}
+
+func TestAvoidanceHonorsScheduledWarmBuffer(t *testing.T) {
+	builder := fakectrlruntimeclient.NewClientBuilder()
+	for i := 0; i < 3; i++ {
+		builder.WithObjects(poolNode(fmt.Sprintf("node-%d", i), scheduling.ClassTests, 10))
+	}
+	for _, pod := range poolPods("node-2", scheduling.ClassTests, 4) {
+		builder.WithObjects(pod)
+	}
+	client := builder.Build()
+	schedule := &capacitySchedule{
+		entries: []scheduleEntry{{class: scheduling.ClassTests, minIdle: 2, days: "all", start: 0, end: 24 * 60}},
+		base:    map[string]int{},
+	}
+	reconciler := &avoidanceReconciler{
+		logger: logrus.WithField("test", t.Name()),
+		client: client,
+		prioritization: &scheduling.Prioritization{
+			Client:         client,
+			MinIdleNodesFn: schedule.minIdleFor,
+		},
+	}
+	if err := reconciler.reconcileClass(context.Background(), scheduling.ClassTests); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	taintedCount := 0
+	for i := 0; i < 3; i++ {
+		node := &corev1.Node{}
+		if err := client.Get(context.Background(), types.NamespacedName{Name: fmt.Sprintf("node-%d", i)}, node); err != nil {
+			t.Fatalf("could not get node: %v", err)
+		}
+		for _, taint := range node.Spec.Taints {
+			if taint.Key == scheduling.AvoidanceTaintName {
+				taintedCount++
+			}
+		}
+	}
+	// Two structural candidates minus the scheduled warm buffer of two.
+	if taintedCount != 0 {
+		t.Errorf("expected the scheduled warm buffer to keep all nodes untainted, got %d tainted", taintedCount)
+	}
+}
+
+func TestMaxNodeAgeRecycling(t *testing.T) {
+	clock := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	old := poolNode("node-old", scheduling.ClassTests, 10)
+	old.CreationTimestamp = metav1.NewTime(clock.Add(-40 * 24 * time.Hour))
+	fresh := poolNode("node-fresh", scheduling.ClassTests, 10)
+	fresh.CreationTimestamp = metav1.NewTime(clock.Add(-time.Hour))
+	builder := fakectrlruntimeclient.NewClientBuilder().WithObjects(old, fresh)
+	// Both nodes busy so neither is a structural candidate.
+	for _, pod := range poolPods("node-old", scheduling.ClassTests, 7) {
+		builder.WithObjects(pod)
+	}
+	for _, pod := range poolPods("node-fresh", scheduling.ClassTests, 7) {
+		builder.WithObjects(pod)
+	}
+	client := builder.Build()
+	reconciler := &avoidanceReconciler{
+		logger:         logrus.WithField("test", t.Name()),
+		client:         client,
+		prioritization: &scheduling.Prioritization{Client: client},
+		maxNodeAge:     30 * 24 * time.Hour,
+		now:            func() time.Time { return clock },
+	}
+	if err := reconciler.reconcileClass(context.Background(), scheduling.ClassTests); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	hasAvoidance := func(name string) bool {
+		node := &corev1.Node{}
+		if err := client.Get(context.Background(), types.NamespacedName{Name: name}, node); err != nil {
+			t.Fatalf("could not get node: %v", err)
+		}
+		for _, taint := range node.Spec.Taints {
+			if taint.Key == scheduling.AvoidanceTaintName {
+				return true
+			}
+		}
+		return false
+	}
+	if !hasAvoidance("node-old") {
+		t.Error("expected the over-age node to become an avoidance candidate")
+	}
+	if hasAvoidance("node-fresh") {
+		t.Error("expected the fresh node to stay untainted")
+	}
+}
+
+func TestUnevictablePodOn(t *testing.T) {
+	protected := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "ci-op-12345",
+			Name:        "protected",
+			Annotations: map[string]string{safeToEvictAnnotation: "false"},
+		},
+		Spec: corev1.PodSpec{NodeName: "node-0"},
+	}
+	budgeted := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-67890", Name: "budgeted", Labels: map[string]string{"app": "etcd"}},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+	}
+	budget := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-67890", Name: "etcd"},
+		Spec:       policyv1.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "etcd"}}},
+		Status:     policyv1.PodDisruptionBudgetStatus{DisruptionsAllowed: 0},
+	}
+	free := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "free"},
+		Spec:       corev1.PodSpec{NodeName: "node-2"},
+	}
+	client := fakectrlruntimeclient.NewClientBuilder().WithObjects(protected, budgeted, budget, free).Build()
+	if blocked, blocker, err := unevictablePodOn(context.Background(), client, "node-0"); err != nil || !blocked || blocker != "ci-op-12345/protected" {
+		t.Errorf("expected the do-not-disrupt pod to block, got %t %q (%v)", blocked, blocker, err)
+	}
+	if blocked, blocker, err := unevictablePodOn(context.Background(), client, "node-1"); err != nil || !blocked || blocker != "ci-op-67890/budgeted" {
+		t.Errorf("expected the PDB-protected pod to block, got %t %q (%v)", blocked, blocker, err)
+	}
+	if blocked, _, err := unevictablePodOn(context.Background(), client, "node-2"); err != nil || blocked {
+		t.Errorf("expected unprotected pods to not block, got %t (%v)", blocked, err)
+	}
+}
+
+func TestDrainPhase(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Two nodes so the emptier one is a candidate; the candidate has been
+	// avoided long enough to escalate and is already drained.
+	candidate := poolNode("node-0", scheduling.ClassTests, 10)
+	candidate.Annotations = map[string]string{avoidanceSinceAnnotation: clock.Add(-2 * time.Hour).Format(time.RFC3339)}
+	busy := poolNode("node-1", scheduling.ClassTests, 10)
+	builder := fakectrlruntimeclient.NewClientBuilder().WithObjects(candidate, busy)
+	for _, pod := range poolPods("node-1", scheduling.ClassTests, 4) {
+		builder.WithObjects(pod)
+	}
+	client := builder.Build()
+	reconciler := &avoidanceReconciler{
+		logger:         logrus.WithField("test", t.Name()),
+		client:         client,
+		prioritization: &scheduling.Prioritization{Client: client},
+		drainAfter:     30 * time.Minute,
+		escalateAfter:  time.Hour,
+		now:            func() time.Time { return clock },
+	}
+	if err := reconciler.reconcileClass(context.Background(), scheduling.ClassTests); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	node := &corev1.Node{}
+	if err := client.Get(context.Background(), types.NamespacedName{Name: "node-0"}, node); err != nil {
+		t.Fatalf("could not get node: %v", err)
+	}
+	var hardened bool
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == scheduling.AvoidanceTaintName && taint.Effect == corev1.TaintEffectNoSchedule {
+			hardened = true
+		}
+	}
+	if !hardened {
+		t.Error("expected the drained, long-avoided candidate to escalate to NoSchedule")
+	}
+}
+
+func TestClassTaintReconciler(t *testing.T) {
+	untainted := poolNode("node-0", scheduling.ClassTests, 10)
+	client := fakectrlruntimeclient.NewClientBuilder().WithObjects(untainted).Build()
+	reconciler := &classTaintReconciler{
+		logger:         logrus.WithField("test", t.Name()),
+		client:         client,
+		prioritization: &scheduling.Prioritization{Client: client},
+	}
+	if err := reconciler.reconcileClass(context.Background(), scheduling.ClassTests); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	node := &corev1.Node{}
+	if err := client.Get(context.Background(), types.NamespacedName{Name: "node-0"}, node); err != nil {
+		t.Fatalf("could not get node: %v", err)
+	}
+	tainted := false
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == scheduling.LabelName && taint.Value == scheduling.ClassTests && taint.Effect == corev1.TaintEffectNoSchedule {
+			tainted = true
+		}
+	}
+	if !tainted {
+		t.Error("expected the class taint to be converged onto the pool node")
+	}
+}
+
+func TestSchedulingRelevantChange(t *testing.T) {
+	base := func() *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "node",
+				Labels: map[string]string{scheduling.LabelName: scheduling.ClassTests},
+			},
+			Spec: corev1.NodeSpec{Taints: []corev1.Taint{{Key: scheduling.LabelName, Value: scheduling.ClassTests, Effect: corev1.TaintEffectNoSchedule}}},
+			Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+				corev1.ResourcePods: *resource.NewQuantity(10, resource.DecimalSI),
+			}},
+		}
+	}
+	var testCases = []struct {
+		name     string
+		mutate   func(node *corev1.Node)
+		expected bool
+	}{
+		{
+			name:     "heartbeat-adjacent change is irrelevant",
+			mutate:   func(node *corev1.Node) { node.Annotations = map[string]string{"heartbeat": "now"} },
+			expected: false,
+		},
+		{
+			name:     "class label change is relevant",
+			mutate:   func(node *corev1.Node) { node.Labels[scheduling.LabelName] = scheduling.ClassBuilds },
+			expected: true,
+		},
+		{
+			name:     "taint change is relevant",
+			mutate:   func(node *corev1.Node) { node.Spec.Taints = nil },
+			expected: true,
+		},
+		{
+			name: "allocatable change is relevant",
+			mutate: func(node *corev1.Node) {
+				node.Status.Allocatable[corev1.ResourcePods] = *resource.NewQuantity(20, resource.DecimalSI)
+			},
+			expected: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			oldNode, newNode := base(), base()
+			testCase.mutate(newNode)
+			if got := schedulingRelevantChange(oldNode, newNode); got != testCase.expected {
+				t.Errorf("%s: expected %t, got %t", testCase.name, testCase.expected, got)
+			}
+		})
+	}
+}
+
+func TestNodeMutatorFastPath(t *testing.T) {
+	mutator := &nodeMutator{
+		logger:  logrus.WithField("test", t.Name()),
+		decoder: admission.NewDecoder(scheme.Scheme),
+	}
+	node := &corev1.Node{
+		TypeMeta: metav1.TypeMeta{Kind: "Node", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "node",
+			Labels: map[string]string{scheduling.LabelName: scheduling.ClassTests},
+		},
+		Spec: corev1.NodeSpec{Taints: []corev1.Taint{{Key: scheduling.LabelName, Value: scheduling.ClassTests, Effect: corev1.TaintEffectNoSchedule}}},
+	}
+	heartbeat := node.DeepCopy()
+	heartbeat.Annotations = map[string]string{"heartbeat": "now"}
+	marshal := func(node *corev1.Node) []byte {
+		raw, err := json.Marshal(node)
+		if err != nil {
+			t.Fatalf("could not marshal node: %v", err)
+		}
+		return raw
+	}
+	response := mutator.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		UID:       "uid",
+		Operation: admissionv1.Update,
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Node"},
+		Object:    runtime.RawExtension{Raw: marshal(heartbeat)},
+		OldObject: runtime.RawExtension{Raw: marshal(node)},
+	}})
+	if !response.Allowed || len(response.Patches) != 0 {
+		t.Errorf("expected fast no-op response for heartbeat-adjacent update, got %+v", response)
+	}
+	if response.Result == nil || response.Result.Message != "node change does not affect scheduling-relevant fields" {
+		t.Errorf("expected fast-path message, got %+v", response.Result)
+	}
+}
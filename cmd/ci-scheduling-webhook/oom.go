@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// oomTracker counts observed OOM kills per (org/repo, container) workload,
+// so future admissions of the same workload get bumped memory and the
+// retry-OOM-retry loop on large builds breaks.
+type oomTracker struct {
+	lock sync.Mutex
+	// bumps counts distinct OOM kills per workload key.
+	bumps map[string]int
+	// seen deduplicates (pod UID, container, restart count) observations,
+	// with timestamps so entries can age out - a farm churns tens of
+	// thousands of pods a day and this process runs for weeks.
+	seen map[string]time.Time
+	// now stands in for time.Now in tests.
+	now func() time.Time
+}
+
+// seenRetention bounds how long dedup entries are kept; the pods they refer
+// to are long gone by then.
+const seenRetention = 24 * time.Hour
+
+func newOOMTracker() *oomTracker {
+	return &oomTracker{bumps: map[string]int{}, seen: map[string]time.Time{}}
+}
+
+func workloadKey(org, repo, container string) string {
+	return fmt.Sprintf("%s/%s/%s", org, repo, container)
+}
+
+func (t *oomTracker) record(key, observation string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	now := time.Now()
+	if t.now != nil {
+		now = t.now()
+	}
+	for entry, observed := range t.seen {
+		if now.Sub(observed) > seenRetention {
+			delete(t.seen, entry)
+		}
+	}
+	if _, counted := t.seen[observation]; counted {
+		return
+	}
+	t.seen[observation] = now
+	t.bumps[key]++
+}
+
+// bumpFactor returns the memory multiplier for a workload: half again per
+// observed OOM, capped at 3x so a broken workload cannot claim whole nodes.
+func (t *oomTracker) bumpFactor(key string) float64 {
+	if t == nil {
+		return 1.0
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	factor := 1.0 + 0.5*float64(t.bumps[key])
+	if factor > 3.0 {
+		factor = 3.0
+	}
+	return factor
+}
+
+// oomWatcher scans ci-op namespaces for containers terminated with OOMKilled
+// and feeds the tracker.
+type oomWatcher struct {
+	logger   *logrus.Entry
+	client   ctrlruntimeclient.Client
+	tracker  *oomTracker
+	interval time.Duration
+}
+
+func (w *oomWatcher) run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.scan(ctx); err != nil {
+				w.logger.WithError(err).Error("Failed to scan for OOM-killed containers.")
+			}
+		}
+	}
+}
+
+func (w *oomWatcher) scan(ctx context.Context) error {
+	podList := &corev1.PodList{}
+	if err := w.client.List(ctx, podList); err != nil {
+		return err
+	}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if !strings.HasPrefix(pod.Namespace, "ci-op-") && !strings.HasPrefix(pod.Namespace, "ci-ln-") {
+			continue
+		}
+		org, repo := pod.Labels[metadataOrgLabel], pod.Labels[metadataRepoLabel]
+		if org == "" || repo == "" {
+			continue
+		}
+		for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+			for _, status := range statuses {
+				terminated := status.State.Terminated
+				if terminated == nil {
+					terminated = status.LastTerminationState.Terminated
+				}
+				if terminated == nil || terminated.Reason != "OOMKilled" {
+					continue
+				}
+				key := workloadKey(org, repo, status.Name)
+				observation := fmt.Sprintf("%s/%s/%d", pod.UID, status.Name, status.RestartCount)
+				w.logger.WithField("workload", key).Warning("observed OOM-killed container, bumping future memory requests")
+				w.tracker.record(key, observation)
+			}
+		}
+	}
+	return nil
+}
+
+// bumpOOMMemory raises memory requests and limits of containers whose
+// workload has a recorded OOM history.
+func (m *podMutator) bumpOOMMemory(pod *corev1.Pod, logger *logrus.Entry) {
+	if m.oom == nil {
+		return
+	}
+	org, repo := pod.Labels[metadataOrgLabel], pod.Labels[metadataRepoLabel]
+	if org == "" || repo == "" {
+		return
+	}
+	bump := func(containers []corev1.Container) {
+		for i := range containers {
+			factor := m.oom.bumpFactor(workloadKey(org, repo, containers[i].Name))
+			if factor <= 1.0 {
+				continue
+			}
+			for _, list := range []corev1.ResourceList{containers[i].Resources.Requests, containers[i].Resources.Limits} {
+				memory, has := list[corev1.ResourceMemory]
+				if !has {
+					continue
+				}
+				bumped := int64(float64(memory.Value()) * factor)
+				list[corev1.ResourceMemory] = *resource.NewQuantity(bumped, resource.BinarySI)
+			}
+			logger.Debugf("bumped memory of container %s by %.1fx after OOM history", containers[i].Name, factor)
+		}
+	}
+	bump(pod.Spec.InitContainers)
+	bump(pod.Spec.Containers)
+}
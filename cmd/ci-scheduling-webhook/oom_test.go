@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+func TestRetryBoost(t *testing.T) {
+	failed := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ci-op-12345",
+			Name:      "failed-run",
+			UID:       "uid-failed",
+			Labels:    map[string]string{"prow.k8s.io/job": "e2e-aws"},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodFailed},
+	}
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newRetryTracker(time.Hour, 0.25, 2.0)
+	tracker.now = func() time.Time { return clock }
+	watcher := &retryWatcher{
+		logger:  logrus.WithField("test", t.Name()),
+		client:  fakectrlruntimeclient.NewClientBuilder().WithObjects(failed).Build(),
+		tracker: tracker,
+	}
+	if err := watcher.scan(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := watcher.scan(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if factor := tracker.boostFactor("e2e-aws"); factor != 1.25 {
+		t.Errorf("expected a 1.25x boost after one failure, got %f", factor)
+	}
+	if factor := tracker.boostFactor("healthy-job"); factor != 1.0 {
+		t.Errorf("expected no boost for healthy jobs, got %f", factor)
+	}
+	// Failures age out of the window.
+	clock = clock.Add(2 * time.Hour)
+	if factor := tracker.boostFactor("e2e-aws"); factor != 1.0 {
+		t.Errorf("expected the failure to age out after the window, got %f", factor)
+	}
+}
+
+func TestRetryBoostExemptsShrinking(t *testing.T) {
+	tracker := newRetryTracker(time.Hour, 0.25, 2.0)
+	tracker.record("e2e-aws", "uid-1")
+	mutator := &podMutator{
+		prioritization:    &scheduling.Prioritization{Client: fakectrlruntimeclient.NewClientBuilder().Build()},
+		avoidanceMode:     avoidanceModeTaints,
+		shrinkCPURequests: map[string]float64{scheduling.ClassTests: 0.5},
+		retries:           tracker,
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ci-op-12345",
+			Name:      "retry",
+			Labels:    map[string]string{"prow.k8s.io/job": "e2e-aws"},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name:      "test",
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2000m")}},
+		}}},
+	}
+	if err := mutator.mutatePod(context.Background(), pod, scheduling.ClassTests, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 2000m declared, no shrink, boosted 1.25x - not 0.625x of declared.
+	if cpu := pod.Spec.Containers[0].Resources.Requests.Cpu().String(); cpu != "2500m" {
+		t.Errorf("expected the boost to apply to the declared request with no shrink, got %s", cpu)
+	}
+}
+
+func TestOOMFeedback(t *testing.T) {
+	oomPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ci-op-12345",
+			Name:      "build-pod",
+			UID:       "uid-1",
+			Labels:    map[string]string{metadataOrgLabel: "openshift", metadataRepoLabel: "origin"},
+		},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name:         "build",
+			RestartCount: 1,
+			LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+				Reason: "OOMKilled",
+			}},
+		}}},
+	}
+	tracker := newOOMTracker()
+	watcher := &oomWatcher{
+		logger:  logrus.WithField("test", t.Name()),
+		client:  fakectrlruntimeclient.NewClientBuilder().WithObjects(oomPod).Build(),
+		tracker: tracker,
+	}
+	if err := watcher.scan(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The same observation does not double-count.
+	if err := watcher.scan(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	key := workloadKey("openshift", "origin", "build")
+	if factor := tracker.bumpFactor(key); factor != 1.5 {
+		t.Errorf("expected a 1.5x bump after one OOM, got %f", factor)
+	}
+	if factor := tracker.bumpFactor(workloadKey("other", "repo", "build")); factor != 1.0 {
+		t.Errorf("expected no bump for unaffected workloads, got %f", factor)
+	}
+
+	mutator := &podMutator{
+		prioritization: &scheduling.Prioritization{Client: fakectrlruntimeclient.NewClientBuilder().Build()},
+		avoidanceMode:  avoidanceModeTaints,
+		oom:            tracker,
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ci-op-67890",
+			Name:      "retry-pod",
+			Labels:    map[string]string{metadataOrgLabel: "openshift", metadataRepoLabel: "origin"},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "build",
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+				corev1.ResourceMemory: *resource.NewQuantity(4<<30, resource.BinarySI),
+			}},
+		}}},
+	}
+	if err := mutator.mutatePod(context.Background(), pod, scheduling.ClassBuilds, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	memory := pod.Spec.Containers[0].Resources.Requests[corev1.ResourceMemory]
+	if memory.Value() != 6<<30 {
+		t.Errorf("expected a 1.5x memory bump, got %d bytes", memory.Value())
+	}
+}
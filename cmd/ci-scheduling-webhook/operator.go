@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	nodev1 "k8s.io/api/node/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// operatorSpec declares the cluster state the webhook depends on. Deploying
+// this state once at bootstrap is not enough - it drifts - so operator mode
+// continuously reconciles it.
+type operatorSpec struct {
+	// WebhookConfiguration describes the MutatingWebhookConfiguration that
+	// routes pod and node admissions to us.
+	WebhookConfiguration *webhookConfigurationSpec `json:"webhookConfiguration,omitempty"`
+	// PriorityClasses are created as specified if missing and updated on drift.
+	PriorityClasses []priorityClassSpec `json:"priorityClasses,omitempty"`
+	// RuntimeClasses are created as specified; classes with tolerations let
+	// pods land on tainted dedicated pools without per-pod injection.
+	RuntimeClasses []runtimeClassSpec `json:"runtimeClasses,omitempty"`
+	// RBAC declares the cluster roles and bindings the webhook's service
+	// accounts need, converged on drift like the rest of the owned state.
+	RBAC *rbacSpec `json:"rbac,omitempty"`
+	// MachineSetLabels are merged into the labels of the named machinesets'
+	// node templates so new nodes join the right pools.
+	MachineSetLabels []machineSetLabelsSpec `json:"machineSetLabels,omitempty"`
+}
+
+type webhookConfigurationSpec struct {
+	Name             string `json:"name"`
+	ServiceNamespace string `json:"serviceNamespace"`
+	ServiceName      string `json:"serviceName"`
+	ServicePort      int32  `json:"servicePort"`
+	CABundleFile     string `json:"caBundleFile,omitempty"`
+	// AdmitProwJobs registers the /prowjobs webhook; it must match the
+	// serving process's --admit-prowjobs flag, which 404s the path when
+	// unset.
+	AdmitProwJobs bool `json:"admitProwJobs,omitempty"`
+}
+
+type priorityClassSpec struct {
+	Name        string `json:"name"`
+	Value       int32  `json:"value"`
+	Description string `json:"description,omitempty"`
+}
+
+type runtimeClassSpec struct {
+	Name        string              `json:"name"`
+	Handler     string              `json:"handler"`
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+}
+
+type rbacSpec struct {
+	ClusterRoles        []rbacv1.ClusterRole        `json:"clusterRoles,omitempty"`
+	ClusterRoleBindings []rbacv1.ClusterRoleBinding `json:"clusterRoleBindings,omitempty"`
+}
+
+type machineSetLabelsSpec struct {
+	Namespace string            `json:"namespace"`
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels"`
+}
+
+func loadOperatorSpec(path string) (*operatorSpec, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read operator spec: %w", err)
+	}
+	spec := &operatorSpec{}
+	if err := yaml.UnmarshalStrict(raw, spec); err != nil {
+		return nil, fmt.Errorf("could not unmarshal operator spec: %w", err)
+	}
+	return spec, nil
+}
+
+// operatorReconciler continuously converges the cluster state the webhook
+// depends on towards the declared spec and records status conditions for
+// each piece of owned state.
+type operatorReconciler struct {
+	logger   *logrus.Entry
+	client   ctrlruntimeclient.Client
+	spec     *operatorSpec
+	interval time.Duration
+
+	// statusNamespace/statusName locate the ConfigMap status conditions are
+	// written to, so operators can inspect reconciliation health with oc.
+	statusNamespace string
+	statusName      string
+
+	conditions []metav1.Condition
+}
+
+func (r *operatorReconciler) run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		r.reconcile(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *operatorReconciler) reconcile(ctx context.Context) {
+	r.conditions = nil
+	r.recordCondition("WebhookConfigurationReconciled", r.reconcileWebhookConfiguration(ctx))
+	r.recordCondition("PriorityClassesReconciled", r.reconcilePriorityClasses(ctx))
+	r.recordCondition("RuntimeClassesReconciled", r.reconcileRuntimeClasses(ctx))
+	r.recordCondition("RBACReconciled", r.reconcileRBAC(ctx))
+	r.recordCondition("MachineSetLabelsReconciled", r.reconcileMachineSetLabels(ctx))
+	if err := r.writeStatus(ctx); err != nil {
+		r.logger.WithError(err).Error("Failed to write status conditions.")
+	}
+}
+
+func (r *operatorReconciler) recordCondition(conditionType string, err error) {
+	condition := metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionTrue,
+		Reason:             "AsDeclared",
+		LastTransitionTime: metav1.Now(),
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ReconcileError"
+		condition.Message = err.Error()
+		r.logger.WithError(err).WithField("condition", conditionType).Error("Failed to reconcile owned cluster state.")
+	}
+	r.conditions = append(r.conditions, condition)
+}
+
+func (r *operatorReconciler) writeStatus(ctx context.Context) error {
+	if r.statusName == "" {
+		return nil
+	}
+	rawConditions, err := yaml.Marshal(r.conditions)
+	if err != nil {
+		return fmt.Errorf("could not marshal conditions: %w", err)
+	}
+	configMap := &corev1.ConfigMap{}
+	name := types.NamespacedName{Namespace: r.statusNamespace, Name: r.statusName}
+	if err := r.client.Get(ctx, name, configMap); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		configMap.Namespace = r.statusNamespace
+		configMap.Name = r.statusName
+		configMap.Data = map[string]string{"conditions": string(rawConditions)}
+		return r.client.Create(ctx, configMap)
+	}
+	updated := configMap.DeepCopy()
+	if updated.Data == nil {
+		updated.Data = map[string]string{}
+	}
+	updated.Data["conditions"] = string(rawConditions)
+	return r.client.Patch(ctx, updated, ctrlruntimeclient.MergeFrom(configMap))
+}
+
+func (r *operatorReconciler) reconcileWebhookConfiguration(ctx context.Context) error {
+	spec := r.spec.WebhookConfiguration
+	if spec == nil {
+		return nil
+	}
+	var caBundle []byte
+	if spec.CABundleFile != "" {
+		var err error
+		if caBundle, err = os.ReadFile(spec.CABundleFile); err != nil {
+			return fmt.Errorf("could not read CA bundle: %w", err)
+		}
+	}
+	failurePolicy := admissionregistrationv1.Ignore
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+	webhook := func(name, path string, rules []admissionregistrationv1.RuleWithOperations) admissionregistrationv1.MutatingWebhook {
+		return admissionregistrationv1.MutatingWebhook{
+			Name:                    name,
+			AdmissionReviewVersions: []string{"v1"},
+			FailurePolicy:           &failurePolicy,
+			SideEffects:             &sideEffects,
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				CABundle: caBundle,
+				Service: &admissionregistrationv1.ServiceReference{
+					Namespace: spec.ServiceNamespace,
+					Name:      spec.ServiceName,
+					Port:      &spec.ServicePort,
+					Path:      &path,
+				},
+			},
+			Rules: rules,
+		}
+	}
+	desired := []admissionregistrationv1.MutatingWebhook{
+		webhook("pods.ci-scheduling-webhook.openshift.io", "/pods", []admissionregistrationv1.RuleWithOperations{{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+			Rule:       admissionregistrationv1.Rule{APIGroups: []string{""}, APIVersions: []string{"v1"}, Resources: []string{"pods"}},
+		}}),
+		webhook("nodes.ci-scheduling-webhook.openshift.io", "/nodes", []admissionregistrationv1.RuleWithOperations{{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+			Rule:       admissionregistrationv1.Rule{APIGroups: []string{""}, APIVersions: []string{"v1"}, Resources: []string{"nodes"}},
+		}}),
+	}
+	if spec.AdmitProwJobs {
+		desired = append(desired, webhook("prowjobs.ci-scheduling-webhook.openshift.io", "/prowjobs", []admissionregistrationv1.RuleWithOperations{{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+			Rule:       admissionregistrationv1.Rule{APIGroups: []string{"prow.k8s.io"}, APIVersions: []string{"v1"}, Resources: []string{"prowjobs"}},
+		}}))
+	}
+	configuration := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	if err := r.client.Get(ctx, types.NamespacedName{Name: spec.Name}, configuration); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		configuration.Name = spec.Name
+		configuration.Webhooks = desired
+		return r.client.Create(ctx, configuration)
+	}
+	updated := configuration.DeepCopy()
+	updated.Webhooks = desired
+	return r.client.Patch(ctx, updated, ctrlruntimeclient.MergeFrom(configuration))
+}
+
+func (r *operatorReconciler) reconcilePriorityClasses(ctx context.Context) error {
+	for _, spec := range r.spec.PriorityClasses {
+		priorityClass := &schedulingv1.PriorityClass{}
+		if err := r.client.Get(ctx, types.NamespacedName{Name: spec.Name}, priorityClass); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+			priorityClass.Name = spec.Name
+			priorityClass.Value = spec.Value
+			priorityClass.Description = spec.Description
+			if err := r.client.Create(ctx, priorityClass); err != nil {
+				return err
+			}
+			continue
+		}
+		// PriorityClass values are immutable; only the description can drift.
+		if priorityClass.Description != spec.Description {
+			updated := priorityClass.DeepCopy()
+			updated.Description = spec.Description
+			if err := r.client.Patch(ctx, updated, ctrlruntimeclient.MergeFrom(priorityClass)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *operatorReconciler) reconcileRuntimeClasses(ctx context.Context) error {
+	for _, spec := range r.spec.RuntimeClasses {
+		runtimeClass := &nodev1.RuntimeClass{}
+		if err := r.client.Get(ctx, types.NamespacedName{Name: spec.Name}, runtimeClass); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+			runtimeClass.Name = spec.Name
+			runtimeClass.Handler = spec.Handler
+			if len(spec.Tolerations) > 0 {
+				runtimeClass.Scheduling = &nodev1.Scheduling{Tolerations: spec.Tolerations}
+			}
+			if err := r.client.Create(ctx, runtimeClass); err != nil {
+				return err
+			}
+			continue
+		}
+		updated := runtimeClass.DeepCopy()
+		if len(spec.Tolerations) > 0 {
+			updated.Scheduling = &nodev1.Scheduling{Tolerations: spec.Tolerations}
+		} else {
+			updated.Scheduling = nil
+		}
+		if !apiequality.Semantic.DeepEqual(runtimeClass.Scheduling, updated.Scheduling) {
+			if err := r.client.Patch(ctx, updated, ctrlruntimeclient.MergeFrom(runtimeClass)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *operatorReconciler) reconcileRBAC(ctx context.Context) error {
+	if r.spec.RBAC == nil {
+		return nil
+	}
+	for i := range r.spec.RBAC.ClusterRoles {
+		desired := &r.spec.RBAC.ClusterRoles[i]
+		existing := &rbacv1.ClusterRole{}
+		if err := r.client.Get(ctx, types.NamespacedName{Name: desired.Name}, existing); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+			if err := r.client.Create(ctx, desired.DeepCopy()); err != nil {
+				return err
+			}
+			continue
+		}
+		if apiequality.Semantic.DeepEqual(existing.Rules, desired.Rules) {
+			continue
+		}
+		updated := existing.DeepCopy()
+		updated.Rules = desired.Rules
+		if err := r.client.Patch(ctx, updated, ctrlruntimeclient.MergeFrom(existing)); err != nil {
+			return err
+		}
+	}
+	for i := range r.spec.RBAC.ClusterRoleBindings {
+		desired := &r.spec.RBAC.ClusterRoleBindings[i]
+		existing := &rbacv1.ClusterRoleBinding{}
+		if err := r.client.Get(ctx, types.NamespacedName{Name: desired.Name}, existing); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+			if err := r.client.Create(ctx, desired.DeepCopy()); err != nil {
+				return err
+			}
+			continue
+		}
+		if apiequality.Semantic.DeepEqual(existing.Subjects, desired.Subjects) && apiequality.Semantic.DeepEqual(existing.RoleRef, desired.RoleRef) {
+			continue
+		}
+		// The roleRef is immutable; recreate on drift there, patch subjects
+		// otherwise.
+		if !apiequality.Semantic.DeepEqual(existing.RoleRef, desired.RoleRef) {
+			if err := r.client.Delete(ctx, existing); err != nil {
+				return err
+			}
+			if err := r.client.Create(ctx, desired.DeepCopy()); err != nil {
+				return err
+			}
+			continue
+		}
+		updated := existing.DeepCopy()
+		updated.Subjects = desired.Subjects
+		if err := r.client.Patch(ctx, updated, ctrlruntimeclient.MergeFrom(existing)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var machineSetGVK = schema.GroupVersionKind{Group: "machine.openshift.io", Version: "v1beta1", Kind: "MachineSet"}
+
+func (r *operatorReconciler) reconcileMachineSetLabels(ctx context.Context) error {
+	for _, spec := range r.spec.MachineSetLabels {
+		machineSet := &unstructured.Unstructured{}
+		machineSet.SetGroupVersionKind(machineSetGVK)
+		if err := r.client.Get(ctx, types.NamespacedName{Namespace: spec.Namespace, Name: spec.Name}, machineSet); err != nil {
+			return fmt.Errorf("could not get machineset %s/%s: %w", spec.Namespace, spec.Name, err)
+		}
+		updated := machineSet.DeepCopy()
+		labels, _, err := unstructured.NestedStringMap(updated.Object, "spec", "template", "spec", "metadata", "labels")
+		if err != nil {
+			return fmt.Errorf("could not read machineset %s/%s node labels: %w", spec.Namespace, spec.Name, err)
+		}
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		changed := false
+		for key, value := range spec.Labels {
+			if labels[key] != value {
+				labels[key] = value
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		if err := unstructured.SetNestedStringMap(updated.Object, labels, "spec", "template", "spec", "metadata", "labels"); err != nil {
+			return fmt.Errorf("could not set machineset %s/%s node labels: %w", spec.Namespace, spec.Name, err)
+		}
+		if err := r.client.Patch(ctx, updated, ctrlruntimeclient.MergeFrom(machineSet)); err != nil {
+			return fmt.Errorf("could not patch machineset %s/%s: %w", spec.Namespace, spec.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	nodev1 "k8s.io/api/node/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestLoadOperatorSpec(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "spec.yaml")
+	if err := os.WriteFile(path, []byte(`priorityClasses:
+- name: ci-high
+  value: 1000
+runtimeClasses:
+- name: ci-tests
+  handler: runc
+  tolerations:
+  - key: ci-workload
+    operator: Equal
+    value: tests
+    effect: NoSchedule
+`), 0o644); err != nil {
+		t.Fatalf("could not write spec: %v", err)
+	}
+	spec, err := loadOperatorSpec(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(spec.PriorityClasses) != 1 || len(spec.RuntimeClasses) != 1 {
+		t.Errorf("spec not loaded as expected: %+v", spec)
+	}
+	if err := os.WriteFile(path, []byte(`unknownField: true`), 0o644); err != nil {
+		t.Fatalf("could not write spec: %v", err)
+	}
+	if _, err := loadOperatorSpec(path); err == nil {
+		t.Error("expected unknown fields to be rejected, got no error")
+	}
+}
+
+func TestOperatorReconcile(t *testing.T) {
+	client := fakectrlruntimeclient.NewClientBuilder().Build()
+	reconciler := &operatorReconciler{
+		logger: logrus.WithField("test", t.Name()),
+		client: client,
+		spec: &operatorSpec{
+			PriorityClasses: []priorityClassSpec{{Name: "ci-high", Value: 1000, Description: "for CI"}},
+			RuntimeClasses:  []runtimeClassSpec{{Name: "ci-tests", Handler: "runc"}},
+			WebhookConfiguration: &webhookConfigurationSpec{
+				Name:             "ci-scheduling-webhook",
+				ServiceNamespace: "ci",
+				ServiceName:      "ci-scheduling-webhook",
+				ServicePort:      443,
+			},
+			RBAC: &rbacSpec{
+				ClusterRoles: []rbacv1.ClusterRole{{
+					ObjectMeta: metav1.ObjectMeta{Name: "ci-scheduling-webhook"},
+					Rules:      []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"get", "list", "watch"}}},
+				}},
+				ClusterRoleBindings: []rbacv1.ClusterRoleBinding{{
+					ObjectMeta: metav1.ObjectMeta{Name: "ci-scheduling-webhook"},
+					Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Namespace: "ci", Name: "ci-scheduling-webhook"}},
+					RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "ci-scheduling-webhook"},
+				}},
+			},
+		},
+		interval:        time.Minute,
+		statusNamespace: "ci",
+		statusName:      "operator-status",
+	}
+	reconciler.reconcile(context.Background())
+
+	priorityClass := &schedulingv1.PriorityClass{}
+	if err := client.Get(context.Background(), types.NamespacedName{Name: "ci-high"}, priorityClass); err != nil {
+		t.Errorf("expected priority class to be created: %v", err)
+	}
+	runtimeClass := &nodev1.RuntimeClass{}
+	if err := client.Get(context.Background(), types.NamespacedName{Name: "ci-tests"}, runtimeClass); err != nil {
+		t.Errorf("expected runtime class to be created: %v", err)
+	}
+	clusterRole := &rbacv1.ClusterRole{}
+	if err := client.Get(context.Background(), types.NamespacedName{Name: "ci-scheduling-webhook"}, clusterRole); err != nil {
+		t.Errorf("expected cluster role to be created: %v", err)
+	}
+	binding := &rbacv1.ClusterRoleBinding{}
+	if err := client.Get(context.Background(), types.NamespacedName{Name: "ci-scheduling-webhook"}, binding); err != nil {
+		t.Errorf("expected cluster role binding to be created: %v", err)
+	}
+	configuration := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	if err := client.Get(context.Background(), types.NamespacedName{Name: "ci-scheduling-webhook"}, configuration); err != nil {
+		t.Fatalf("expected webhook configuration to be created: %v", err)
+	}
+	if len(configuration.Webhooks) != 2 {
+		t.Errorf("expected only the pod and node webhooks without admitProwJobs, got %d", len(configuration.Webhooks))
+	}
+	reconciler.spec.WebhookConfiguration.AdmitProwJobs = true
+	reconciler.reconcile(context.Background())
+	if err := client.Get(context.Background(), types.NamespacedName{Name: "ci-scheduling-webhook"}, configuration); err != nil {
+		t.Fatalf("expected webhook configuration to survive reconciliation: %v", err)
+	}
+	if len(configuration.Webhooks) != 3 {
+		t.Errorf("expected the prowjobs webhook with admitProwJobs, got %d", len(configuration.Webhooks))
+	}
+	status := &corev1.ConfigMap{}
+	if err := client.Get(context.Background(), types.NamespacedName{Namespace: "ci", Name: "operator-status"}, status); err != nil {
+		t.Fatalf("expected status configmap to be created: %v", err)
+	}
+	if status.Data["conditions"] == "" {
+		t.Error("expected status conditions to be recorded")
+	}
+	for _, condition := range reconciler.conditions {
+		if condition.Status != "True" {
+			t.Errorf("expected condition %s to be True: %+v", condition.Type, condition)
+		}
+	}
+}
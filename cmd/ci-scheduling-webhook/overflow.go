@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+// overflowTracker watches the primary test pool's pending queue and, past a
+// threshold, routes newly admitted test pods to the overflow pool until the
+// backlog drains. Activation and deactivation use separate thresholds so the
+// router does not flap at the boundary, and routing overflow pods early gives
+// the autoscaler the pending signal it needs to grow the overflow machineset.
+type overflowTracker struct {
+	logger *logrus.Entry
+	client ctrlruntimeclient.Client
+
+	// activateAt and drainBelow bound the hysteresis window.
+	activateAt int
+	drainBelow int
+
+	// cacheFor bounds how often admissions re-count the queue.
+	cacheFor time.Duration
+
+	lock      sync.Mutex
+	active    bool
+	checkedAt time.Time
+	now       func() time.Time
+}
+
+// pendingTestPods counts classified test pods that have no node yet.
+func (t *overflowTracker) pendingTestPods(ctx context.Context) (int, error) {
+	podList := &corev1.PodList{}
+	if err := t.client.List(ctx, podList); err != nil {
+		return 0, err
+	}
+	pending := 0
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Spec.NodeSelector[scheduling.LabelName] != scheduling.ClassTests {
+			continue
+		}
+		if pod.Spec.NodeName == "" && pod.Status.Phase == corev1.PodPending {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// shouldOverflow reports whether new test pods should route to the overflow
+// pool, re-counting the queue at most every cacheFor.
+func (t *overflowTracker) shouldOverflow(ctx context.Context) bool {
+	if t == nil {
+		return false
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	now := time.Now()
+	if t.now != nil {
+		now = t.now()
+	}
+	if now.Sub(t.checkedAt) < t.cacheFor {
+		return t.active
+	}
+	t.checkedAt = now
+	pending, err := t.pendingTestPods(ctx)
+	if err != nil {
+		t.logger.WithError(err).Warning("Could not count pending test pods, keeping previous overflow state.")
+		return t.active
+	}
+	switch {
+	case !t.active && pending >= t.activateAt:
+		t.logger.Warningf("test pool backlog at %d pending pods, routing new test pods to the overflow pool", pending)
+		t.active = true
+	case t.active && pending < t.drainBelow:
+		t.logger.Infof("test pool backlog drained to %d pending pods, routing test pods to the primary pool again", pending)
+		t.active = false
+	}
+	return t.active
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+func pendingTestPod(name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: name},
+		Spec:       corev1.PodSpec{NodeSelector: map[string]string{scheduling.LabelName: scheduling.ClassTests}},
+		Status:     corev1.PodStatus{Phase: corev1.PodPending},
+	}
+}
+
+func TestOverflowTracker(t *testing.T) {
+	client := fakectrlruntimeclient.NewClientBuilder().Build()
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := &overflowTracker{
+		logger:     logrus.WithField("test", t.Name()),
+		client:     client,
+		activateAt: 3,
+		drainBelow: 2,
+		cacheFor:   time.Second,
+		now:        func() time.Time { return clock },
+	}
+	tick := func() { clock = clock.Add(2 * time.Second) }
+
+	if tracker.shouldOverflow(context.Background()) {
+		t.Error("expected no overflow with an empty queue")
+	}
+	for i := 0; i < 3; i++ {
+		if err := client.Create(context.Background(), pendingTestPod(fmt.Sprintf("pending-%d", i))); err != nil {
+			t.Fatalf("could not create pod: %v", err)
+		}
+	}
+	tick()
+	if !tracker.shouldOverflow(context.Background()) {
+		t.Error("expected overflow once the backlog hits the threshold")
+	}
+	// Dropping to two pending pods is inside the hysteresis window.
+	if err := client.Delete(context.Background(), pendingTestPod("pending-0")); err != nil {
+		t.Fatalf("could not delete pod: %v", err)
+	}
+	tick()
+	if !tracker.shouldOverflow(context.Background()) {
+		t.Error("expected overflow to stay active inside the hysteresis window")
+	}
+	// Draining below the lower threshold deactivates routing.
+	if err := client.Delete(context.Background(), pendingTestPod("pending-1")); err != nil {
+		t.Fatalf("could not delete pod: %v", err)
+	}
+	tick()
+	if tracker.shouldOverflow(context.Background()) {
+		t.Error("expected overflow to deactivate once the backlog drained")
+	}
+	var nilTracker *overflowTracker
+	if nilTracker.shouldOverflow(context.Background()) {
+		t.Error("expected a nil tracker to be inert")
+	}
+	// The cache keeps the last answer inside the TTL without listing.
+	tracker.client = nil // would panic if consulted
+	if got := tracker.shouldOverflow(context.Background()); got {
+		t.Error("expected the cached state to be returned inside the TTL")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	classificationpolicy "github.com/openshift/ci-tools/pkg/api/podclassificationpolicy/v1"
+)
+
+// mutationOverrides collects the per-pod adjustments resolved from tenant and
+// classification policies, applied on top of the class defaults.
+type mutationOverrides struct {
+	priorityClassName string
+	shrinkExempt      bool
+	shrinkFactor      *float64
+	tolerations       []corev1.Toleration
+}
+
+// classificationPolicyResolver matches pods against PodClassificationPolicy
+// resources through the cached client. Policies are evaluated in name order
+// and the first match wins, so ordering is deterministic and auditable.
+type classificationPolicyResolver struct {
+	client ctrlruntimeclient.Client
+}
+
+func matches(policy *classificationpolicy.PodClassificationPolicySpec, pod *corev1.Pod) bool {
+	if policy.Match.NamePrefix != "" && !strings.HasPrefix(pod.Name, policy.Match.NamePrefix) {
+		return false
+	}
+	if policy.Match.NamespacePrefix != "" && !strings.HasPrefix(pod.Namespace, policy.Match.NamespacePrefix) {
+		return false
+	}
+	for key, value := range policy.Match.Labels {
+		if pod.Labels[key] != value {
+			return false
+		}
+	}
+	return policy.Match.NamePrefix != "" || policy.Match.NamespacePrefix != "" || len(policy.Match.Labels) > 0
+}
+
+func (r *classificationPolicyResolver) policyFor(ctx context.Context, pod *corev1.Pod) (*classificationpolicy.PodClassificationPolicy, error) {
+	if r == nil {
+		return nil, nil
+	}
+	policyList := &classificationpolicy.PodClassificationPolicyList{}
+	if err := r.client.List(ctx, policyList); err != nil {
+		return nil, err
+	}
+	sort.Slice(policyList.Items, func(i, j int) bool {
+		return policyList.Items[i].Name < policyList.Items[j].Name
+	})
+	for i := range policyList.Items {
+		if matches(&policyList.Items[i].Spec, pod) {
+			return &policyList.Items[i], nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	classificationpolicy "github.com/openshift/ci-tools/pkg/api/podclassificationpolicy/v1"
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+func TestClassificationPolicyResolver(t *testing.T) {
+	factor := 0.9
+	byName := &classificationpolicy.PodClassificationPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "a-by-name"},
+		Spec: classificationpolicy.PodClassificationPolicySpec{
+			Match:           classificationpolicy.PodMatcher{NamePrefix: "e2e-"},
+			PodClass:        scheduling.ClassLongTests,
+			ShrinkCPUFactor: &factor,
+		},
+	}
+	byLabel := &classificationpolicy.PodClassificationPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "b-by-label"},
+		Spec: classificationpolicy.PodClassificationPolicySpec{
+			Match:    classificationpolicy.PodMatcher{Labels: map[string]string{"app": "mirror"}},
+			PodClass: scheduling.ClassArtifacts,
+		},
+	}
+	empty := &classificationpolicy.PodClassificationPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "c-matches-nothing"},
+		Spec:       classificationpolicy.PodClassificationPolicySpec{PodClass: scheduling.ClassTests},
+	}
+	resolver := &classificationPolicyResolver{
+		client: fakectrlruntimeclient.NewClientBuilder().WithObjects(byName, byLabel, empty).Build(),
+	}
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "e2e-aws"}}
+	if policy, err := resolver.policyFor(context.Background(), pod); err != nil || policy == nil || policy.Name != "a-by-name" {
+		t.Errorf("expected the name-prefix policy to match, got %+v (%v)", policy, err)
+	}
+	labeled := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "other", Labels: map[string]string{"app": "mirror"}}}
+	if policy, err := resolver.policyFor(context.Background(), labeled); err != nil || policy == nil || policy.Name != "b-by-label" {
+		t.Errorf("expected the label policy to match, got %+v (%v)", policy, err)
+	}
+	unmatched := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "unmatched"}}
+	if policy, err := resolver.policyFor(context.Background(), unmatched); err != nil || policy != nil {
+		t.Errorf("expected an empty matcher to never match, got %+v (%v)", policy, err)
+	}
+}
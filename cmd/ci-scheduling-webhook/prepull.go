@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+// imageUsage counts, per pod class, how often images are admitted, feeding
+// the pre-pull orchestrator.
+type imageUsage struct {
+	lock   sync.Mutex
+	counts map[string]map[string]int
+}
+
+func newImageUsage() *imageUsage {
+	return &imageUsage{counts: map[string]map[string]int{}}
+}
+
+// maxTrackedImages bounds the per-class image map; under pressure all
+// counts are halved and zeroes dropped, an exponential decay that keeps the
+// popular images on top while old one-off tags fall out.
+const maxTrackedImages = 2048
+
+func (u *imageUsage) record(podClass string, pod *corev1.Pod) {
+	if u == nil {
+		return
+	}
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	classCounts := u.counts[podClass]
+	if classCounts == nil {
+		classCounts = map[string]int{}
+		u.counts[podClass] = classCounts
+	}
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			if container.Image != "" {
+				classCounts[container.Image]++
+			}
+		}
+	}
+	if len(classCounts) > maxTrackedImages {
+		for image, count := range classCounts {
+			if count /= 2; count == 0 {
+				delete(classCounts, image)
+			} else {
+				classCounts[image] = count
+			}
+		}
+	}
+}
+
+// top returns the n most admitted images of a class, most used first.
+func (u *imageUsage) top(podClass string, n int) []string {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	type usage struct {
+		image string
+		count int
+	}
+	var usages []usage
+	for image, count := range u.counts[podClass] {
+		usages = append(usages, usage{image: image, count: count})
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].count != usages[j].count {
+			return usages[i].count > usages[j].count
+		}
+		return usages[i].image < usages[j].image
+	})
+	if len(usages) > n {
+		usages = usages[:n]
+	}
+	var images []string
+	for _, entry := range usages {
+		images = append(images, entry.image)
+	}
+	return images
+}
+
+// prePullReconciler maintains one DaemonSet per pod class that pre-pulls the
+// class's most used CI images onto every pool node. Combined with
+// image-locality hints this cuts cold-start times substantially.
+type prePullReconciler struct {
+	logger     *logrus.Entry
+	client     ctrlruntimeclient.Client
+	usage      *imageUsage
+	namespace  string
+	topN       int
+	pauseImage string
+	interval   time.Duration
+}
+
+func (r *prePullReconciler) run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, podClass := range scheduling.PodClasses {
+				if err := r.reconcileClass(ctx, podClass); err != nil {
+					r.logger.WithError(err).WithField("class", podClass).Error("Failed to reconcile pre-pull DaemonSet.")
+				}
+			}
+		}
+	}
+}
+
+func (r *prePullReconciler) reconcileClass(ctx context.Context, podClass string) error {
+	images := r.usage.top(podClass, r.topN)
+	name := scheduling.PrePullAppPrefix + podClass
+	if len(images) == 0 {
+		// Nothing admitted yet; leave whatever exists in place.
+		return nil
+	}
+	desired := r.daemonSet(name, podClass, images)
+	existing := &appsv1.DaemonSet{}
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: r.namespace, Name: name}, existing); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		r.logger.WithField("class", podClass).Debugf("creating pre-pull DaemonSet for %d images", len(images))
+		return r.client.Create(ctx, desired)
+	}
+	updated := existing.DeepCopy()
+	updated.Spec = desired.Spec
+	return r.client.Patch(ctx, updated, ctrlruntimeclient.MergeFrom(existing))
+}
+
+func (r *prePullReconciler) daemonSet(name, podClass string, images []string) *appsv1.DaemonSet {
+	labels := map[string]string{"app": name}
+	var pullers []corev1.Container
+	for i, image := range images {
+		pullers = append(pullers, corev1.Container{
+			Name:    fmt.Sprintf("pull-%d", i),
+			Image:   image,
+			Command: []string{"/bin/true"},
+		})
+	}
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: r.namespace, Name: name, Labels: labels},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					NodeSelector: map[string]string{scheduling.LabelName: podClass},
+					Tolerations: []corev1.Toleration{{
+						Key:      scheduling.LabelName,
+						Operator: corev1.TolerationOpEqual,
+						Value:    podClass,
+						Effect:   corev1.TaintEffectNoSchedule,
+					}},
+					// The pullers run once at pod start; the pause container
+					// keeps the pod alive so the images stay referenced.
+					InitContainers: pullers,
+					Containers: []corev1.Container{{
+						Name:  "pause",
+						Image: r.pauseImage,
+					}},
+				},
+			},
+		},
+	}
+}
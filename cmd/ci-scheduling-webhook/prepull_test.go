@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+func usagePod(images ...string) *corev1.Pod {
+	pod := &corev1.Pod{}
+	for i, image := range images {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: fmt.Sprintf("c-%d", i), Image: image})
+	}
+	return pod
+}
+
+func TestImageUsageTop(t *testing.T) {
+	usage := newImageUsage()
+	for i := 0; i < 3; i++ {
+		usage.record(scheduling.ClassTests, usagePod("popular"))
+	}
+	usage.record(scheduling.ClassTests, usagePod("rare", "common"))
+	usage.record(scheduling.ClassTests, usagePod("common"))
+	usage.record(scheduling.ClassBuilds, usagePod("builder"))
+
+	if diff := cmp.Diff([]string{"popular", "common"}, usage.top(scheduling.ClassTests, 2)); diff != "" {
+		t.Errorf("incorrect top images: %v", diff)
+	}
+	if diff := cmp.Diff([]string{"builder"}, usage.top(scheduling.ClassBuilds, 5)); diff != "" {
+		t.Errorf("incorrect top images for builds: %v", diff)
+	}
+	if images := usage.top(scheduling.ClassProwjobs, 5); images != nil {
+		t.Errorf("expected no images for an unseen class, got %v", images)
+	}
+}
+
+func TestPrePullReconciler(t *testing.T) {
+	client := fakectrlruntimeclient.NewClientBuilder().Build()
+	usage := newImageUsage()
+	usage.record(scheduling.ClassTests, usagePod("popular", "other"))
+	reconciler := &prePullReconciler{
+		logger:     logrus.WithField("test", t.Name()),
+		client:     client,
+		usage:      usage,
+		namespace:  "ci",
+		topN:       1,
+		pauseImage: "pause:latest",
+	}
+	if err := reconciler.reconcileClass(context.Background(), scheduling.ClassTests); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	daemonSet := &appsv1.DaemonSet{}
+	if err := client.Get(context.Background(), types.NamespacedName{Namespace: "ci", Name: "ci-image-prepull-tests"}, daemonSet); err != nil {
+		t.Fatalf("expected DaemonSet to be created: %v", err)
+	}
+	spec := daemonSet.Spec.Template.Spec
+	if len(spec.InitContainers) != 1 || spec.InitContainers[0].Image != "other" && spec.InitContainers[0].Image != "popular" {
+		t.Errorf("expected one pre-pull container for the top image, got %+v", spec.InitContainers)
+	}
+	if spec.NodeSelector[scheduling.LabelName] != scheduling.ClassTests {
+		t.Errorf("expected the DaemonSet to target the class pool, got %+v", spec.NodeSelector)
+	}
+	// A class with no admissions leaves no DaemonSet behind.
+	if err := reconciler.reconcileClass(context.Background(), scheduling.ClassBuilds); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := client.Get(context.Background(), types.NamespacedName{Namespace: "ci", Name: "ci-image-prepull-builds"}, &appsv1.DaemonSet{}); err == nil {
+		t.Error("expected no DaemonSet for a class without admissions")
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	pjapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+// prowJobMutator stamps scheduling-class hints onto ProwJob objects before
+// their pods exist. Plank copies ProwJob labels onto the pods it creates, so
+// downstream pod admission becomes a cheap label read rather than heuristic
+// classification.
+type prowJobMutator struct {
+	logger     *logrus.Entry
+	decoder    *admission.Decoder
+	classifier scheduling.Classifier
+	// shrinkFactors and containerShrinkRules mirror the pod admission
+	// configuration, so ProwJob pod specs carry their class's adjusted
+	// requests from birth instead of waiting for pod admission to rewrite
+	// them.
+	shrinkFactors        map[string]float64
+	initShrinkFactor     float64
+	containerShrinkRules []containerShrinkRule
+}
+
+func (m *prowJobMutator) Handle(_ context.Context, req admission.Request) admission.Response {
+	prowJob := &pjapi.ProwJob{}
+	if err := m.decoder.Decode(req, prowJob); err != nil {
+		logrus.WithError(err).Error("Failed to decode raw object as ProwJob.")
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	logger := m.logger.WithField("name", prowJob.Name).WithField("job", prowJob.Spec.Job)
+
+	podClass := m.classify(prowJob)
+	if podClass == scheduling.ClassNone {
+		return admission.Allowed("ignoring ProwJob outside of CI workload classes")
+	}
+	if prowJob.Labels == nil {
+		prowJob.Labels = map[string]string{}
+	}
+	prowJob.Labels[scheduling.LabelName] = podClass
+	logger.WithField("class", podClass).Debug("stamped scheduling class onto ProwJob")
+	m.applyResourceOverrides(prowJob, podClass, logger)
+
+	marshaled, err := json.Marshal(prowJob)
+	if err != nil {
+		logger.WithError(err).Error("Could not marshal mutated ProwJob.")
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	response := admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+	response.Patches = scheduling.MinimizePatches(req.Object.Raw, response.Patches)
+	// we need these to be deterministically ordered for testing
+	sort.Slice(response.Patches, func(i, j int) bool {
+		if response.Patches[i].Operation != response.Patches[j].Operation {
+			return response.Patches[i].Operation < response.Patches[j].Operation
+		}
+		return response.Patches[i].Path < response.Patches[j].Path
+	})
+	return response
+}
+
+// applyResourceOverrides shrinks the CPU requests of the ProwJob's embedded
+// pod spec by the class factor and marks the job as already shrunk. Plank
+// copies ProwJob annotations onto the pods it creates, so pod admission sees
+// the applied-shrink-factor marker and does not compound the reduction.
+func (m *prowJobMutator) applyResourceOverrides(prowJob *pjapi.ProwJob, podClass string, logger *logrus.Entry) {
+	if prowJob.Spec.PodSpec == nil {
+		return
+	}
+	if _, alreadyShrunk := prowJob.Annotations[shrinkFactorAnnotation]; alreadyShrunk {
+		return
+	}
+	factor, configured := m.shrinkFactors[podClass]
+	if !configured || factor >= 1.0 {
+		return
+	}
+	initFactor := factor
+	if m.initShrinkFactor > 0 {
+		initFactor = m.initShrinkFactor
+	}
+	spec := prowJob.Spec.PodSpec
+	saved := shrinkContainers(spec.InitContainers, initFactor, m.containerShrinkRules, nil, logger)
+	saved += shrinkContainers(spec.Containers, factor, m.containerShrinkRules, nil, logger)
+	if saved == 0 {
+		return
+	}
+	if prowJob.Annotations == nil {
+		prowJob.Annotations = map[string]string{}
+	}
+	prowJob.Annotations[shrinkFactorAnnotation] = strconv.FormatFloat(factor, 'f', -1, 64)
+	logger.WithField("class", podClass).Debugf("shrank ProwJob pod spec CPU requests by %.2f, saving %dm", factor, saved)
+}
+
+// classify runs the pod classifier against a synthetic pod carrying the
+// ProwJob's labels and pod spec, so ProwJobs and raw pods always agree.
+func (m *prowJobMutator) classify(prowJob *pjapi.ProwJob) string {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   prowJob.Spec.Namespace,
+			Labels:      map[string]string{"created-by-prow": "true", "prow.k8s.io/job": prowJob.Spec.Job},
+			Annotations: prowJob.Annotations,
+		},
+	}
+	for key, value := range prowJob.Labels {
+		pod.Labels[key] = value
+	}
+	if prowJob.Spec.PodSpec != nil {
+		pod.Spec = *prowJob.Spec.PodSpec.DeepCopy()
+	}
+	return m.classifier.Classify(pod)
+}
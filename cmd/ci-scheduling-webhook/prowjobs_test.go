@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	pjapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+func TestRefineProwJobClass(t *testing.T) {
+	stamped := &pjapi.ProwJob{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "stamped-job", Labels: map[string]string{scheduling.LabelName: scheduling.ClassLongTests}},
+		Spec:       pjapi.ProwJobSpec{Type: pjapi.PeriodicJob},
+	}
+	periodic := &pjapi.ProwJob{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "periodic-job"},
+		Spec:       pjapi.ProwJobSpec{Type: pjapi.PeriodicJob},
+	}
+	testScheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(testScheme); err != nil {
+		t.Fatalf("could not build scheme: %v", err)
+	}
+	if err := pjapi.AddToScheme(testScheme); err != nil {
+		t.Fatalf("could not add ProwJob types: %v", err)
+	}
+	mutator := &podMutator{
+		client:             fakectrlruntimeclient.NewClientBuilder().WithScheme(testScheme).WithObjects(stamped, periodic).Build(),
+		prowJobNamespace:   "ci",
+		prowJobTypeClasses: map[string]string{string(pjapi.PeriodicJob): scheduling.ClassOverflow},
+	}
+	pod := func(name string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: name}}
+	}
+	if class, reason := mutator.refineProwJobClass(context.Background(), pod("stamped-job")); class != scheduling.ClassLongTests || reason == "" {
+		t.Errorf("expected the stamped ProwJob label to win, got %q (%q)", class, reason)
+	}
+	if class, _ := mutator.refineProwJobClass(context.Background(), pod("periodic-job")); class != scheduling.ClassOverflow {
+		t.Errorf("expected the type mapping to apply, got %q", class)
+	}
+	if class, _ := mutator.refineProwJobClass(context.Background(), pod("no-such-job")); class != "" {
+		t.Errorf("expected missing ProwJobs to keep the generic class, got %q", class)
+	}
+}
+
+func TestProwJobMutator(t *testing.T) {
+	webhookScheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(webhookScheme); err != nil {
+		t.Fatalf("could not build scheme: %v", err)
+	}
+	if err := pjapi.AddToScheme(webhookScheme); err != nil {
+		t.Fatalf("could not add ProwJob types: %v", err)
+	}
+	mutator := &prowJobMutator{
+		logger:        logrus.WithField("test", t.Name()),
+		decoder:       admission.NewDecoder(webhookScheme),
+		classifier:    scheduling.Classifier{IOIntensiveJobs: map[string]bool{"periodic-io-heavy": true}},
+		shrinkFactors: map[string]float64{scheduling.ClassIOIntensive: 0.5},
+	}
+	prowJob := &pjapi.ProwJob{
+		TypeMeta:   metav1.TypeMeta{Kind: "ProwJob", APIVersion: "prow.k8s.io/v1"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "abc123"},
+		Spec: pjapi.ProwJobSpec{
+			Job:       "periodic-io-heavy",
+			Namespace: "ci",
+			PodSpec: &corev1.PodSpec{Containers: []corev1.Container{{
+				Name: "test", Image: "image",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}},
+			}}},
+		},
+	}
+	raw, err := json.Marshal(prowJob)
+	if err != nil {
+		t.Fatalf("could not marshal ProwJob: %v", err)
+	}
+	response := mutator.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		UID:    "uid",
+		Kind:   metav1.GroupVersionKind{Group: "prow.k8s.io", Version: "v1", Kind: "ProwJob"},
+		Object: runtime.RawExtension{Raw: raw},
+	}})
+	if !response.Allowed {
+		t.Fatalf("expected admission, got %+v", response.Result)
+	}
+	var stamped, shrunk, marked bool
+	for _, patch := range response.Patches {
+		if patch.Path == "/metadata/labels" || patch.Path == "/metadata/labels/"+scheduling.LabelName {
+			stamped = true
+		}
+		if strings.HasPrefix(patch.Path, "/spec/pod_spec/containers/0/resources/requests") {
+			shrunk = true
+		}
+		if strings.HasPrefix(patch.Path, "/metadata/annotations") {
+			marked = true
+		}
+	}
+	if !stamped {
+		t.Errorf("expected the class label to be stamped, got patches %+v", response.Patches)
+	}
+	if !shrunk || !marked {
+		t.Errorf("expected the pod spec CPU to be shrunk and the shrink factor recorded, got patches %+v", response.Patches)
+	}
+
+	// A pod carrying the stamped label classifies without heuristics.
+	classifier := &scheduling.Classifier{}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "other",
+		Labels:    map[string]string{scheduling.LabelName: scheduling.ClassIOIntensive},
+	}}
+	if got := classifier.Classify(pod); got != scheduling.ClassIOIntensive {
+		t.Errorf("expected stamped pods to keep their class, got %q", got)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// retryTracker counts recent failures per job, so retried runs get
+// escalating resource boosts: many flakes are resource starvation in
+// disguise, and the retry is the cheapest place to test that theory.
+// Failures age out after the window, so one bad afternoon does not boost
+// every future run of the job until the process restarts.
+type retryTracker struct {
+	lock sync.Mutex
+	// failures holds the observation times of failed pods per job key.
+	failures map[string][]time.Time
+	// seen deduplicates failed pod UIDs, with timestamps so entries age out
+	// alongside the failures they guard.
+	seen map[string]time.Time
+	// window bounds how long a failure counts as recent; step and cap shape
+	// the escalation per failure.
+	window time.Duration
+	step   float64
+	cap    float64
+	// now stands in for time.Now in tests.
+	now func() time.Time
+}
+
+func newRetryTracker(window time.Duration, step, cap float64) *retryTracker {
+	return &retryTracker{failures: map[string][]time.Time{}, seen: map[string]time.Time{}, window: window, step: step, cap: cap}
+}
+
+// boostFactor returns the request multiplier for a job: one step more per
+// failure inside the window, bounded by the cap.
+func (t *retryTracker) boostFactor(job string) float64 {
+	if t == nil || job == "" {
+		return 1.0
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	factor := 1.0 + t.step*float64(t.recentLocked(job))
+	if factor > t.cap {
+		factor = t.cap
+	}
+	return factor
+}
+
+// recentLocked prunes expired failures of the job and returns how many
+// remain; the caller holds the lock.
+func (t *retryTracker) recentLocked(job string) int {
+	now := time.Now()
+	if t.now != nil {
+		now = t.now()
+	}
+	var recent []time.Time
+	for _, observed := range t.failures[job] {
+		if now.Sub(observed) <= t.window {
+			recent = append(recent, observed)
+		}
+	}
+	if len(recent) == 0 {
+		delete(t.failures, job)
+	} else {
+		t.failures[job] = recent
+	}
+	return len(recent)
+}
+
+func (t *retryTracker) record(job, uid string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	now := time.Now()
+	if t.now != nil {
+		now = t.now()
+	}
+	for entry, observed := range t.seen {
+		if now.Sub(observed) > 2*t.window {
+			delete(t.seen, entry)
+		}
+	}
+	if _, counted := t.seen[uid]; counted {
+		return
+	}
+	t.seen[uid] = now
+	t.failures[job] = append(t.failures[job], now)
+}
+
+// retryWatcher feeds the tracker from failed pods in CI namespaces.
+type retryWatcher struct {
+	logger   *logrus.Entry
+	client   ctrlruntimeclient.Client
+	tracker  *retryTracker
+	interval time.Duration
+}
+
+func (w *retryWatcher) run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.scan(ctx); err != nil {
+				w.logger.WithError(err).Error("Failed to scan for failed job pods.")
+			}
+		}
+	}
+}
+
+func (w *retryWatcher) scan(ctx context.Context) error {
+	podList := &corev1.PodList{}
+	if err := w.client.List(ctx, podList); err != nil {
+		return err
+	}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Status.Phase != corev1.PodFailed {
+			continue
+		}
+		if !strings.HasPrefix(pod.Namespace, "ci-op-") && !strings.HasPrefix(pod.Namespace, "ci-ln-") && pod.Labels["created-by-prow"] != "true" {
+			continue
+		}
+		job := pod.Labels["prow.k8s.io/job"]
+		if job == "" {
+			continue
+		}
+		w.tracker.record(job, string(pod.UID))
+	}
+	return nil
+}
+
+// boostRetryRequests escalates CPU and memory requests by the given factor.
+// The caller exempts boosted pods from CPU shrinking, so the boost applies
+// to the declared values rather than to an already-shrunk request.
+func (m *podMutator) boostRetryRequests(pod *corev1.Pod, factor float64, logger *logrus.Entry) {
+	if factor <= 1.0 {
+		return
+	}
+	boost := func(containers []corev1.Container) {
+		for i := range containers {
+			requests := containers[i].Resources.Requests
+			for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+				current, has := requests[name]
+				if !has {
+					continue
+				}
+				if name == corev1.ResourceCPU {
+					requests[name] = *resource.NewMilliQuantity(int64(float64(current.MilliValue())*factor), resource.DecimalSI)
+				} else {
+					requests[name] = *resource.NewQuantity(int64(float64(current.Value())*factor), resource.BinarySI)
+				}
+			}
+		}
+	}
+	boost(pod.Spec.InitContainers)
+	boost(pod.Spec.Containers)
+	logger.Debug(fmt.Sprintf("boosted requests by %.2fx after recent failures of the job", factor))
+}
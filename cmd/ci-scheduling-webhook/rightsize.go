@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// usageP95 is the historical P95 usage of one (repo, container) workload.
+type usageP95 struct {
+	CPUMilliP95    int64 `json:"cpuMilliP95,omitempty"`
+	MemoryBytesP95 int64 `json:"memoryBytesP95,omitempty"`
+}
+
+// usageProfiler serves historical usage keyed by "org/repo/container",
+// loaded from a profile file produced out-of-band from Prometheus data (the
+// pod-scaler producer pipeline emits compatible aggregates). The file is
+// re-read when its modification time changes.
+type usageProfiler struct {
+	logger *logrus.Entry
+	path   string
+
+	lock    sync.Mutex
+	modTime time.Time
+	profile map[string]usageP95
+}
+
+func (p *usageProfiler) lookup(org, repo, container string) (usageP95, bool) {
+	if p == nil || org == "" || repo == "" {
+		return usageP95{}, false
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return usageP95{}, false
+	}
+	if !info.ModTime().Equal(p.modTime) {
+		raw, err := os.ReadFile(p.path)
+		if err != nil {
+			p.logger.WithError(err).Warning("Could not read usage profile.")
+			return usageP95{}, false
+		}
+		profile := map[string]usageP95{}
+		if err := json.Unmarshal(raw, &profile); err != nil {
+			p.logger.WithError(err).Warning("Could not parse usage profile, keeping the previous one.")
+		} else {
+			p.profile = profile
+			p.modTime = info.ModTime()
+		}
+	}
+	usage, found := p.profile[fmt.Sprintf("%s/%s/%s", org, repo, container)]
+	return usage, found
+}
+
+// rightsizeRequests replaces declared requests with P95 historical usage
+// where a profile exists, which beats any blunt multiplication factor.
+// Returns the names of containers that were right-sized, so the factor-based
+// shrink leaves them alone.
+func (m *podMutator) rightsizeRequests(pod *corev1.Pod, logger *logrus.Entry) map[string]bool {
+	if m.usageProfile == nil {
+		return nil
+	}
+	org, repo := pod.Labels[metadataOrgLabel], pod.Labels[metadataRepoLabel]
+	rightsized := map[string]bool{}
+	size := func(containers []corev1.Container) {
+		for i := range containers {
+			usage, found := m.usageProfile.lookup(org, repo, containers[i].Name)
+			if !found {
+				continue
+			}
+			requests := containers[i].Resources.Requests
+			if requests == nil {
+				requests = corev1.ResourceList{}
+				containers[i].Resources.Requests = requests
+			}
+			if usage.CPUMilliP95 > 0 {
+				requests[corev1.ResourceCPU] = *resource.NewMilliQuantity(usage.CPUMilliP95, resource.DecimalSI)
+			}
+			if usage.MemoryBytesP95 > 0 {
+				requests[corev1.ResourceMemory] = *resource.NewQuantity(usage.MemoryBytesP95, resource.BinarySI)
+			}
+			logger.Debugf("right-sized container %s from historical P95 usage", containers[i].Name)
+			rightsized[containers[i].Name] = true
+		}
+	}
+	size(pod.Spec.InitContainers)
+	size(pod.Spec.Containers)
+	return rightsized
+}
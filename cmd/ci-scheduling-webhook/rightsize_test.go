@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+func TestRightsizeRequests(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	if err := os.WriteFile(path, []byte(`{"openshift/origin/test": {"cpuMilliP95": 1500, "memoryBytesP95": 2147483648}}`), 0o644); err != nil {
+		t.Fatalf("could not write profile: %v", err)
+	}
+	mutator := &podMutator{
+		prioritization:    &scheduling.Prioritization{Client: fakectrlruntimeclient.NewClientBuilder().Build()},
+		avoidanceMode:     avoidanceModeTaints,
+		shrinkCPURequests: map[string]float64{scheduling.ClassTests: 0.5},
+		usageProfile:      &usageProfiler{logger: logrus.WithField("test", t.Name()), path: path},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ci-op-12345",
+			Name:      "pod",
+			Labels:    map[string]string{metadataOrgLabel: "openshift", metadataRepoLabel: "origin"},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{
+				Name: "test",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceCPU: resource.MustParse("4"),
+				}},
+			},
+			{
+				Name: "unprofiled",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceCPU: resource.MustParse("4"),
+				}},
+			},
+		}},
+	}
+	if err := mutator.mutatePod(context.Background(), pod, scheduling.ClassTests, nil, nil, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	profiled := pod.Spec.Containers[0].Resources.Requests
+	if cpu := profiled.Cpu().String(); cpu != "1500m" {
+		t.Errorf("expected the profiled container to get the P95 CPU, got %s", cpu)
+	}
+	if memory := profiled.Memory().String(); memory != "2Gi" {
+		t.Errorf("expected the profiled container to get the P95 memory, got %s", memory)
+	}
+	if cpu := pod.Spec.Containers[1].Resources.Requests.Cpu().String(); cpu != "2" {
+		t.Errorf("expected the unprofiled container to keep the factor-based shrink, got %s", cpu)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	nodev1 "k8s.io/api/node/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+// runtimeClassReconciler creates and keeps in sync the per-class
+// ci-scheduler-runtime-<class> RuntimeClass objects from the webhook's
+// configuration, so deploying a new pod class no longer requires
+// hand-managed cluster objects.
+type runtimeClassReconciler struct {
+	logger   *logrus.Entry
+	client   ctrlruntimeclient.Client
+	handler  string
+	overhead corev1.ResourceList
+	interval time.Duration
+}
+
+func (r *runtimeClassReconciler) run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		for _, podClass := range scheduling.PodClasses {
+			if err := r.reconcileClass(ctx, podClass); err != nil {
+				r.logger.WithError(err).WithField("class", podClass).Error("Failed to reconcile RuntimeClass.")
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *runtimeClassReconciler) desired(podClass string) *nodev1.RuntimeClass {
+	runtimeClass := &nodev1.RuntimeClass{
+		ObjectMeta: metav1.ObjectMeta{Name: runtimeClassPrefix + podClass},
+		Handler:    r.handler,
+		Scheduling: &nodev1.Scheduling{
+			NodeSelector: map[string]string{scheduling.LabelName: podClass},
+			Tolerations: []corev1.Toleration{{
+				Key:      scheduling.LabelName,
+				Operator: corev1.TolerationOpEqual,
+				Value:    podClass,
+				Effect:   corev1.TaintEffectNoSchedule,
+			}},
+		},
+	}
+	if len(r.overhead) > 0 {
+		runtimeClass.Overhead = &nodev1.Overhead{PodFixed: r.overhead}
+	}
+	return runtimeClass
+}
+
+func (r *runtimeClassReconciler) reconcileClass(ctx context.Context, podClass string) error {
+	desired := r.desired(podClass)
+	existing := &nodev1.RuntimeClass{}
+	if err := r.client.Get(ctx, types.NamespacedName{Name: desired.Name}, existing); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		r.logger.WithField("class", podClass).Debug("creating RuntimeClass")
+		return r.client.Create(ctx, desired)
+	}
+	if existing.Handler == desired.Handler &&
+		apiequality.Semantic.DeepEqual(existing.Scheduling, desired.Scheduling) &&
+		apiequality.Semantic.DeepEqual(existing.Overhead, desired.Overhead) {
+		return nil
+	}
+	// The handler is immutable; recreate on drift there, patch otherwise.
+	if existing.Handler != desired.Handler {
+		if err := r.client.Delete(ctx, existing); err != nil {
+			return err
+		}
+		return r.client.Create(ctx, desired)
+	}
+	updated := existing.DeepCopy()
+	updated.Scheduling = desired.Scheduling
+	updated.Overhead = desired.Overhead
+	return r.client.Patch(ctx, updated, ctrlruntimeclient.MergeFrom(existing))
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+// scaleUp bumps MachineSet replicas from the Pending queue per pod class, so
+// spikes in presubmits don't wait for the autoscaler's slow reaction chain
+// (unschedulable pod -> simulation -> scale-up).
+type scaleUp struct {
+	logger         *logrus.Entry
+	client         ctrlruntimeclient.Client
+	prioritization *scheduling.Prioritization
+	interval       time.Duration
+
+	// machineSets maps a pod class to its namespace/name MachineSet.
+	machineSets map[string]string
+	// maxReplicas bounds every managed MachineSet.
+	maxReplicas int64
+}
+
+func (s *scaleUp) run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for podClass, machineSet := range s.machineSets {
+				if err := s.reconcileClass(ctx, podClass, machineSet); err != nil {
+					s.logger.WithError(err).WithField("class", podClass).Error("Failed to scale up for pending pods.")
+				}
+			}
+		}
+	}
+}
+
+// podRequests sums the adjusted CPU and memory requests of a pod. Init
+// containers run sequentially, so only the larger of any single init
+// container and the container sum counts.
+func podRequests(pod *corev1.Pod) (cpuMilli, memoryBytes int64) {
+	for _, container := range pod.Spec.Containers {
+		cpuMilli += container.Resources.Requests.Cpu().MilliValue()
+		memoryBytes += container.Resources.Requests.Memory().Value()
+	}
+	for _, container := range pod.Spec.InitContainers {
+		if cpu := container.Resources.Requests.Cpu().MilliValue(); cpu > cpuMilli {
+			cpuMilli = cpu
+		}
+		if memory := container.Resources.Requests.Memory().Value(); memory > memoryBytes {
+			memoryBytes = memory
+		}
+	}
+	return cpuMilli, memoryBytes
+}
+
+// neededNodes estimates how many additional nodes the pending queue needs
+// from its aggregate adjusted requests against per-node allocatable - pod
+// slots alone would let a queue of huge builds "fit" into one node and
+// never trigger a scale-up. Free capacity of the existing pool (allocatable
+// minus the requests of bound pods) absorbs demand first.
+func neededNodes(utilization []scheduling.NodeUtilization, pendingCPU, pendingMemory, activeCPU, activeMemory int64) int {
+	if pendingCPU == 0 && pendingMemory == 0 {
+		return 0
+	}
+	var totalCPU, totalMemory, perNodeCPU, perNodeMemory int64
+	for _, entry := range utilization {
+		if entry.Node.Spec.Unschedulable {
+			continue
+		}
+		cpu := entry.Node.Status.Allocatable.Cpu().MilliValue()
+		memory := entry.Node.Status.Allocatable.Memory().Value()
+		totalCPU += cpu
+		totalMemory += memory
+		if cpu > perNodeCPU {
+			perNodeCPU = cpu
+		}
+		if memory > perNodeMemory {
+			perNodeMemory = memory
+		}
+	}
+	if perNodeCPU == 0 {
+		// An empty pool: size conservatively for a typical worker.
+		perNodeCPU = 8000
+	}
+	if perNodeMemory == 0 {
+		perNodeMemory = 32 << 30
+	}
+	needed := 0
+	if demand := pendingCPU - (totalCPU - activeCPU); demand > 0 {
+		needed = int((demand + perNodeCPU - 1) / perNodeCPU)
+	}
+	if demand := pendingMemory - (totalMemory - activeMemory); demand > 0 {
+		if byMemory := int((demand + perNodeMemory - 1) / perNodeMemory); byMemory > needed {
+			needed = byMemory
+		}
+	}
+	return needed
+}
+
+func (s *scaleUp) reconcileClass(ctx context.Context, podClass, machineSetRef string) error {
+	utilization, err := s.prioritization.ClassNodeUtilization(ctx, podClass)
+	if err != nil {
+		return err
+	}
+	podList := &corev1.PodList{}
+	if err := s.client.List(ctx, podList); err != nil {
+		return err
+	}
+	var pendingCPU, pendingMemory, activeCPU, activeMemory int64
+	pending := 0
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Spec.NodeSelector[scheduling.LabelName] != podClass {
+			continue
+		}
+		cpu, memory := podRequests(pod)
+		switch {
+		case pod.Spec.NodeName == "" && pod.Status.Phase == corev1.PodPending:
+			// Gated pods count as demand too: they are invisible to the
+			// cluster autoscaler, and with gating enabled a full pool would
+			// otherwise never generate a scale-up signal anywhere.
+			pending++
+			pendingCPU += cpu
+			pendingMemory += memory
+		case pod.Spec.NodeName != "" && pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed:
+			activeCPU += cpu
+			activeMemory += memory
+		}
+	}
+	needed := neededNodes(utilization, pendingCPU, pendingMemory, activeCPU, activeMemory)
+	if needed == 0 {
+		return nil
+	}
+	namespace, name, valid := strings.Cut(machineSetRef, "/")
+	if !valid {
+		return nil
+	}
+	machineSet := &unstructured.Unstructured{}
+	machineSet.SetGroupVersionKind(machineSetGVK2)
+	if err := s.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, machineSet); err != nil {
+		return err
+	}
+	replicas, _, err := unstructured.NestedInt64(machineSet.Object, "spec", "replicas")
+	if err != nil {
+		return err
+	}
+	desired := replicas + int64(needed)
+	if desired > s.maxReplicas {
+		desired = s.maxReplicas
+	}
+	if desired <= replicas {
+		return nil
+	}
+	updated := machineSet.DeepCopy()
+	if err := unstructured.SetNestedField(updated.Object, desired, "spec", "replicas"); err != nil {
+		return err
+	}
+	s.logger.WithField("class", podClass).WithField("machineset", name).
+		Infof("scaling up from %d to %d replicas for %d pending pods", replicas, desired, pending)
+	return s.client.Patch(ctx, updated, ctrlruntimeclient.MergeFrom(machineSet))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scheduleDays names the day sets accepted in capacity schedules.
+var scheduleDays = map[string]func(time.Weekday) bool{
+	"all":      func(time.Weekday) bool { return true },
+	"weekdays": func(day time.Weekday) bool { return day >= time.Monday && day <= time.Friday },
+	"weekends": func(day time.Weekday) bool { return day == time.Saturday || day == time.Sunday },
+}
+
+// scheduleEntry adjusts one class's warm buffer during a recurring window.
+type scheduleEntry struct {
+	class      string
+	minIdle    int
+	days       string
+	start, end int
+}
+
+func (e *scheduleEntry) active(now time.Time) bool {
+	if !scheduleDays[e.days](now.UTC().Weekday()) {
+		return false
+	}
+	minute := now.UTC().Hour()*60 + now.UTC().Minute()
+	if e.start <= e.end {
+		return minute >= e.start && minute < e.end
+	}
+	return minute >= e.start || minute < e.end
+}
+
+// capacitySchedule drives warm-pool minimums from recurring windows, so
+// weekend scale-to-near-zero and generous working-hours buffers happen from
+// config rather than manual intervention.
+type capacitySchedule struct {
+	entries []scheduleEntry
+	// base supplies the out-of-window warm buffers.
+	base map[string]int
+	// now stands in for time.Now in tests.
+	now func() time.Time
+}
+
+// parseCapacitySchedules parses class=minIdle@days:HH:MM-HH:MM entries,
+// e.g. tests=5@weekdays:06:00-18:00 or tests=0@weekends:00:00-23:59.
+func parseCapacitySchedules(specs []string, classes map[string]bool) ([]scheduleEntry, error) {
+	var entries []scheduleEntry
+	for _, spec := range specs {
+		malformed := fmt.Errorf("capacity schedule must be in class=minIdle@days:HH:MM-HH:MM form, not %q", spec)
+		assignment, window, valid := strings.Cut(spec, "@")
+		if !valid {
+			return nil, malformed
+		}
+		class, rawFloor, valid := strings.Cut(assignment, "=")
+		if !valid || !classes[class] {
+			return nil, malformed
+		}
+		floor, err := strconv.Atoi(rawFloor)
+		if err != nil || floor < 0 {
+			return nil, malformed
+		}
+		days, timespan, valid := strings.Cut(window, ":")
+		if !valid || scheduleDays[days] == nil {
+			return nil, malformed
+		}
+		rawStart, rawEnd, valid := strings.Cut(timespan, "-")
+		if !valid {
+			return nil, malformed
+		}
+		entry := scheduleEntry{class: class, minIdle: floor, days: days}
+		for _, bound := range []struct {
+			raw    string
+			minute *int
+		}{{rawStart, &entry.start}, {rawEnd, &entry.end}} {
+			parsed, err := time.Parse("15:04", bound.raw)
+			if err != nil {
+				return nil, malformed
+			}
+			*bound.minute = parsed.Hour()*60 + parsed.Minute()
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// minIdleFor resolves the warm buffer for a class: the last active schedule
+// entry wins, the base configuration applies outside every window.
+func (s *capacitySchedule) minIdleFor(podClass string) int {
+	now := time.Now()
+	if s.now != nil {
+		now = s.now()
+	}
+	floor := s.base[podClass]
+	for _, entry := range s.entries {
+		if entry.class == podClass && entry.active(now) {
+			floor = entry.minIdle
+		}
+	}
+	return floor
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+func TestCapacitySchedule(t *testing.T) {
+	entries, err := parseCapacitySchedules([]string{
+		"tests=5@weekdays:06:00-18:00",
+		"tests=0@weekends:00:00-23:59",
+	}, scheduling.SetOf(scheduling.PodClasses))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	schedule := &capacitySchedule{entries: entries, base: map[string]int{scheduling.ClassTests: 2}}
+	at := func(day time.Weekday, hour int) func() time.Time {
+		// 2024-06-03 is a Monday.
+		base := time.Date(2024, 6, 3, hour, 30, 0, 0, time.UTC)
+		return func() time.Time { return base.AddDate(0, 0, int(day-time.Monday)) }
+	}
+	var testCases = []struct {
+		name     string
+		now      func() time.Time
+		expected int
+	}{
+		{name: "weekday working hours get the generous buffer", now: at(time.Wednesday, 12), expected: 5},
+		{name: "weekday night falls back to the base floor", now: at(time.Wednesday, 22), expected: 2},
+		{name: "weekends scale to near zero", now: at(time.Saturday, 12), expected: 0},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			schedule.now = testCase.now
+			if got := schedule.minIdleFor(scheduling.ClassTests); got != testCase.expected {
+				t.Errorf("%s: expected floor %d, got %d", testCase.name, testCase.expected, got)
+			}
+		})
+	}
+	if got := schedule.minIdleFor(scheduling.ClassBuilds); got != 0 {
+		t.Errorf("expected unscheduled classes to use their base floor, got %d", got)
+	}
+	for _, invalid := range []string{"tests=5", "unknown=5@all:00:00-01:00", "tests=5@daily:00:00-01:00", "tests=-1@all:00:00-01:00"} {
+		if _, err := parseCapacitySchedules([]string{invalid}, scheduling.SetOf(scheduling.PodClasses)); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
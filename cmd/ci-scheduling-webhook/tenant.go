@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	tenantpolicy "github.com/openshift/ci-tools/pkg/api/tenantschedulingpolicy/v1"
+	"github.com/openshift/ci-tools/pkg/steps"
+)
+
+// tenantPolicyResolver looks up the TenantSchedulingPolicy applying to a pod
+// through the cached client (backed by an informer), matching org/repo labels
+// stamped by ci-operator. A repo-scoped policy beats an org-wide one.
+type tenantPolicyResolver struct {
+	client ctrlruntimeclient.Client
+}
+
+func (r *tenantPolicyResolver) policyFor(ctx context.Context, pod *corev1.Pod) (*tenantpolicy.TenantSchedulingPolicySpec, error) {
+	if r == nil {
+		return nil, nil
+	}
+	org, repo := pod.Labels[steps.LabelMetadataOrg], pod.Labels[steps.LabelMetadataRepo]
+	if org == "" {
+		return nil, nil
+	}
+	policyList := &tenantpolicy.TenantSchedulingPolicyList{}
+	if err := r.client.List(ctx, policyList); err != nil {
+		return nil, err
+	}
+	var orgWide *tenantpolicy.TenantSchedulingPolicySpec
+	for i := range policyList.Items {
+		spec := &policyList.Items[i].Spec
+		if spec.Org != org {
+			continue
+		}
+		if spec.Repo == repo && repo != "" {
+			return spec, nil
+		}
+		if spec.Repo == "" {
+			orgWide = spec
+		}
+	}
+	return orgWide, nil
+}
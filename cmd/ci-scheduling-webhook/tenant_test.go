@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	tenantpolicy "github.com/openshift/ci-tools/pkg/api/tenantschedulingpolicy/v1"
+	"github.com/openshift/ci-tools/pkg/scheduling"
+	"github.com/openshift/ci-tools/pkg/steps"
+)
+
+func TestTenantPolicyResolver(t *testing.T) {
+	orgWide := &tenantpolicy.TenantSchedulingPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "org-wide"},
+		Spec:       tenantpolicy.TenantSchedulingPolicySpec{Org: "openshift", ShrinkExempt: true},
+	}
+	repoScoped := &tenantpolicy.TenantSchedulingPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "repo-scoped"},
+		Spec:       tenantpolicy.TenantSchedulingPolicySpec{Org: "openshift", Repo: "installer", PodClass: scheduling.ClassIOIntensive},
+	}
+	resolver := &tenantPolicyResolver{client: fakectrlruntimeclient.NewClientBuilder().WithObjects(orgWide, repoScoped).Build()}
+
+	pod := func(org, repo string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ci-op-12345",
+			Labels:    map[string]string{steps.LabelMetadataOrg: org, steps.LabelMetadataRepo: repo},
+		}}
+	}
+	if policy, err := resolver.policyFor(context.Background(), pod("openshift", "installer")); err != nil || policy == nil || policy.PodClass != scheduling.ClassIOIntensive {
+		t.Errorf("expected the repo-scoped policy to win, got %+v (%v)", policy, err)
+	}
+	if policy, err := resolver.policyFor(context.Background(), pod("openshift", "other")); err != nil || policy == nil || !policy.ShrinkExempt {
+		t.Errorf("expected the org-wide policy to apply, got %+v (%v)", policy, err)
+	}
+	if policy, err := resolver.policyFor(context.Background(), pod("unrelated", "repo")); err != nil || policy != nil {
+		t.Errorf("expected no policy for an unrelated org, got %+v (%v)", policy, err)
+	}
+	var nilResolver *tenantPolicyResolver
+	if policy, err := nilResolver.policyFor(context.Background(), pod("openshift", "installer")); err != nil || policy != nil {
+		t.Errorf("expected a nil resolver to be inert, got %+v (%v)", policy, err)
+	}
+}
+
+func TestTenantPolicyMutation(t *testing.T) {
+	mutator := &podMutator{
+		prioritization:    &scheduling.Prioritization{Client: fakectrlruntimeclient.NewClientBuilder().Build()},
+		avoidanceMode:     avoidanceModeTaints,
+		shrinkCPURequests: map[string]float64{scheduling.ClassTests: 0.5},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "pod"},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "test",
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+				corev1.ResourceCPU: resource.MustParse("2000m"),
+			}},
+		}}},
+	}
+	overrides := &mutationOverrides{shrinkExempt: true, priorityClassName: "tenant-priority"}
+	if err := mutator.mutatePod(context.Background(), pod, scheduling.ClassTests, overrides, nil, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cpu := pod.Spec.Containers[0].Resources.Requests.Cpu().String(); cpu != "2" {
+		t.Errorf("expected shrink exemption to keep the CPU request, got %s", cpu)
+	}
+	if pod.Spec.PriorityClassName != "tenant-priority" {
+		t.Errorf("expected the tenant priority class, got %q", pod.Spec.PriorityClassName)
+	}
+}
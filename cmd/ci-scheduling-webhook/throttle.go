@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	prometheusapi "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/sirupsen/logrus"
+)
+
+// dynamicShrink overlays runtime-adjusted shrink factors over the configured
+// ones.
+type dynamicShrink struct {
+	lock    sync.Mutex
+	factors map[string]float64
+}
+
+func (d *dynamicShrink) factorFor(podClass string) (float64, bool) {
+	if d == nil {
+		return 0, false
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	factor, adjusted := d.factors[podClass]
+	return factor, adjusted
+}
+
+func (d *dynamicShrink) set(podClass string, factor float64) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.factors == nil {
+		d.factors = map[string]float64{}
+	}
+	d.factors[podClass] = factor
+}
+
+// throttleRatioQuerier reports the fraction of CFS periods in which class
+// workloads were throttled.
+type throttleRatioQuerier interface {
+	throttleRatio(ctx context.Context) (float64, error)
+}
+
+// prometheusThrottleQuerier evaluates the throttling ratio through the
+// cluster's Prometheus.
+type prometheusThrottleQuerier struct {
+	api   prometheusapi.API
+	query string
+}
+
+func (q *prometheusThrottleQuerier) throttleRatio(ctx context.Context) (float64, error) {
+	value, _, err := q.api.Query(ctx, q.query, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	vector, isVector := value.(model.Vector)
+	if !isVector || len(vector) == 0 {
+		return 0, fmt.Errorf("throttle query returned no samples")
+	}
+	return float64(vector[0].Value), nil
+}
+
+// throttleAdjuster tunes the CPU shrink factor of one class within bounds so
+// the webhook stops over-shrinking when CFS throttling exceeds the SLO, and
+// carefully re-tightens once throttling subsides.
+type throttleAdjuster struct {
+	logger   *logrus.Entry
+	querier  throttleRatioQuerier
+	overlay  *dynamicShrink
+	podClass string
+
+	// slo is the tolerated fraction of throttled CFS periods.
+	slo float64
+	// minFactor and maxFactor bound the adjustment; step is applied per tick.
+	minFactor, maxFactor, step float64
+	// base seeds the adjustment from the configured factor.
+	base     float64
+	interval time.Duration
+}
+
+func (a *throttleAdjuster) run(ctx context.Context) {
+	a.overlay.set(a.podClass, a.base)
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.adjust(ctx)
+		}
+	}
+}
+
+func (a *throttleAdjuster) adjust(ctx context.Context) {
+	ratio, err := a.querier.throttleRatio(ctx)
+	if err != nil {
+		a.logger.WithError(err).Warning("Could not determine throttling ratio, keeping the current shrink factor.")
+		return
+	}
+	current, _ := a.overlay.factorFor(a.podClass)
+	adjusted := current
+	switch {
+	case ratio > a.slo:
+		// Too much throttling: shrink less.
+		adjusted = current + a.step
+	case ratio < a.slo/2:
+		// Comfortable headroom: shrink a bit more again.
+		adjusted = current - a.step
+	}
+	if adjusted > a.maxFactor {
+		adjusted = a.maxFactor
+	}
+	if adjusted < a.minFactor {
+		adjusted = a.minFactor
+	}
+	if adjusted != current {
+		a.logger.Infof("throttling ratio %.3f against SLO %.3f, adjusting %s shrink factor from %.2f to %.2f", ratio, a.slo, a.podClass, current, adjusted)
+		a.overlay.set(a.podClass, adjusted)
+	}
+}
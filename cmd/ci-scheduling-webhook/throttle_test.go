@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+)
+
+type staticRatio float64
+
+func (r staticRatio) throttleRatio(context.Context) (float64, error) {
+	return float64(r), nil
+}
+
+func TestThrottleAdjuster(t *testing.T) {
+	overlay := &dynamicShrink{}
+	adjuster := &throttleAdjuster{
+		logger:    logrus.WithField("test", t.Name()),
+		overlay:   overlay,
+		podClass:  scheduling.ClassTests,
+		slo:       0.05,
+		minFactor: 0.3,
+		maxFactor: 0.6,
+		step:      0.05,
+		base:      0.5,
+	}
+	overlay.set(adjuster.podClass, adjuster.base)
+
+	// Throttling above the SLO relaxes shrinking.
+	adjuster.querier = staticRatio(0.2)
+	adjuster.adjust(context.Background())
+	if factor, _ := overlay.factorFor(scheduling.ClassTests); factor != 0.55 {
+		t.Errorf("expected the factor to relax to 0.55, got %f", factor)
+	}
+	// It never exceeds the upper bound.
+	adjuster.adjust(context.Background())
+	adjuster.adjust(context.Background())
+	if factor, _ := overlay.factorFor(scheduling.ClassTests); factor != 0.6 {
+		t.Errorf("expected the factor to cap at 0.6, got %f", factor)
+	}
+	// Comfortable headroom re-tightens, bounded below.
+	adjuster.querier = staticRatio(0.0)
+	for i := 0; i < 10; i++ {
+		adjuster.adjust(context.Background())
+	}
+	if factor, _ := overlay.factorFor(scheduling.ClassTests); factor != 0.3 {
+		t.Errorf("expected the factor to floor at 0.3, got %f", factor)
+	}
+	// Inside the dead band nothing changes.
+	adjuster.querier = staticRatio(0.04)
+	adjuster.adjust(context.Background())
+	if factor, _ := overlay.factorFor(scheduling.ClassTests); factor != 0.3 {
+		t.Errorf("expected the factor to hold inside the dead band, got %f", factor)
+	}
+
+	// The overlay takes precedence in the mutator.
+	mutator := &podMutator{shrinkCPURequests: map[string]float64{scheduling.ClassTests: 0.9}, dynamicShrink: overlay}
+	if factor, configured := mutator.shrinkFactorFor(scheduling.ClassTests); !configured || factor != 0.3 {
+		t.Errorf("expected the overlay factor to win, got %f (%t)", factor, configured)
+	}
+}
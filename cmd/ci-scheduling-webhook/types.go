@@ -0,0 +1,34 @@
+package main
+
+const (
+	// avoidanceModeAffinity precludes scale-down candidates through hostname
+	// anti-affinity injected into pods, falling back to taints beyond the cap.
+	avoidanceModeAffinity = "affinity"
+	// avoidanceModeTaints implements avoidance purely through the taints
+	// managed on nodes; pod specs are left small at the cost of avoidance
+	// being a soft preference only.
+	avoidanceModeTaints = "taints"
+	// avoidanceModeTiered excludes drained candidates through required
+	// affinity but gives still-draining ones only a weighted preference, so
+	// scale-down pressure cannot cause scheduling failures when capacity is
+	// tight.
+	avoidanceModeTiered = "tiered"
+	// avoidanceModeLabel labels precluded nodes and injects one small
+	// DoesNotExist affinity term per pod, keeping pod specs bounded and
+	// clear of the 64-term selector limits no matter how large the pool.
+	avoidanceModeLabel = "label"
+)
+
+const (
+	// tolerationModeDirect injects the class toleration straight into
+	// pod.spec, for clusters where creating RuntimeClasses is not possible
+	// or where pod overhead semantics are undesired.
+	tolerationModeDirect = "direct"
+	// tolerationModeRuntimeClass points pods at the per-class
+	// ci-scheduler-runtime-<class> RuntimeClass, whose scheduling stanza
+	// carries the toleration.
+	tolerationModeRuntimeClass = "runtimeclass"
+
+	// runtimeClassPrefix names the per-class RuntimeClass objects.
+	runtimeClassPrefix = "ci-scheduler-runtime-"
+)
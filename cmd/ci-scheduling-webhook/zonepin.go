@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// pinnedZoneAnnotation records, on a ci-op namespace, the zone its first
+// scheduled pod landed in; later pods of the namespace are pinned there,
+// avoiding cross-zone traffic charges and zone-skewed flakes for multi-pod
+// e2e topologies.
+const pinnedZoneAnnotation = "ci-workload.openshift.io/pinned-zone"
+
+// zonePinner maintains the pinned-zone annotation on ci-op namespaces from
+// their first scheduled pod.
+type zonePinner struct {
+	logger   *logrus.Entry
+	client   ctrlruntimeclient.Client
+	interval time.Duration
+}
+
+func (p *zonePinner) run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.reconcile(ctx); err != nil {
+				p.logger.WithError(err).Error("Failed to pin namespace zones.")
+			}
+		}
+	}
+}
+
+func (p *zonePinner) reconcile(ctx context.Context) error {
+	podList := &corev1.PodList{}
+	if err := p.client.List(ctx, podList); err != nil {
+		return err
+	}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		if !strings.HasPrefix(pod.Namespace, "ci-op-") && !strings.HasPrefix(pod.Namespace, "ci-ln-") {
+			continue
+		}
+		if err := p.pinNamespace(ctx, pod); err != nil {
+			p.logger.WithError(err).WithField("namespace", pod.Namespace).Warning("Failed to pin namespace zone.")
+		}
+	}
+	return nil
+}
+
+func (p *zonePinner) pinNamespace(ctx context.Context, pod *corev1.Pod) error {
+	namespace := &corev1.Namespace{}
+	if err := p.client.Get(ctx, types.NamespacedName{Name: pod.Namespace}, namespace); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if namespace.Annotations[pinnedZoneAnnotation] != "" {
+		return nil
+	}
+	node := &corev1.Node{}
+	if err := p.client.Get(ctx, types.NamespacedName{Name: pod.Spec.NodeName}, node); err != nil {
+		return err
+	}
+	zone := node.Labels[corev1.LabelTopologyZone]
+	if zone == "" {
+		return nil
+	}
+	updated := namespace.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	updated.Annotations[pinnedZoneAnnotation] = zone
+	p.logger.WithField("namespace", pod.Namespace).WithField("zone", zone).Debug("pinning namespace to the zone of its first scheduled pod")
+	return p.client.Patch(ctx, updated, ctrlruntimeclient.MergeFrom(namespace))
+}
+
+// pinnedZoneFor reads the namespace's pinned zone, empty when unpinned.
+func (m *podMutator) pinnedZoneFor(ctx context.Context, namespaceName string) string {
+	if m.client == nil {
+		return ""
+	}
+	namespace := &corev1.Namespace{}
+	if err := m.client.Get(ctx, types.NamespacedName{Name: namespaceName}, namespace); err != nil {
+		return ""
+	}
+	return namespace.Annotations[pinnedZoneAnnotation]
+}
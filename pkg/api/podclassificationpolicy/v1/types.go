@@ -0,0 +1,53 @@
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PodClassificationPolicy declares, in-cluster and auditable, how pods
+// matching the given name, namespace, and label criteria are classified and
+// mutated, instead of the policy being baked into the webhook binary.
+type PodClassificationPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec PodClassificationPolicySpec `json:"spec"`
+}
+
+type PodClassificationPolicySpec struct {
+	// Match selects the pods the policy applies to; all set criteria must
+	// match.
+	Match PodMatcher `json:"match"`
+
+	// PodClass is the class matched pods are routed to.
+	PodClass string `json:"podClass"`
+	// ShrinkCPUFactor overrides the class's CPU shrink factor for matched
+	// pods.
+	ShrinkCPUFactor *float64 `json:"shrinkCPUFactor,omitempty"`
+	// Tolerations are injected into matched pods in addition to the class
+	// toleration.
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+}
+
+// PodMatcher selects pods by name, namespace, and labels.
+type PodMatcher struct {
+	// NamePrefix matches pods whose name starts with the prefix.
+	NamePrefix string `json:"namePrefix,omitempty"`
+	// NamespacePrefix matches pods in namespaces starting with the prefix.
+	NamespacePrefix string `json:"namespacePrefix,omitempty"`
+	// Labels must all be present with the given values.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PodClassificationPolicyList is a list of PodClassificationPolicy resources.
+type PodClassificationPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []PodClassificationPolicy `json:"items"`
+}
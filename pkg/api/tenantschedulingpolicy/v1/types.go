@@ -0,0 +1,42 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// TenantSchedulingPolicy grants a tenant, keyed by org/repo, scheduling
+// behavior that differs from the build farm defaults. Before this existed,
+// every exception became a hardcoded special case in the webhook.
+type TenantSchedulingPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec TenantSchedulingPolicySpec `json:"spec"`
+}
+
+type TenantSchedulingPolicySpec struct {
+	// Org is the organization the policy applies to.
+	Org string `json:"org"`
+	// Repo narrows the policy to one repository; empty covers the whole org.
+	Repo string `json:"repo,omitempty"`
+
+	// PriorityClassName is set on the tenant's pods when they carry none.
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// ShrinkExempt keeps the tenant's CPU requests unshrunk.
+	ShrinkExempt bool `json:"shrinkExempt,omitempty"`
+	// PodClass routes the tenant's pods to a specific pool regardless of the
+	// heuristic classification.
+	PodClass string `json:"podClass,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// TenantSchedulingPolicyList is a list of TenantSchedulingPolicy resources.
+type TenantSchedulingPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []TenantSchedulingPolicy `json:"items"`
+}
@@ -0,0 +1,481 @@
+package scheduling
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	buildv1 "github.com/openshift/api/build/v1"
+)
+
+// IOIntensiveAnnotation lets a job opt its pods into the local-SSD pool
+// without being on the configured list.
+const IOIntensiveAnnotation = "ci-workload.openshift.io/io-intensive"
+
+// stepLabel and targetLabel are the ci-operator metadata labels consulted by
+// the step and target classification rules. They mirror the constants in
+// pkg/steps, duplicated here to keep this library free of that dependency
+// tree.
+const (
+	stepLabel   = "ci.openshift.io/metadata.step"
+	targetLabel = "ci.openshift.io/metadata.target"
+)
+
+// artifactContainerNames identifies containers that upload artifacts; pods
+// built around them form the artifacts class and their CPU requests are never
+// shrunk, since a throttled upload means missing artifacts.
+var artifactContainerNames = map[string]bool{
+	"gcsupload":     true,
+	"artifact-sync": true,
+}
+
+// IsArtifactContainer reports whether a container uploads artifacts and must
+// keep its full CPU request no matter which class the surrounding pod landed
+// in.
+func IsArtifactContainer(name string) bool {
+	return artifactContainerNames[name]
+}
+
+// isArtifactUploadPod reports whether a pod exists to upload artifacts, i.e.
+// all of its containers are upload containers.
+func isArtifactUploadPod(pod *corev1.Pod) bool {
+	if len(pod.Spec.Containers) == 0 {
+		return false
+	}
+	for _, container := range pod.Spec.Containers {
+		if !artifactContainerNames[container.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+// ImageClassRule assigns a class to pods running images whose reference
+// matches the pattern.
+type ImageClassRule struct {
+	Pattern *regexp.Regexp
+	Class   string
+}
+
+// ParseImageClassRules compiles pattern=class specifications into rules,
+// rejecting unknown classes and invalid patterns.
+func ParseImageClassRules(specs []string) ([]ImageClassRule, error) {
+	classes := SetOf(PodClasses)
+	var rules []ImageClassRule
+	for _, spec := range specs {
+		index := strings.LastIndex(spec, "=")
+		if index < 1 {
+			return nil, fmt.Errorf("image class rule must be in pattern=class form, not %q", spec)
+		}
+		pattern, class := spec[:index], spec[index+1:]
+		if !classes[class] {
+			return nil, fmt.Errorf("image class rule %q names unknown class %q", spec, class)
+		}
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("image class rule pattern %q does not compile: %w", pattern, err)
+		}
+		rules = append(rules, ImageClassRule{Pattern: compiled, Class: class})
+	}
+	return rules, nil
+}
+
+// ParseClassMap parses name=class assignments, rejecting unknown classes.
+func ParseClassMap(specs []string) (map[string]string, error) {
+	classes := SetOf(PodClasses)
+	assignments := map[string]string{}
+	for _, spec := range specs {
+		name, class, valid := strings.Cut(spec, "=")
+		if !valid || name == "" {
+			return nil, fmt.Errorf("class assignment must be in name=class form, not %q", spec)
+		}
+		if !classes[class] {
+			return nil, fmt.Errorf("class assignment %q names unknown class %q", spec, class)
+		}
+		assignments[name] = class
+	}
+	return assignments, nil
+}
+
+// Classifier determines which dedicated node pool, if any, a pod belongs on.
+type Classifier struct {
+	// ImageClassRules reclassify pods by container image reference;
+	// name-prefix matching kept missing renamed jobs.
+	ImageClassRules []ImageClassRule
+
+	// IOIntensiveJobs names prowjobs known to be IO-heavy; their pods are
+	// rerouted to the local-SSD pool.
+	IOIntensiveJobs map[string]bool
+
+	// AcceleratorResources are the extended resource names that route a pod
+	// to the GPU pool; nvidia.com/gpu is always recognized.
+	AcceleratorResources map[string]bool
+
+	// HeavyMemoryThreshold routes pods whose aggregate container memory
+	// requests exceed it to the heavy class; nil disables the class.
+	HeavyMemoryThreshold *resource.Quantity
+
+	// LargeDiskThreshold routes pods whose aggregate ephemeral-storage
+	// requests exceed it to the large-disk class; nil disables the class.
+	LargeDiskThreshold *resource.Quantity
+
+	// ResourceClassRules route pods requesting a specific extended resource
+	// (hugepages pools, device plugins) to the pool that provides it. Keys
+	// ending in - match as prefixes; matched resources are implicitly
+	// harmless.
+	ResourceClassRules map[string]string
+
+	// MetalStepNames are ci-operator steps known to need /dev/kvm; their
+	// pods route to the bare-metal pool alongside pods requesting the KVM
+	// device resource directly.
+	MetalStepNames map[string]bool
+
+	// HarmlessExtendedResources are extended resource names that do not
+	// cause a pod to be left unmanaged; hugepages and the standard resources
+	// are always harmless. Anything else beyond cpu/memory/ephemeral-storage
+	// makes the pod unschedulable on the generic CI machinesets, so such
+	// pods are skipped rather than pinned to pools that cannot run them.
+	HarmlessExtendedResources map[string]bool
+
+	// XLBuildPatterns route builds whose openshift.io/build.name matches to
+	// the builds-xl pool.
+	XLBuildPatterns []*regexp.Regexp
+	// XLBuildRepos routes all builds of an org/repo (from the metadata
+	// labels) to the builds-xl pool.
+	XLBuildRepos map[string]bool
+
+	// DefaultClass, when set, catches pods no other rule classified, so no
+	// CI pod accidentally lands on infra or long-lived nodes and blocks
+	// autoscaler scale-down. Deployments should pair it with a namespace
+	// allowlist, since it claims every unmatched pod the webhook sees.
+	DefaultClass string
+
+	// PresubmitTiering splits test-class presubmits into tests-high and
+	// tests-low by the prow.k8s.io/is-optional label, so blocking presubmits
+	// schedule ahead of optional ones during capacity crunches.
+	PresubmitTiering bool
+
+	// SpotJobTypes are the prow job types (from the prow.k8s.io/type label)
+	// routed to the spot/preemptible pool. Periodics tolerate interruption
+	// well: they rerun on schedule anyway.
+	SpotJobTypes map[string]bool
+
+	// StepClassRules map ci-operator step names (from the metadata.step
+	// label) to pod classes, giving much finer-grained routing than
+	// pod-name prefix matching.
+	StepClassRules map[string]string
+	// TargetClassRules map ci-operator target names (from the
+	// metadata.target label) to pod classes; a step rule wins over a target
+	// rule.
+	TargetClassRules map[string]string
+}
+
+// isHeavy reports whether the pod's aggregate container memory requests
+// exceed the heavy threshold. Init containers run sequentially and do not
+// add up, so only the larger of any single init container and the container
+// sum counts.
+func (c *Classifier) isHeavy(pod *corev1.Pod) bool {
+	if c.HeavyMemoryThreshold == nil {
+		return false
+	}
+	total := resource.NewQuantity(0, resource.BinarySI)
+	for _, container := range pod.Spec.Containers {
+		memory := container.Resources.Requests[corev1.ResourceMemory]
+		total.Add(memory)
+	}
+	for _, container := range pod.Spec.InitContainers {
+		memory := container.Resources.Requests[corev1.ResourceMemory]
+		if memory.Cmp(*total) > 0 {
+			total = &memory
+		}
+	}
+	return total.Cmp(*c.HeavyMemoryThreshold) > 0
+}
+
+// kvmDeviceResource is the device plugin resource exposing /dev/kvm.
+const kvmDeviceResource = "devices.kubevirt.io/kvm"
+
+// needsKVM reports whether a pod requires nested virtualization, either by
+// requesting the KVM device resource or by running a known metal step.
+func (c *Classifier) needsKVM(pod *corev1.Pod) bool {
+	if c.MetalStepNames[pod.Labels[stepLabel]] {
+		return true
+	}
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			for _, list := range []corev1.ResourceList{container.Resources.Requests, container.Resources.Limits} {
+				if _, needs := list[kvmDeviceResource]; needs {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// resourceClassFor returns the pool a pod's extended resources route to, if
+// a rule matches.
+func (c *Classifier) resourceClassFor(pod *corev1.Pod) (string, string, bool) {
+	match := func(name string) (string, bool) {
+		for rule, class := range c.ResourceClassRules {
+			if rule == name || (strings.HasSuffix(rule, "-") && strings.HasPrefix(name, rule)) {
+				return class, true
+			}
+		}
+		return "", false
+	}
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			for _, list := range []corev1.ResourceList{container.Resources.Requests, container.Resources.Limits} {
+				for name := range list {
+					if class, routed := match(string(name)); routed {
+						return class, string(name), true
+					}
+				}
+			}
+		}
+	}
+	return "", "", false
+}
+
+// harmless reports whether a resource can be ignored for classification.
+func (c *Classifier) harmless(name corev1.ResourceName) bool {
+	switch name {
+	case corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourceEphemeralStorage:
+		return true
+	}
+	if strings.HasPrefix(string(name), "hugepages-") {
+		return true
+	}
+	if _, routed := func() (string, bool) {
+		for rule, class := range c.ResourceClassRules {
+			if rule == string(name) || (strings.HasSuffix(rule, "-") && strings.HasPrefix(string(name), rule)) {
+				return class, true
+			}
+		}
+		return "", false
+	}(); routed {
+		return true
+	}
+	return c.HarmlessExtendedResources[string(name)]
+}
+
+// unknownExtendedResource returns the first extended resource that makes the
+// pod unschedulable on the generic CI machinesets.
+func (c *Classifier) unknownExtendedResource(pod *corev1.Pod) (string, bool) {
+	accelerator := func(name corev1.ResourceName) bool {
+		return name == "nvidia.com/gpu" || c.AcceleratorResources[string(name)]
+	}
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			for _, list := range []corev1.ResourceList{container.Resources.Requests, container.Resources.Limits} {
+				for name := range list {
+					if !c.harmless(name) && !accelerator(name) {
+						return string(name), true
+					}
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// needsLargeDisk reports whether the pod's aggregate ephemeral-storage
+// requests exceed the large-disk threshold.
+func (c *Classifier) needsLargeDisk(pod *corev1.Pod) bool {
+	if c.LargeDiskThreshold == nil {
+		return false
+	}
+	total := resource.NewQuantity(0, resource.BinarySI)
+	for _, container := range pod.Spec.Containers {
+		storage := container.Resources.Requests[corev1.ResourceEphemeralStorage]
+		total.Add(storage)
+	}
+	return total.Cmp(*c.LargeDiskThreshold) > 0
+}
+
+// requestsAccelerator reports whether any container requests or limits an
+// accelerator resource.
+func (c *Classifier) requestsAccelerator(pod *corev1.Pod) bool {
+	accelerator := func(name corev1.ResourceName) bool {
+		return name == "nvidia.com/gpu" || c.AcceleratorResources[string(name)]
+	}
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			for name := range container.Resources.Requests {
+				if accelerator(name) {
+					return true
+				}
+			}
+			for name := range container.Resources.Limits {
+				if accelerator(name) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Classify determines the pod class, applying image rules and rerouting known
+// IO-heavy workloads to the instance-store/local-SSD pool. Only pods that
+// already belong to a CI class are ever rerouted. Pods already stamped with a
+// known class label (e.g. via ProwJob admission) keep it without any
+// heuristics running.
+func (c *Classifier) Classify(pod *corev1.Pod) string {
+	podClass, _ := c.Explain(pod)
+	return podClass
+}
+
+// Explain classifies the pod and names the rule that decided, for decision
+// audit trails and the explain endpoint.
+func (c *Classifier) Explain(pod *corev1.Pod) (string, string) {
+	if stamped := pod.Labels[LabelName]; SetOf(PodClasses)[stamped] {
+		return stamped, "stamped class label"
+	}
+	podClass, reason := classifyPod(pod)
+	if podClass == ClassNone {
+		if c.DefaultClass != "" {
+			podClass, reason = c.DefaultClass, "default catch-all class"
+		} else {
+			return podClass, reason
+		}
+	}
+	if os := podOperatingSystem(pod); os != "" && os != "linux" {
+		// Forcing a Linux nodeSelector onto these would make them
+		// unschedulable; leave them to their own pools.
+		return ClassNone, fmt.Sprintf("%s%s", UnsupportedOSReasonPrefix, os)
+	}
+	if c.requestsAccelerator(pod) {
+		return ClassGPU, "accelerator resource request"
+	}
+	if c.needsKVM(pod) {
+		return ClassMetal, "nested virtualization requirement"
+	}
+	if class, name, routed := c.resourceClassFor(pod); routed {
+		return class, fmt.Sprintf("resource rule for %s", name)
+	}
+	if name, unknown := c.unknownExtendedResource(pod); unknown {
+		return ClassNone, fmt.Sprintf("unmanaged extended resource %s", name)
+	}
+	if strings.HasPrefix(pod.Name, "release-images-") || strings.HasPrefix(pod.Name, "release-payload-") {
+		return ClassRelease, "release payload pod name"
+	}
+	if rule, matched := c.imageRuleFor(pod); matched {
+		podClass, reason = rule.Class, fmt.Sprintf("image rule %q", rule.Pattern.String())
+	}
+	if step := pod.Labels[stepLabel]; step != "" && c.StepClassRules[step] != "" {
+		podClass, reason = c.StepClassRules[step], fmt.Sprintf("step rule %q", step)
+	} else if target := pod.Labels[targetLabel]; target != "" && c.TargetClassRules[target] != "" {
+		podClass, reason = c.TargetClassRules[target], fmt.Sprintf("target rule %q", target)
+	}
+	if pod.Annotations[IOIntensiveAnnotation] == "true" {
+		return ClassIOIntensive, "io-intensive annotation"
+	}
+	if c.IOIntensiveJobs[pod.Labels["prow.k8s.io/job"]] {
+		return ClassIOIntensive, "configured io-intensive job"
+	}
+	if c.SpotJobTypes[pod.Labels["prow.k8s.io/type"]] {
+		return ClassSpot, fmt.Sprintf("spot-eligible %s job", pod.Labels["prow.k8s.io/type"])
+	}
+	if c.isHeavy(pod) {
+		return ClassHeavy, fmt.Sprintf("aggregate memory requests above %s", c.HeavyMemoryThreshold.String())
+	}
+	if c.needsLargeDisk(pod) {
+		return ClassLargeDisk, fmt.Sprintf("aggregate ephemeral-storage requests above %s", c.LargeDiskThreshold.String())
+	}
+	if podClass == ClassBuilds && c.isXLBuild(pod) {
+		return ClassBuildsXL, "known-large build"
+	}
+	if c.PresubmitTiering && podClass == ClassTests && pod.Labels["prow.k8s.io/type"] == "presubmit" {
+		if pod.Labels["prow.k8s.io/is-optional"] == "true" {
+			return ClassTestsLow, "optional presubmit tier"
+		}
+		return ClassTestsHigh, "blocking presubmit tier"
+	}
+	if arch := podArchitecture(pod); arch != "" {
+		if derived := ClassForArch(podClass, arch); derived != podClass {
+			return derived, fmt.Sprintf("%s, %s architecture", reason, arch)
+		}
+	}
+	return podClass, reason
+}
+
+// isXLBuild reports whether a build pod is known to need the big-instance
+// builds pool.
+func (c *Classifier) isXLBuild(pod *corev1.Pod) bool {
+	buildName := pod.Annotations[buildv1.BuildLabel]
+	for _, pattern := range c.XLBuildPatterns {
+		if pattern.MatchString(buildName) {
+			return true
+		}
+	}
+	return c.XLBuildRepos[pod.Labels[metadataOrgLabel]+"/"+pod.Labels[metadataRepoLabel]]
+}
+
+// metadataOrgLabel and metadataRepoLabel mirror the ci-operator metadata
+// label constants.
+const (
+	metadataOrgLabel  = "ci.openshift.io/metadata.org"
+	metadataRepoLabel = "ci.openshift.io/metadata.repo"
+)
+
+// UnsupportedOSReasonPrefix prefixes the classification reason for pods
+// excluded because they target a non-Linux operating system.
+const UnsupportedOSReasonPrefix = "unsupported operating system "
+
+// podOperatingSystem reads the OS a pod targets through its nodeSelector or
+// spec.os.
+func podOperatingSystem(pod *corev1.Pod) string {
+	if os := pod.Spec.NodeSelector[corev1.LabelOSStable]; os != "" {
+		return os
+	}
+	if pod.Spec.OS != nil {
+		return string(pod.Spec.OS.Name)
+	}
+	return ""
+}
+
+// podArchitecture reads the architecture a pod asks for through its
+// nodeSelector or the arch label ci-operator stamps.
+func podArchitecture(pod *corev1.Pod) string {
+	if arch := pod.Spec.NodeSelector[corev1.LabelArchStable]; arch != "" {
+		return arch
+	}
+	return pod.Labels["ci.openshift.io/arch"]
+}
+
+// classifyPod determines the base class of a pod and the rule deciding it.
+func classifyPod(pod *corev1.Pod) (string, string) {
+	if _, isBuildPod := pod.Annotations[buildv1.BuildLabel]; isBuildPod {
+		return ClassBuilds, "openshift build annotation"
+	}
+	if isArtifactUploadPod(pod) {
+		return ClassArtifacts, "artifact upload containers"
+	}
+	if strings.HasPrefix(pod.Namespace, "ci-op-") || strings.HasPrefix(pod.Namespace, "ci-ln-") {
+		return ClassTests, "test namespace prefix"
+	}
+	if pod.Labels["created-by-prow"] == "true" {
+		return ClassProwjobs, "created-by-prow label"
+	}
+	return ClassNone, "no classification rule matched"
+}
+
+// imageRuleFor returns the first rule matching any container image in the
+// pod, if any.
+func (c *Classifier) imageRuleFor(pod *corev1.Pod) (*ImageClassRule, bool) {
+	for i, rule := range c.ImageClassRules {
+		for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+			for _, container := range containers {
+				if rule.Pattern.MatchString(container.Image) {
+					return &c.ImageClassRules[i], true
+				}
+			}
+		}
+	}
+	return nil, false
+}
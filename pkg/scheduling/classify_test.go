@@ -0,0 +1,406 @@
+package scheduling
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	buildv1 "github.com/openshift/api/build/v1"
+)
+
+func TestClassify(t *testing.T) {
+	rules, err := ParseImageClassRules([]string{`registry\.ci\.openshift\.org/ci/cluster-bot-.*=longtests`})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	classifier := &Classifier{
+		ImageClassRules:      rules,
+		IOIntensiveJobs:      map[string]bool{"periodic-io-heavy": true},
+		AcceleratorResources: map[string]bool{"habana.ai/gaudi": true},
+	}
+	heavyThreshold := resource.MustParse("24Gi")
+	classifier.HeavyMemoryThreshold = &heavyThreshold
+	classifier.SpotJobTypes = map[string]bool{"periodic": true}
+	classifier.PresubmitTiering = true
+	classifier.XLBuildPatterns = []*regexp.Regexp{regexp.MustCompile(`^openshift-origin-`)}
+	classifier.XLBuildRepos = map[string]bool{"openshift/installer": true}
+	classifier.HarmlessExtendedResources = map[string]bool{"kata.peerpods.io/vm": true}
+	classifier.ResourceClassRules = map[string]string{"hugepages-": ClassHeavy}
+	classifier.MetalStepNames = map[string]bool{"launch-libvirt": true}
+	largeDisk := resource.MustParse("100Gi")
+	classifier.LargeDiskThreshold = &largeDisk
+	classifier.StepClassRules = map[string]string{"ipi-install": ClassLongTests}
+	classifier.TargetClassRules = map[string]string{"e2e-aws": ClassIOIntensive}
+	var testCases = []struct {
+		name     string
+		pod      *corev1.Pod
+		expected string
+	}{
+		{
+			name: "build pod",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "ci-op-12345",
+				Annotations: map[string]string{buildv1.BuildLabel: "some-build"},
+			}},
+			expected: ClassBuilds,
+		},
+		{
+			name:     "test pod by namespace",
+			pod:      &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345"}},
+			expected: ClassTests,
+		},
+		{
+			name:     "launch pod by namespace",
+			pod:      &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ci-ln-12345"}},
+			expected: ClassTests,
+		},
+		{
+			name: "prowjob pod",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ci",
+				Labels:    map[string]string{"created-by-prow": "true"},
+			}},
+			expected: ClassProwjobs,
+		},
+		{
+			name: "artifact upload pod",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345"},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "gcsupload"}}},
+			},
+			expected: ClassArtifacts,
+		},
+		{
+			name: "test pod with an upload sidecar stays a test pod",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345"},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "test"}, {Name: "gcsupload"}}},
+			},
+			expected: ClassTests,
+		},
+		{
+			name: "image rule reroutes renamed jobs",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345", Name: "renamed-job-pod"},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "test", Image: "registry.ci.openshift.org/ci/cluster-bot-launcher:latest"},
+				}},
+			},
+			expected: ClassLongTests,
+		},
+		{
+			name: "configured IO-heavy job is rerouted",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ci",
+				Labels:    map[string]string{"created-by-prow": "true", "prow.k8s.io/job": "periodic-io-heavy"},
+			}},
+			expected: ClassIOIntensive,
+		},
+		{
+			name: "annotation opt-in is rerouted",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "ci-op-12345",
+				Annotations: map[string]string{IOIntensiveAnnotation: "true"},
+			}},
+			expected: ClassIOIntensive,
+		},
+		{
+			name:     "unclassified pods are never rerouted",
+			pod:      &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Annotations: map[string]string{IOIntensiveAnnotation: "true"}}},
+			expected: ClassNone,
+		},
+		{
+			name: "gpu pod by accelerator request",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345"},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name: "test",
+					Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{
+						"nvidia.com/gpu": resource.MustParse("1"),
+					}},
+				}}},
+			},
+			expected: ClassGPU,
+		},
+		{
+			name: "gpu pod by configured accelerator",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345"},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name: "test",
+					Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+						"habana.ai/gaudi": resource.MustParse("1"),
+					}},
+				}}},
+			},
+			expected: ClassGPU,
+		},
+		{
+			name: "windows pods are excluded from mutation",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345"},
+				Spec:       corev1.PodSpec{NodeSelector: map[string]string{corev1.LabelOSStable: "windows"}},
+			},
+			expected: ClassNone,
+		},
+		{
+			name: "explicit linux pods stay classified",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345"},
+				Spec:       corev1.PodSpec{NodeSelector: map[string]string{corev1.LabelOSStable: "linux"}},
+			},
+			expected: ClassTests,
+		},
+		{
+			name: "large build by name pattern",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "ci-op-12345",
+				Annotations: map[string]string{buildv1.BuildLabel: "openshift-origin-amd64"},
+			}},
+			expected: ClassBuildsXL,
+		},
+		{
+			name: "large build by configured repo",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "ci-op-12345",
+				Labels:      map[string]string{metadataOrgLabel: "openshift", metadataRepoLabel: "installer"},
+				Annotations: map[string]string{buildv1.BuildLabel: "installer-build"},
+			}},
+			expected: ClassBuildsXL,
+		},
+		{
+			name: "blocking presubmit lands in the high tier",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ci-op-12345",
+				Labels:    map[string]string{"prow.k8s.io/type": "presubmit"},
+			}},
+			expected: ClassTestsHigh,
+		},
+		{
+			name: "optional presubmit lands in the low tier",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ci-op-12345",
+				Labels:    map[string]string{"prow.k8s.io/type": "presubmit", "prow.k8s.io/is-optional": "true"},
+			}},
+			expected: ClassTestsLow,
+		},
+		{
+			name: "release payload pods get the release class",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ci-op-12345",
+				Name:      "release-images-latest",
+			}},
+			expected: ClassRelease,
+		},
+		{
+			name: "unknown extended resource leaves the pod unmanaged",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345"},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name: "test",
+					Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+						"vendor.example/fpga": resource.MustParse("1"),
+					}},
+				}}},
+			},
+			expected: ClassNone,
+		},
+		{
+			name: "harmless extended resources still classify",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345"},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name: "test",
+					Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+						"kata.peerpods.io/vm": resource.MustParse("1"),
+					}},
+				}}},
+			},
+			expected: ClassTests,
+		},
+		{
+			name: "ephemeral-storage appetite routes to large-disk",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345"},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name: "test",
+					Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+						corev1.ResourceEphemeralStorage: resource.MustParse("200Gi"),
+					}},
+				}}},
+			},
+			expected: ClassLargeDisk,
+		},
+		{
+			name: "kvm device requests route to metal",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345"},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name: "test",
+					Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{
+						"devices.kubevirt.io/kvm": resource.MustParse("1"),
+					}},
+				}}},
+			},
+			expected: ClassMetal,
+		},
+		{
+			name: "known metal steps route to metal",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ci-op-12345",
+				Labels:    map[string]string{"ci.openshift.io/metadata.step": "launch-libvirt"},
+			}},
+			expected: ClassMetal,
+		},
+		{
+			name: "resource rule routes hugepages pods by prefix",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345"},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name: "test",
+					Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+						"hugepages-2Mi": resource.MustParse("512Mi"),
+					}},
+				}}},
+			},
+			expected: ClassHeavy,
+		},
+		{
+			name: "heavy pod by aggregate memory requests",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345"},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "a", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("16Gi")}}},
+					{Name: "b", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("9Gi")}}},
+				}},
+			},
+			expected: ClassHeavy,
+		},
+		{
+			name: "pod under the heavy threshold keeps its class",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345"},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "a", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("16Gi")}}},
+				}},
+			},
+			expected: ClassTests,
+		},
+		{
+			name: "arm64 test pod by nodeSelector",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345"},
+				Spec:       corev1.PodSpec{NodeSelector: map[string]string{corev1.LabelArchStable: "arm64"}},
+			},
+			expected: "tests-arm64",
+		},
+		{
+			name: "arm64 build pod by ci-operator label",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "ci-op-12345",
+				Labels:      map[string]string{"ci.openshift.io/arch": "arm64"},
+				Annotations: map[string]string{buildv1.BuildLabel: "some-build"},
+			}},
+			expected: "builds-arm64",
+		},
+		{
+			name: "amd64 stays the unsuffixed class",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ci-op-12345"},
+				Spec:       corev1.PodSpec{NodeSelector: map[string]string{corev1.LabelArchStable: "amd64"}},
+			},
+			expected: ClassTests,
+		},
+		{
+			name: "classes without arch machinesets keep the base class",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ci",
+				Labels:    map[string]string{"created-by-prow": "true", "ci.openshift.io/arch": "arm64"},
+			}},
+			expected: ClassProwjobs,
+		},
+		{
+			name: "periodic prow jobs route to the spot pool",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ci",
+				Labels:    map[string]string{"created-by-prow": "true", "prow.k8s.io/type": "periodic"},
+			}},
+			expected: ClassSpot,
+		},
+		{
+			name: "presubmits stay in their class",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ci",
+				Labels:    map[string]string{"created-by-prow": "true", "prow.k8s.io/type": "presubmit"},
+			}},
+			expected: ClassProwjobs,
+		},
+		{
+			name: "step rule routes install steps",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ci-op-12345",
+				Labels:    map[string]string{"ci.openshift.io/metadata.step": "ipi-install"},
+			}},
+			expected: ClassLongTests,
+		},
+		{
+			name: "step rule wins over target rule",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ci-op-12345",
+				Labels: map[string]string{
+					"ci.openshift.io/metadata.step":   "ipi-install",
+					"ci.openshift.io/metadata.target": "e2e-aws",
+				},
+			}},
+			expected: ClassLongTests,
+		},
+		{
+			name: "target rule applies without a step rule",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ci-op-12345",
+				Labels:    map[string]string{"ci.openshift.io/metadata.target": "e2e-aws"},
+			}},
+			expected: ClassIOIntensive,
+		},
+		{
+			name:     "unclassified pod",
+			pod:      &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}},
+			expected: ClassNone,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if diff := cmp.Diff(testCase.expected, classifier.Classify(testCase.pod)); diff != "" {
+				t.Errorf("%s: got incorrect class: %v", testCase.name, diff)
+			}
+		})
+	}
+}
+
+func TestDefaultClass(t *testing.T) {
+	classifier := &Classifier{DefaultClass: ClassTests}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "random"}}
+	if got, reason := classifier.Explain(pod); got != ClassTests || reason != "default catch-all class" {
+		t.Errorf("expected the default class to catch unmatched pods, got %q (%q)", got, reason)
+	}
+	windows := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "random"},
+		Spec:       corev1.PodSpec{NodeSelector: map[string]string{corev1.LabelOSStable: "windows"}},
+	}
+	if got, _ := classifier.Explain(windows); got != ClassNone {
+		t.Errorf("expected unsupported-OS pods to stay unmanaged even with a default class, got %q", got)
+	}
+}
+
+func TestParseImageClassRulesRejections(t *testing.T) {
+	for _, invalid := range []string{"no-equals", "=tests", `pattern=unknown`, `(=tests`} {
+		if _, err := ParseImageClassRules([]string{invalid}); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Package config loads the versioned configuration of the
+// ci-scheduling-webhook with strict decoding, defaulting, validation, and
+// conversion from older schema versions.
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/ci-tools/pkg/scheduling"
+	schedulingv1 "github.com/openshift/ci-tools/pkg/scheduling/config/v1"
+	schedulingv1alpha1 "github.com/openshift/ci-tools/pkg/scheduling/config/v1alpha1"
+)
+
+// Load reads a configuration file in any supported version, converts it to
+// v1, defaults it, and validates it.
+func Load(path string) (*schedulingv1.Configuration, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read configuration: %w", err)
+	}
+	var typeMeta metav1.TypeMeta
+	if err := yaml.Unmarshal(raw, &typeMeta); err != nil {
+		return nil, fmt.Errorf("could not determine configuration version: %w", err)
+	}
+	var configuration *schedulingv1.Configuration
+	switch typeMeta.APIVersion {
+	case schedulingv1.GroupVersion:
+		configuration = &schedulingv1.Configuration{}
+		if err := yaml.UnmarshalStrict(raw, configuration); err != nil {
+			return nil, fmt.Errorf("could not unmarshal v1 configuration: %w", err)
+		}
+	case schedulingv1alpha1.GroupVersion:
+		old := &schedulingv1alpha1.Configuration{}
+		if err := yaml.UnmarshalStrict(raw, old); err != nil {
+			return nil, fmt.Errorf("could not unmarshal v1alpha1 configuration: %w", err)
+		}
+		configuration = convertV1Alpha1(old)
+	default:
+		return nil, fmt.Errorf("unsupported configuration apiVersion %q, expected %q or %q", typeMeta.APIVersion, schedulingv1.GroupVersion, schedulingv1alpha1.GroupVersion)
+	}
+	Default(configuration)
+	if err := Validate(configuration); err != nil {
+		return nil, err
+	}
+	return configuration, nil
+}
+
+// convertV1Alpha1 lifts the flat v1alpha1 knobs into the per-class v1 layout.
+func convertV1Alpha1(old *schedulingv1alpha1.Configuration) *schedulingv1.Configuration {
+	configuration := &schedulingv1.Configuration{
+		TypeMeta: metav1.TypeMeta{APIVersion: schedulingv1.GroupVersion, Kind: old.Kind},
+		Avoidance: schedulingv1.AvoidanceConfiguration{
+			Mode:                  old.AvoidanceMode,
+			MaxPrecludedHostnames: old.MaxPrecludedHostnames,
+		},
+		Classes:              map[string]schedulingv1.ClassConfiguration{},
+		StatefulSetPodPolicy: old.StatefulSetPodPolicy,
+		PatchGuardCategories: old.PatchGuardCategories,
+		Classification: schedulingv1.ClassificationConfiguration{
+			ImageRules:      old.ImageClassRules,
+			IOIntensiveJobs: old.IOIntensiveJobs,
+		},
+	}
+	class := func(name string) schedulingv1.ClassConfiguration {
+		return configuration.Classes[name]
+	}
+	for name, factor := range old.ShrinkCPURequests {
+		factor := factor
+		entry := class(name)
+		entry.ShrinkCPUFactor = &factor
+		configuration.Classes[name] = entry
+	}
+	for _, window := range old.ShrinkWindows {
+		assignment, timespan, _ := strings.Cut(window, "@")
+		name, rawFactor, _ := strings.Cut(assignment, "=")
+		start, end, _ := strings.Cut(timespan, "-")
+		var factor float64
+		fmt.Sscanf(rawFactor, "%g", &factor) //nolint:errcheck // validation rejects malformed windows after conversion
+		entry := class(name)
+		entry.ShrinkWindows = append(entry.ShrinkWindows, schedulingv1.ShrinkWindow{Factor: factor, Start: start, End: end})
+		configuration.Classes[name] = entry
+	}
+	for name, bandwidth := range old.ClassBandwidth {
+		ingress, egress, _ := strings.Cut(bandwidth, ":")
+		entry := class(name)
+		entry.Bandwidth = &schedulingv1.BandwidthConfiguration{Ingress: ingress, Egress: egress}
+		configuration.Classes[name] = entry
+	}
+	for name, limit := range old.ClassEmptyDirSizeLimit {
+		entry := class(name)
+		entry.EmptyDirSizeLimit = limit
+		configuration.Classes[name] = entry
+	}
+	for _, name := range old.MemoryScratchClasses {
+		entry := class(name)
+		entry.MemoryScratch = true
+		configuration.Classes[name] = entry
+	}
+	return configuration
+}
+
+// Default fills unset fields with the documented defaults.
+func Default(configuration *schedulingv1.Configuration) {
+	if configuration.Avoidance.Mode == "" {
+		configuration.Avoidance.Mode = "affinity"
+	}
+	if configuration.Avoidance.MaxPrecludedHostnames == nil {
+		defaultCap := 25
+		configuration.Avoidance.MaxPrecludedHostnames = &defaultCap
+	}
+	if configuration.StatefulSetPodPolicy == "" {
+		configuration.StatefulSetPodPolicy = "label-only"
+	}
+	if configuration.PatchGuardCategories == nil {
+		configuration.PatchGuardCategories = []string{"resources"}
+	}
+}
+
+// Validate rejects configurations that could not be acted on.
+func Validate(configuration *schedulingv1.Configuration) error {
+	switch configuration.Avoidance.Mode {
+	case "affinity", "taints", "label", "tiered":
+	default:
+		return fmt.Errorf("avoidance.mode must be one of \"affinity\", \"taints\", \"label\", or \"tiered\", not %q", configuration.Avoidance.Mode)
+	}
+	if *configuration.Avoidance.MaxPrecludedHostnames < 0 {
+		return fmt.Errorf("avoidance.maxPrecludedHostnames must not be negative")
+	}
+	switch configuration.StatefulSetPodPolicy {
+	case "label-only", "ignore", "full":
+	default:
+		return fmt.Errorf("statefulSetPodPolicy must be one of \"label-only\", \"ignore\", or \"full\", not %q", configuration.StatefulSetPodPolicy)
+	}
+	classes := scheduling.SetOf(scheduling.PodClasses)
+	for name, entry := range configuration.Classes {
+		if !classes[name] {
+			return fmt.Errorf("classes[%s]: unknown pod class", name)
+		}
+		if entry.ShrinkCPUFactor != nil && (*entry.ShrinkCPUFactor <= 0 || *entry.ShrinkCPUFactor > 1.0) {
+			return fmt.Errorf("classes[%s].shrinkCPUFactor must be in (0.0,1.0], not %f", name, *entry.ShrinkCPUFactor)
+		}
+		for i, window := range entry.ShrinkWindows {
+			if window.Factor <= 0 || window.Factor > 1.0 {
+				return fmt.Errorf("classes[%s].shrinkWindows[%d].factor must be in (0.0,1.0], not %f", name, i, window.Factor)
+			}
+			for _, bound := range []string{window.Start, window.End} {
+				if _, err := time.Parse("15:04", bound); err != nil {
+					return fmt.Errorf("classes[%s].shrinkWindows[%d]: %q is not an HH:MM time", name, i, bound)
+				}
+			}
+		}
+		if entry.Bandwidth != nil {
+			for _, value := range []string{entry.Bandwidth.Ingress, entry.Bandwidth.Egress} {
+				if value == "" {
+					continue
+				}
+				if _, err := resource.ParseQuantity(value); err != nil {
+					return fmt.Errorf("classes[%s].bandwidth: %q is not a valid quantity: %w", name, value, err)
+				}
+			}
+		}
+		if entry.EmptyDirSizeLimit != "" {
+			if _, err := resource.ParseQuantity(entry.EmptyDirSizeLimit); err != nil {
+				return fmt.Errorf("classes[%s].emptyDirSizeLimit: %q is not a valid quantity: %w", name, entry.EmptyDirSizeLimit, err)
+			}
+		}
+	}
+	if _, err := scheduling.ParseImageClassRules(configuration.Classification.ImageRules); err != nil {
+		return fmt.Errorf("classification.imageRules: %w", err)
+	}
+	for i, tenant := range configuration.Tenants {
+		if tenant.Org == "" {
+			return fmt.Errorf("tenants[%d]: org is required", i)
+		}
+		if tenant.PodClass != "" && !classes[tenant.PodClass] {
+			return fmt.Errorf("tenants[%d]: unknown pod class %q", i, tenant.PodClass)
+		}
+		if tenant.ShrinkCPUFactor != nil && (*tenant.ShrinkCPUFactor <= 0 || *tenant.ShrinkCPUFactor > 1.0) {
+			return fmt.Errorf("tenants[%d]: shrinkCPUFactor must be in (0.0,1.0], not %f", i, *tenant.ShrinkCPUFactor)
+		}
+	}
+	for _, category := range configuration.PatchGuardCategories {
+		if category == "none" {
+			continue
+		}
+		if _, known := scheduling.PatchGuardCategories[category]; !known {
+			return fmt.Errorf("patchGuardCategories contains unknown category %q", category)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func write(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("could not write configuration: %v", err)
+	}
+	return path
+}
+
+func TestLoadV1(t *testing.T) {
+	configuration, err := Load(write(t, `apiVersion: scheduling.ci.openshift.io/v1
+kind: Configuration
+avoidance:
+  mode: taints
+classes:
+  tests:
+    shrinkCPUFactor: 0.5
+    shrinkWindows:
+    - factor: 0.4
+      start: "06:00"
+      end: "18:00"
+    emptyDirSizeLimit: 100Gi
+  builds:
+    memoryScratch: true
+classification:
+  ioIntensiveJobs:
+  - periodic-io-heavy
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if configuration.Avoidance.Mode != "taints" {
+		t.Errorf("expected avoidance mode from the document, got %q", configuration.Avoidance.Mode)
+	}
+	if *configuration.Avoidance.MaxPrecludedHostnames != 25 {
+		t.Errorf("expected defaulted preclusion cap, got %d", *configuration.Avoidance.MaxPrecludedHostnames)
+	}
+	if configuration.StatefulSetPodPolicy != "label-only" {
+		t.Errorf("expected defaulted StatefulSet policy, got %q", configuration.StatefulSetPodPolicy)
+	}
+	if diff := cmp.Diff([]string{"resources"}, configuration.PatchGuardCategories); diff != "" {
+		t.Errorf("expected defaulted patch guard categories: %v", diff)
+	}
+	if factor := configuration.Classes["tests"].ShrinkCPUFactor; factor == nil || *factor != 0.5 {
+		t.Errorf("expected tests shrink factor 0.5, got %v", factor)
+	}
+}
+
+func TestLoadV1Alpha1Converts(t *testing.T) {
+	configuration, err := Load(write(t, `apiVersion: scheduling.ci.openshift.io/v1alpha1
+kind: Configuration
+avoidanceMode: affinity
+shrinkCPURequests:
+  builds: 0.5
+shrinkWindows:
+- tests=0.4@06:00-18:00
+classBandwidth:
+  tests: 50M:200M
+memoryScratchClasses:
+- builds
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	builds := configuration.Classes["builds"]
+	if builds.ShrinkCPUFactor == nil || *builds.ShrinkCPUFactor != 0.5 || !builds.MemoryScratch {
+		t.Errorf("expected converted builds class, got %+v", builds)
+	}
+	tests := configuration.Classes["tests"]
+	if len(tests.ShrinkWindows) != 1 || tests.ShrinkWindows[0].Factor != 0.4 || tests.ShrinkWindows[0].Start != "06:00" {
+		t.Errorf("expected converted shrink window, got %+v", tests.ShrinkWindows)
+	}
+	if tests.Bandwidth == nil || tests.Bandwidth.Ingress != "50M" || tests.Bandwidth.Egress != "200M" {
+		t.Errorf("expected converted bandwidth, got %+v", tests.Bandwidth)
+	}
+}
+
+func TestLoadAcceptsAllAvoidanceModes(t *testing.T) {
+	for _, mode := range []string{"affinity", "taints", "label", "tiered"} {
+		if _, err := Load(write(t, "apiVersion: scheduling.ci.openshift.io/v1\nkind: Configuration\navoidance:\n  mode: "+mode+"\n")); err != nil {
+			t.Errorf("expected avoidance mode %q to be accepted: %v", mode, err)
+		}
+	}
+}
+
+func TestLoadRejections(t *testing.T) {
+	var testCases = []struct {
+		name    string
+		content string
+	}{
+		{
+			name:    "unknown apiVersion",
+			content: "apiVersion: scheduling.ci.openshift.io/v2\nkind: Configuration\n",
+		},
+		{
+			name:    "unknown field is rejected strictly",
+			content: "apiVersion: scheduling.ci.openshift.io/v1\nkind: Configuration\nsurprise: true\n",
+		},
+		{
+			name:    "unknown class",
+			content: "apiVersion: scheduling.ci.openshift.io/v1\nkind: Configuration\nclasses:\n  nonsense: {}\n",
+		},
+		{
+			name:    "factor out of range",
+			content: "apiVersion: scheduling.ci.openshift.io/v1\nkind: Configuration\nclasses:\n  tests:\n    shrinkCPUFactor: 1.5\n",
+		},
+		{
+			name:    "malformed bandwidth quantity",
+			content: "apiVersion: scheduling.ci.openshift.io/v1\nkind: Configuration\nclasses:\n  tests:\n    bandwidth:\n      ingress: wat\n",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if _, err := Load(write(t, testCase.content)); err == nil {
+				t.Errorf("%s: expected an error, got none", testCase.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Package v1 holds the stable configuration schema of the
+// ci-scheduling-webhook. All externally configurable behavior hangs off this
+// one document so future evolution happens through conversion instead of
+// breaking existing build farms.
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GroupVersion identifies this configuration schema in apiVersion.
+const GroupVersion = "scheduling.ci.openshift.io/v1"
+
+// Configuration is the v1 configuration of the webhook.
+type Configuration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Avoidance configures how scale-down candidates are kept free of pods.
+	Avoidance AvoidanceConfiguration `json:"avoidance,omitempty"`
+
+	// Classes configures per-class mutation policy, keyed by pod class.
+	Classes map[string]ClassConfiguration `json:"classes,omitempty"`
+
+	// Classification configures how pods are assigned to classes.
+	Classification ClassificationConfiguration `json:"classification,omitempty"`
+
+	// Tenants override classification and shrinking per org/repo, so teams
+	// with known pathological jobs can be isolated without global rule
+	// changes. A repo-scoped entry beats an org-wide one.
+	Tenants []TenantOverride `json:"tenants,omitempty"`
+
+	// StatefulSetPodPolicy controls how StatefulSet-owned pods are handled.
+	StatefulSetPodPolicy string `json:"statefulSetPodPolicy,omitempty"`
+
+	// PatchGuardCategories are protected with JSON-Patch test operations.
+	PatchGuardCategories []string `json:"patchGuardCategories,omitempty"`
+}
+
+// AvoidanceConfiguration configures scale-down avoidance.
+type AvoidanceConfiguration struct {
+	// Mode is one of "affinity", "taints", "label", or "tiered".
+	Mode string `json:"mode,omitempty"`
+	// MaxPrecludedHostnames bounds the per-pod anti-affinity list.
+	MaxPrecludedHostnames *int `json:"maxPrecludedHostnames,omitempty"`
+}
+
+// ClassConfiguration is the mutation policy of one pod class.
+type ClassConfiguration struct {
+	// ShrinkCPUFactor is the base factor CPU requests are multiplied by.
+	ShrinkCPUFactor *float64 `json:"shrinkCPUFactor,omitempty"`
+	// ShrinkWindows override the base factor during UTC time windows.
+	ShrinkWindows []ShrinkWindow `json:"shrinkWindows,omitempty"`
+	// Bandwidth caps pod network bandwidth through annotations.
+	Bandwidth *BandwidthConfiguration `json:"bandwidth,omitempty"`
+	// EmptyDirSizeLimit is defaulted onto emptyDir volumes declaring none.
+	EmptyDirSizeLimit string `json:"emptyDirSizeLimit,omitempty"`
+	// MemoryScratch allows pods to opt into memory-backed scratch volumes.
+	MemoryScratch bool `json:"memoryScratch,omitempty"`
+	// ExtraTolerations are injected into every pod of the class in addition
+	// to the class toleration, e.g. for infrastructure taints like
+	// dedicated=ci, so dedicated capacity can be carved out without every
+	// job config needing toleration stanzas.
+	ExtraTolerations []corev1.Toleration `json:"extraTolerations,omitempty"`
+}
+
+// ShrinkWindow is a time-of-day override of the class's shrink factor.
+type ShrinkWindow struct {
+	Factor float64 `json:"factor"`
+	// Start and End are UTC times of day in HH:MM form; the window may wrap
+	// midnight.
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// BandwidthConfiguration caps pod network bandwidth.
+type BandwidthConfiguration struct {
+	Ingress string `json:"ingress,omitempty"`
+	Egress  string `json:"egress,omitempty"`
+}
+
+// TenantOverride adjusts scheduling for one org or org/repo.
+type TenantOverride struct {
+	// Org is the organization the override applies to.
+	Org string `json:"org"`
+	// Repo narrows the override to one repository; empty covers the org.
+	Repo string `json:"repo,omitempty"`
+	// PodClass routes the tenant's pods to a specific pool.
+	PodClass string `json:"podClass,omitempty"`
+	// ShrinkCPUFactor overrides the CPU shrink factor for the tenant.
+	ShrinkCPUFactor *float64 `json:"shrinkCPUFactor,omitempty"`
+}
+
+// ClassificationConfiguration configures pod classification.
+type ClassificationConfiguration struct {
+	// ImageRules are pattern=class rules matched against container images.
+	ImageRules []string `json:"imageRules,omitempty"`
+	// IOIntensiveJobs are rerouted to the local-SSD pool.
+	IOIntensiveJobs []string `json:"ioIntensiveJobs,omitempty"`
+}
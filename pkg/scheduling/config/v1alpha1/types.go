@@ -0,0 +1,43 @@
+// Package v1alpha1 holds the original, flat configuration schema of the
+// ci-scheduling-webhook. It is kept only so existing build farms keep
+// working; new configuration should be written against v1.
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GroupVersion identifies this configuration schema in apiVersion.
+const GroupVersion = "scheduling.ci.openshift.io/v1alpha1"
+
+// Configuration is the v1alpha1 configuration of the webhook: one flat
+// namespace of knobs, mirroring the original command line flags.
+type Configuration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	AvoidanceMode         string `json:"avoidanceMode,omitempty"`
+	MaxPrecludedHostnames *int   `json:"maxPrecludedHostnames,omitempty"`
+
+	// ShrinkCPURequests maps pod classes to base CPU shrink factors.
+	ShrinkCPURequests map[string]float64 `json:"shrinkCPURequests,omitempty"`
+	// ShrinkWindows are time-of-day overrides in class=factor@HH:MM-HH:MM
+	// form, as accepted by the --shrink-window flag.
+	ShrinkWindows []string `json:"shrinkWindows,omitempty"`
+
+	// ClassBandwidth maps pod classes to ingress:egress bandwidth caps.
+	ClassBandwidth map[string]string `json:"classBandwidth,omitempty"`
+	// ClassEmptyDirSizeLimit maps pod classes to default emptyDir sizeLimits.
+	ClassEmptyDirSizeLimit map[string]string `json:"classEmptyDirSizeLimit,omitempty"`
+	// MemoryScratchClasses may opt into memory-backed scratch volumes.
+	MemoryScratchClasses []string `json:"memoryScratchClasses,omitempty"`
+
+	StatefulSetPodPolicy string `json:"statefulSetPodPolicy,omitempty"`
+
+	// IOIntensiveJobs are rerouted to the local-SSD pool.
+	IOIntensiveJobs []string `json:"ioIntensiveJobs,omitempty"`
+	// ImageClassRules are pattern=class classification rules.
+	ImageClassRules []string `json:"imageClassRules,omitempty"`
+
+	// PatchGuardCategories are protected with JSON-Patch test operations.
+	PatchGuardCategories []string `json:"patchGuardCategories,omitempty"`
+}
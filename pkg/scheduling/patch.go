@@ -0,0 +1,120 @@
+package scheduling
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	jsonpatch "gomodules.xyz/jsonpatch/v2"
+)
+
+// MinimizePatches drops operations that would not change the admitted object.
+// Round-tripping large pod objects through decode and re-marshal yields no-op
+// operations - re-added identical labels and annotations, null
+// creationTimestamps, empty status stanzas - and in ci namespaces those maps
+// are big enough for the waste to show up in apiserver request sizes.
+func MinimizePatches(originalRaw []byte, patches []jsonpatch.Operation) []jsonpatch.Operation {
+	var original interface{}
+	if err := json.Unmarshal(originalRaw, &original); err != nil {
+		// Not being able to minimize is not a reason to fail admission.
+		return patches
+	}
+	var minimized []jsonpatch.Operation
+	for _, patch := range patches {
+		if patch.Operation == "add" || patch.Operation == "replace" {
+			existing, found := lookupJSONPath(original, patch.Path)
+			if found && reflect.DeepEqual(existing, patch.Value) {
+				continue
+			}
+			if !found && isEmptyValue(patch.Value) {
+				// Serialization artifacts: adding null or an empty object
+				// where the original had nothing changes no behavior.
+				continue
+			}
+		}
+		minimized = append(minimized, patch)
+	}
+	return minimized
+}
+
+// PatchGuardCategories names groups of patch paths that can be protected by
+// JSON-Patch test operations, keyed by the category name accepted on the
+// command line.
+var PatchGuardCategories = map[string]*regexp.Regexp{
+	"resources":  regexp.MustCompile(`/resources/(requests|limits)(/|$)`),
+	"metadata":   regexp.MustCompile(`^/metadata/(labels|annotations)(/|$)`),
+	"scheduling": regexp.MustCompile(`^/spec/(affinity|tolerations|nodeSelector)(/|$)`),
+}
+
+// GuardPatches emits a test operation ahead of every add or replace that
+// touches a guarded path and had a value in the object we decoded. If another
+// mutating webhook changed the object between our decode and the patch being
+// applied, the test fails the patch visibly instead of silently merging a
+// wrong spec. Operations on paths the original object did not have need no
+// guard - an add there cannot clobber concurrent state we read.
+func GuardPatches(originalRaw []byte, patches []jsonpatch.Operation, guards []*regexp.Regexp) []jsonpatch.Operation {
+	if len(guards) == 0 {
+		return patches
+	}
+	var original interface{}
+	if err := json.Unmarshal(originalRaw, &original); err != nil {
+		return patches
+	}
+	var guarded []jsonpatch.Operation
+	for _, patch := range patches {
+		if patch.Operation == "add" || patch.Operation == "replace" {
+			for _, guard := range guards {
+				if !guard.MatchString(patch.Path) {
+					continue
+				}
+				if existing, found := lookupJSONPath(original, patch.Path); found {
+					guarded = append(guarded, jsonpatch.Operation{Operation: "test", Path: patch.Path, Value: existing})
+				}
+				break
+			}
+		}
+		guarded = append(guarded, patch)
+	}
+	return guarded
+}
+
+func isEmptyValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	if object, isObject := value.(map[string]interface{}); isObject {
+		return len(object) == 0
+	}
+	return false
+}
+
+// lookupJSONPath resolves a JSON-Pointer style patch path against a document
+// unmarshaled into generic types.
+func lookupJSONPath(document interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return document, true
+	}
+	current := document
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		segment = strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+		switch typed := current.(type) {
+		case map[string]interface{}:
+			value, has := typed[segment]
+			if !has {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(typed) {
+				return nil, false
+			}
+			current = typed[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
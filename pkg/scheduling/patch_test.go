@@ -0,0 +1,119 @@
+package scheduling
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	jsonpatch "gomodules.xyz/jsonpatch/v2"
+)
+
+func TestMinimizePatches(t *testing.T) {
+	original := []byte(`{"metadata":{"labels":{"app":"ci"},"annotations":{"key":"value"}},"spec":{"nodeName":"node-1"}}`)
+	var testCases = []struct {
+		name     string
+		original []byte
+		patches  []jsonpatch.Operation
+		expected []jsonpatch.Operation
+	}{
+		{
+			name:     "identical re-adds are dropped",
+			original: original,
+			patches: []jsonpatch.Operation{
+				{Operation: "add", Path: "/metadata/labels/app", Value: "ci"},
+				{Operation: "replace", Path: "/metadata/annotations", Value: map[string]interface{}{"key": "value"}},
+			},
+			expected: nil,
+		},
+		{
+			name:     "serialization artifacts are dropped",
+			original: original,
+			patches: []jsonpatch.Operation{
+				{Operation: "add", Path: "/metadata/creationTimestamp", Value: nil},
+				{Operation: "add", Path: "/status", Value: map[string]interface{}{}},
+			},
+			expected: nil,
+		},
+		{
+			name:     "real changes are kept",
+			original: original,
+			patches: []jsonpatch.Operation{
+				{Operation: "add", Path: "/metadata/labels/app", Value: "other"},
+				{Operation: "add", Path: "/spec/nodeSelector", Value: map[string]interface{}{LabelName: "tests"}},
+				{Operation: "remove", Path: "/spec/nodeName"},
+			},
+			expected: []jsonpatch.Operation{
+				{Operation: "add", Path: "/metadata/labels/app", Value: "other"},
+				{Operation: "add", Path: "/spec/nodeSelector", Value: map[string]interface{}{LabelName: "tests"}},
+				{Operation: "remove", Path: "/spec/nodeName"},
+			},
+		},
+		{
+			name:     "unparseable original passes patches through",
+			original: []byte(`{not json`),
+			patches: []jsonpatch.Operation{
+				{Operation: "add", Path: "/status", Value: map[string]interface{}{}},
+			},
+			expected: []jsonpatch.Operation{
+				{Operation: "add", Path: "/status", Value: map[string]interface{}{}},
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if diff := cmp.Diff(testCase.expected, MinimizePatches(testCase.original, testCase.patches)); diff != "" {
+				t.Errorf("%s: incorrect minimized patches: %v", testCase.name, diff)
+			}
+		})
+	}
+}
+
+func TestGuardPatches(t *testing.T) {
+	original := []byte(`{"spec":{"containers":[{"name":"test","resources":{"requests":{"cpu":"2"}}}]}}`)
+	var testCases = []struct {
+		name     string
+		patches  []jsonpatch.Operation
+		guards   []*regexp.Regexp
+		expected []jsonpatch.Operation
+	}{
+		{
+			name: "replace on guarded path gets a test guard",
+			patches: []jsonpatch.Operation{
+				{Operation: "replace", Path: "/spec/containers/0/resources/requests/cpu", Value: "1001m"},
+			},
+			guards: []*regexp.Regexp{PatchGuardCategories["resources"]},
+			expected: []jsonpatch.Operation{
+				{Operation: "test", Path: "/spec/containers/0/resources/requests/cpu", Value: "2"},
+				{Operation: "replace", Path: "/spec/containers/0/resources/requests/cpu", Value: "1001m"},
+			},
+		},
+		{
+			name: "adds to previously absent paths are not guarded",
+			patches: []jsonpatch.Operation{
+				{Operation: "add", Path: "/spec/containers/0/resources/limits", Value: map[string]interface{}{"cpu": "4"}},
+			},
+			guards: []*regexp.Regexp{PatchGuardCategories["resources"]},
+			expected: []jsonpatch.Operation{
+				{Operation: "add", Path: "/spec/containers/0/resources/limits", Value: map[string]interface{}{"cpu": "4"}},
+			},
+		},
+		{
+			name: "unguarded categories pass through",
+			patches: []jsonpatch.Operation{
+				{Operation: "replace", Path: "/spec/containers/0/resources/requests/cpu", Value: "1001m"},
+			},
+			guards: nil,
+			expected: []jsonpatch.Operation{
+				{Operation: "replace", Path: "/spec/containers/0/resources/requests/cpu", Value: "1001m"},
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if diff := cmp.Diff(testCase.expected, GuardPatches(original, testCase.patches, testCase.guards)); diff != "" {
+				t.Errorf("%s: incorrect guarded patches: %v", testCase.name, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+package scheduling
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Prioritization answers which nodes in a pod class pool we would most like
+// the autoscaler to remove. New pods are steered away from those nodes so
+// that they can drain naturally.
+type Prioritization struct {
+	Client ctrlruntimeclient.Client
+
+	// MaxPrecludedHostnames bounds the number of hostnames we are willing to
+	// inject into any one pod's anti-affinity. Candidates beyond this cap are
+	// only avoided through the soft avoidance taint, keeping pod specs (and
+	// etcd object sizes) bounded no matter how large the pool grows.
+	MaxPrecludedHostnames int
+
+	// MinIdleNodes keeps a per-class warm buffer: that many of the emptiest
+	// candidates are exempted from avoidance so they absorb the morning
+	// presubmit spike without cold scale-up latency.
+	MinIdleNodes map[string]int
+	// MinIdleNodesFn, when set, supersedes MinIdleNodes, e.g. for
+	// schedule-driven warm buffers.
+	MinIdleNodesFn func(podClass string) int
+
+	// MaxCandidateUtilization drops nodes busier than this fraction of
+	// their pod capacity from candidacy, so busy nodes are never drained
+	// just because the pool could theoretically absorb them. Zero or one
+	// leaves candidacy purely structural.
+	MaxCandidateUtilization float64
+}
+
+// NodeUtilization pairs a node with the number of active CI pods it hosts
+// and its approximate hourly cost.
+type NodeUtilization struct {
+	Node     *corev1.Node
+	PodCount int
+	// HourlyCost is the provider's list price for the node's instance type,
+	// zero when unknown.
+	HourlyCost float64
+}
+
+// ClassNodeUtilization lists the nodes dedicated to podClass, including
+// unschedulable ones, along with their active pod counts.
+func (p *Prioritization) ClassNodeUtilization(ctx context.Context, podClass string) ([]NodeUtilization, error) {
+	nodeList := &corev1.NodeList{}
+	if err := p.Client.List(ctx, nodeList, ctrlruntimeclient.MatchingLabels{LabelName: podClass}); err != nil {
+		return nil, fmt.Errorf("could not list nodes for pod class %s: %w", podClass, err)
+	}
+	podList := &corev1.PodList{}
+	if err := p.Client.List(ctx, podList); err != nil {
+		return nil, fmt.Errorf("could not list pods for pod class %s: %w", podClass, err)
+	}
+	podCounts := map[string]int{}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		if pod.Spec.NodeSelector[LabelName] != podClass {
+			continue
+		}
+		if strings.HasPrefix(pod.Labels["app"], PrePullAppPrefix) {
+			// Pre-pull DaemonSet pods sit on every pool node by design and
+			// would otherwise keep drained nodes from ever looking empty.
+			continue
+		}
+		podCounts[pod.Spec.NodeName]++
+	}
+	var utilization []NodeUtilization
+	for i := range nodeList.Items {
+		node := &nodeList.Items[i]
+		profile := ProfileForProviderID(node.Spec.ProviderID)
+		utilization = append(utilization, NodeUtilization{
+			Node:       node,
+			PodCount:   podCounts[node.Name],
+			HourlyCost: profile.InstanceHourlyCost[node.Labels[InstanceTypeLabel]],
+		})
+	}
+	return utilization, nil
+}
+
+// ScaleDownCandidatesFrom identifies the nodes in a pool whose pods could be
+// absorbed by the schedulable remainder of the pool, ordered by how valuable
+// it is to drain them (emptiest first). The returned slice may be arbitrarily
+// long; callers that inject hostnames into pod specs must bound it with
+// PrecludedHostnames.
+func ScaleDownCandidatesFrom(utilization []NodeUtilization) []NodeUtilization {
+	var schedulable []NodeUtilization
+	for _, entry := range utilization {
+		if !entry.Node.Spec.Unschedulable {
+			schedulable = append(schedulable, entry)
+		}
+	}
+	if len(schedulable) < 2 {
+		// A pool this small has nothing to drain into.
+		return nil
+	}
+	// Emptiest nodes first - they are the cheapest to drain and the most
+	// valuable to give back to the autoscaler; at equal emptiness the most
+	// expensive node drains first, maximizing savings per evicted pod.
+	sort.Slice(schedulable, func(i, j int) bool {
+		if schedulable[i].PodCount != schedulable[j].PodCount {
+			return schedulable[i].PodCount < schedulable[j].PodCount
+		}
+		if schedulable[i].HourlyCost != schedulable[j].HourlyCost {
+			return schedulable[i].HourlyCost > schedulable[j].HourlyCost
+		}
+		return schedulable[i].Node.Name < schedulable[j].Node.Name
+	})
+	// Walk the emptiest nodes and nominate them for scale-down as long as the
+	// busier remainder of the pool retains headroom to absorb their pods.
+	var candidates []NodeUtilization
+	candidatePods := 0
+	for i := 0; i < len(schedulable)-1; i++ {
+		candidatePods += schedulable[i].PodCount
+		headroom := 0
+		for _, remaining := range schedulable[i+1:] {
+			capacity := int(remaining.Node.Status.Allocatable.Pods().Value())
+			if free := capacity - remaining.PodCount; free > 0 {
+				headroom += free
+			}
+		}
+		if candidatePods > headroom {
+			break
+		}
+		candidates = append(candidates, schedulable[i])
+	}
+	return candidates
+}
+
+// ScaleDownCandidates lists the scale-down candidates for a pod class.
+func (p *Prioritization) ScaleDownCandidates(ctx context.Context, podClass string) ([]NodeUtilization, error) {
+	utilization, err := p.ClassNodeUtilization(ctx, podClass)
+	if err != nil {
+		return nil, err
+	}
+	return p.ScaleDownCandidatesOf(podClass, utilization), nil
+}
+
+// ScaleDownCandidatesOf applies the configured policy - warm-buffer floors
+// (possibly schedule-driven) and the utilization criterion - on top of the
+// structural selection. Every consumer of candidacy (preclusion, taints,
+// drain, machine scale-down) must go through here, or a configured floor
+// would be ignored by part of the machinery.
+func (p *Prioritization) ScaleDownCandidatesOf(podClass string, utilization []NodeUtilization) []NodeUtilization {
+	candidates := ScaleDownCandidatesFrom(utilization)
+	warm := p.MinIdleNodes[podClass]
+	if p.MinIdleNodesFn != nil {
+		warm = p.MinIdleNodesFn(podClass)
+	}
+	if warm > 0 {
+		// The emptiest candidates make the best warm buffer; exempt them.
+		if len(candidates) <= warm {
+			candidates = nil
+		} else {
+			candidates = candidates[warm:]
+		}
+	}
+	if p.MaxCandidateUtilization <= 0 || p.MaxCandidateUtilization >= 1 {
+		return candidates
+	}
+	var filtered []NodeUtilization
+	for _, candidate := range candidates {
+		capacity := candidate.Node.Status.Allocatable.Pods().Value()
+		if capacity > 0 && float64(candidate.PodCount)/float64(capacity) > p.MaxCandidateUtilization {
+			continue
+		}
+		filtered = append(filtered, candidate)
+	}
+	return filtered
+}
+
+// PrecludedHostnames returns the hostnames that pod admission should exclude
+// through anti-affinity for podClass, bounded by MaxPrecludedHostnames. When
+// more candidates exist than the cap allows, the most valuable ones (the
+// emptiest) keep their place in the list and the remainder rely solely on the
+// avoidance taint.
+func (p *Prioritization) PrecludedHostnames(ctx context.Context, podClass string) ([]string, error) {
+	candidates, err := p.ScaleDownCandidates(ctx, podClass)
+	if err != nil {
+		return nil, err
+	}
+	if p.MaxPrecludedHostnames >= 0 && len(candidates) > p.MaxPrecludedHostnames {
+		candidates = candidates[:p.MaxPrecludedHostnames]
+	}
+	var hostnames []string
+	for _, candidate := range candidates {
+		hostname := candidate.Node.Labels[HostnameLabelName]
+		if hostname == "" {
+			hostname = candidate.Node.Name
+		}
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+	return hostnames, nil
+}
+
+// PreclusionRequirement builds the node selector requirement excluding
+// scale-down candidates for podClass. When more candidates exist than
+// MaxPrecludedHostnames allows, the requirement compacts: if the allowed
+// remainder of the pool is the smaller list, an In expression over it
+// replaces the NotIn list; otherwise the NotIn list is truncated to the
+// emptiest candidates as before. The boolean reports whether compaction to
+// the allowed list occurred.
+func (p *Prioritization) PreclusionRequirement(ctx context.Context, podClass string) (corev1.NodeSelectorRequirement, bool, error) {
+	utilization, err := p.ClassNodeUtilization(ctx, podClass)
+	if err != nil {
+		return corev1.NodeSelectorRequirement{}, false, err
+	}
+	candidates := p.ScaleDownCandidatesOf(podClass, utilization)
+	hostnameOf := func(entry NodeUtilization) string {
+		if hostname := entry.Node.Labels[HostnameLabelName]; hostname != "" {
+			return hostname
+		}
+		return entry.Node.Name
+	}
+	candidateNames := map[string]bool{}
+	var precluded []string
+	for _, candidate := range candidates {
+		candidateNames[candidate.Node.Name] = true
+		precluded = append(precluded, hostnameOf(candidate))
+	}
+	if len(precluded) == 0 {
+		return corev1.NodeSelectorRequirement{}, false, nil
+	}
+	if p.MaxPrecludedHostnames >= 0 && len(precluded) > p.MaxPrecludedHostnames {
+		var allowed []string
+		for _, entry := range utilization {
+			if !entry.Node.Spec.Unschedulable && !candidateNames[entry.Node.Name] {
+				allowed = append(allowed, hostnameOf(entry))
+			}
+		}
+		if len(allowed) > 0 && len(allowed) < len(precluded) {
+			sort.Strings(allowed)
+			return corev1.NodeSelectorRequirement{
+				Key:      HostnameLabelName,
+				Operator: corev1.NodeSelectorOpIn,
+				Values:   allowed,
+			}, true, nil
+		}
+		precluded = precluded[:p.MaxPrecludedHostnames]
+	}
+	sort.Strings(precluded)
+	return corev1.NodeSelectorRequirement{
+		Key:      HostnameLabelName,
+		Operator: corev1.NodeSelectorOpNotIn,
+		Values:   precluded,
+	}, false, nil
+}
+
+// TieredPreclusion splits the scale-down candidates into a hard tier (the
+// emptiest nodes, closest to being reclaimed, excluded through required
+// affinity) and a soft tier (early avoidance, only a scheduling preference),
+// each bounded by MaxPrecludedHostnames. Scale-down pressure then cannot
+// cause scheduling failures when capacity is tight.
+func (p *Prioritization) TieredPreclusion(ctx context.Context, podClass string) (hard, soft []string, err error) {
+	candidates, err := p.ScaleDownCandidates(ctx, podClass)
+	if err != nil {
+		return nil, nil, err
+	}
+	hostnameOf := func(entry NodeUtilization) string {
+		if hostname := entry.Node.Labels[HostnameLabelName]; hostname != "" {
+			return hostname
+		}
+		return entry.Node.Name
+	}
+	bound := func(names []string) []string {
+		if p.MaxPrecludedHostnames >= 0 && len(names) > p.MaxPrecludedHostnames {
+			names = names[:p.MaxPrecludedHostnames]
+		}
+		sort.Strings(names)
+		return names
+	}
+	// Only completely empty candidates are in the hard tier; nodes still
+	// draining get the soft preference.
+	for _, candidate := range candidates {
+		if candidate.PodCount == 0 {
+			hard = append(hard, hostnameOf(candidate))
+		} else {
+			soft = append(soft, hostnameOf(candidate))
+		}
+	}
+	return bound(hard), bound(soft), nil
+}
+
+// PackingPreferredTerms biases pods toward the most-packed schedulable
+// nodes of the class through preferred scheduling terms, improving bin
+// packing beyond what the default scheduler's spreading does. At most limit
+// nodes are named, weighted from 100 downwards.
+func (p *Prioritization) PackingPreferredTerms(ctx context.Context, podClass string, limit int) ([]corev1.PreferredSchedulingTerm, error) {
+	utilization, err := p.ClassNodeUtilization(ctx, podClass)
+	if err != nil {
+		return nil, err
+	}
+	candidates := map[string]bool{}
+	for _, candidate := range ScaleDownCandidatesFrom(utilization) {
+		candidates[candidate.Node.Name] = true
+	}
+	var packed []NodeUtilization
+	for _, entry := range utilization {
+		if entry.Node.Spec.Unschedulable || candidates[entry.Node.Name] || entry.PodCount == 0 {
+			continue
+		}
+		packed = append(packed, entry)
+	}
+	// Most-packed first - those are the nodes we want filled completely.
+	sort.Slice(packed, func(i, j int) bool {
+		if packed[i].PodCount != packed[j].PodCount {
+			return packed[i].PodCount > packed[j].PodCount
+		}
+		return packed[i].Node.Name < packed[j].Node.Name
+	})
+	if len(packed) > limit {
+		packed = packed[:limit]
+	}
+	var terms []corev1.PreferredSchedulingTerm
+	for i, entry := range packed {
+		hostname := entry.Node.Labels[HostnameLabelName]
+		if hostname == "" {
+			hostname = entry.Node.Name
+		}
+		weight := int32(100 - i*10)
+		if weight < 1 {
+			weight = 1
+		}
+		terms = append(terms, corev1.PreferredSchedulingTerm{
+			Weight: weight,
+			Preference: corev1.NodeSelectorTerm{
+				MatchExpressions: []corev1.NodeSelectorRequirement{{
+					Key:      HostnameLabelName,
+					Operator: corev1.NodeSelectorOpIn,
+					Values:   []string{hostname},
+				}},
+			},
+		})
+	}
+	return terms, nil
+}
+
+// PoolCPUCapacityMilli determines the largest per-node CPU capacity in a
+// class pool, preferring the provider's instance-type table and falling back
+// to node allocatable.
+func (p *Prioritization) PoolCPUCapacityMilli(ctx context.Context, podClass string) (int64, *ProviderProfile, error) {
+	utilization, err := p.ClassNodeUtilization(ctx, podClass)
+	if err != nil {
+		return 0, &genericProfile, err
+	}
+	var capacity int64
+	profile := &genericProfile
+	for _, entry := range utilization {
+		profile = ProfileForProviderID(entry.Node.Spec.ProviderID)
+		nodeCapacity, tabled := profile.InstanceCPUMilli[entry.Node.Labels[InstanceTypeLabel]]
+		if !tabled {
+			nodeCapacity = entry.Node.Status.Allocatable.Cpu().MilliValue()
+		}
+		if nodeCapacity > capacity {
+			capacity = nodeCapacity
+		}
+	}
+	return capacity, profile, nil
+}
@@ -0,0 +1,283 @@
+package scheduling
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func poolNode(name, podClass string, allocatablePods int64) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				LabelName:         podClass,
+				HostnameLabelName: name,
+			},
+		},
+		Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+			corev1.ResourcePods: *resource.NewQuantity(allocatablePods, resource.DecimalSI),
+		}},
+	}
+}
+
+func poolPods(node, podClass string, count int) []*corev1.Pod {
+	var pods []*corev1.Pod
+	for i := 0; i < count; i++ {
+		pods = append(pods, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: fmt.Sprintf("%s-pod-%d", node, i)},
+			Spec: corev1.PodSpec{
+				NodeName:     node,
+				NodeSelector: map[string]string{LabelName: podClass},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		})
+	}
+	return pods
+}
+
+func TestPrecludedHostnames(t *testing.T) {
+	newClient := func() ctrlruntimeclient.Client {
+		builder := fakectrlruntimeclient.NewClientBuilder()
+		for i := 0; i < 5; i++ {
+			builder.WithObjects(poolNode(fmt.Sprintf("node-%d", i), ClassTests, 10))
+			for _, pod := range poolPods(fmt.Sprintf("node-%d", i), ClassTests, i) {
+				builder.WithObjects(pod)
+			}
+		}
+		return builder.Build()
+	}
+	var testCases = []struct {
+		name                  string
+		maxPrecludedHostnames int
+		expected              []string
+	}{
+		{
+			name:                  "all candidates fit under the cap",
+			maxPrecludedHostnames: 25,
+			expected:              []string{"node-0", "node-1", "node-2", "node-3"},
+		},
+		{
+			name:                  "cap keeps the emptiest nodes",
+			maxPrecludedHostnames: 2,
+			expected:              []string{"node-0", "node-1"},
+		},
+		{
+			name:                  "zero cap disables affinity entirely",
+			maxPrecludedHostnames: 0,
+			expected:              nil,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			p := &Prioritization{Client: newClient(), MaxPrecludedHostnames: testCase.maxPrecludedHostnames}
+			hostnames, err := p.PrecludedHostnames(context.Background(), ClassTests)
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", testCase.name, err)
+			}
+			if diff := cmp.Diff(testCase.expected, hostnames); diff != "" {
+				t.Errorf("%s: incorrect precluded hostnames: %v", testCase.name, diff)
+			}
+		})
+	}
+}
+
+func TestScaleDownCandidatesSkipUnschedulable(t *testing.T) {
+	cordoned := poolNode("node-0", ClassTests, 10)
+	cordoned.Spec.Unschedulable = true
+	builder := fakectrlruntimeclient.NewClientBuilder().WithObjects(cordoned)
+	for i := 1; i < 4; i++ {
+		builder.WithObjects(poolNode(fmt.Sprintf("node-%d", i), ClassTests, 10))
+		for _, pod := range poolPods(fmt.Sprintf("node-%d", i), ClassTests, i) {
+			builder.WithObjects(pod)
+		}
+	}
+	p := &Prioritization{Client: builder.Build()}
+	candidates, err := p.ScaleDownCandidates(context.Background(), ClassTests)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, candidate := range candidates {
+		if candidate.Node.Name == "node-0" {
+			t.Error("expected cordoned node to not be a scale-down candidate")
+		}
+	}
+}
+
+func TestClassNodeUtilizationIgnoresPrePullPods(t *testing.T) {
+	node := poolNode("node-0", ClassTests, 10)
+	prepull := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ci",
+			Name:      "prepull-abc",
+			Labels:    map[string]string{"app": PrePullAppPrefix + ClassTests},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:     "node-0",
+			NodeSelector: map[string]string{LabelName: ClassTests},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	p := &Prioritization{Client: fakectrlruntimeclient.NewClientBuilder().WithObjects(node, prepull).Build()}
+	utilization, err := p.ClassNodeUtilization(context.Background(), ClassTests)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(utilization) != 1 || utilization[0].PodCount != 0 {
+		t.Errorf("expected the pre-pull pod to not count as utilization, got %+v", utilization)
+	}
+}
+
+func TestMinIdleNodesWarmBuffer(t *testing.T) {
+	builder := fakectrlruntimeclient.NewClientBuilder()
+	for i := 0; i < 5; i++ {
+		builder.WithObjects(poolNode(fmt.Sprintf("node-%d", i), ClassTests, 10))
+		for _, pod := range poolPods(fmt.Sprintf("node-%d", i), ClassTests, i) {
+			builder.WithObjects(pod)
+		}
+	}
+	p := &Prioritization{Client: builder.Build(), MinIdleNodes: map[string]int{ClassTests: 2}}
+	candidates, err := p.ScaleDownCandidates(context.Background(), ClassTests)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Four structural candidates minus a warm buffer of two.
+	if len(candidates) != 2 {
+		t.Fatalf("expected the warm buffer to exempt two candidates, got %+v", candidates)
+	}
+	for _, candidate := range candidates {
+		if candidate.Node.Name == "node-0" || candidate.Node.Name == "node-1" {
+			t.Errorf("expected the emptiest nodes to form the warm buffer, got candidate %s", candidate.Node.Name)
+		}
+	}
+}
+
+func TestCostAwareCandidateOrdering(t *testing.T) {
+	cheap := poolNode("node-cheap", ClassTests, 10)
+	cheap.Spec.ProviderID = "aws:///us-east-1a/i-cheap"
+	cheap.Labels[InstanceTypeLabel] = "m5.xlarge"
+	expensive := poolNode("node-expensive", ClassTests, 10)
+	expensive.Spec.ProviderID = "aws:///us-east-1a/i-big"
+	expensive.Labels[InstanceTypeLabel] = "m5.4xlarge"
+	busy := poolNode("node-busy", ClassTests, 10)
+	builder := fakectrlruntimeclient.NewClientBuilder().WithObjects(cheap, expensive, busy)
+	for _, pod := range poolPods("node-busy", ClassTests, 5) {
+		builder.WithObjects(pod)
+	}
+	p := &Prioritization{Client: builder.Build()}
+	candidates, err := p.ScaleDownCandidates(context.Background(), ClassTests)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(candidates) < 2 || candidates[0].Node.Name != "node-expensive" {
+		t.Errorf("expected the most expensive idle node to drain first, got %+v", candidates)
+	}
+}
+
+func TestPreclusionRequirementCompaction(t *testing.T) {
+	// Four empty candidates drain into one busy node; with a cap of 2 the
+	// allowed list (one node) is smaller than the candidate list, so the
+	// requirement compacts to an In expression.
+	builder := fakectrlruntimeclient.NewClientBuilder()
+	for i := 0; i < 4; i++ {
+		builder.WithObjects(poolNode(fmt.Sprintf("node-%d", i), ClassTests, 10))
+	}
+	busy := poolNode("node-busy", ClassTests, 10)
+	builder.WithObjects(busy)
+	for _, pod := range poolPods("node-busy", ClassTests, 4) {
+		builder.WithObjects(pod)
+	}
+	p := &Prioritization{Client: builder.Build(), MaxPrecludedHostnames: 2}
+	requirement, compacted, err := p.PreclusionRequirement(context.Background(), ClassTests)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !compacted || requirement.Operator != corev1.NodeSelectorOpIn {
+		t.Fatalf("expected compaction to an In expression, got %+v (compacted=%t)", requirement, compacted)
+	}
+	if diff := cmp.Diff([]string{"node-busy"}, requirement.Values); diff != "" {
+		t.Errorf("expected the allowed list, got %v", diff)
+	}
+
+	// Under the cap the NotIn list stays.
+	relaxed := &Prioritization{Client: builder.Build(), MaxPrecludedHostnames: 25}
+	requirement, compacted, err = relaxed.PreclusionRequirement(context.Background(), ClassTests)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if compacted || requirement.Operator != corev1.NodeSelectorOpNotIn || len(requirement.Values) != 4 {
+		t.Errorf("expected the plain NotIn list, got %+v (compacted=%t)", requirement, compacted)
+	}
+}
+
+func TestPackingPreferredTerms(t *testing.T) {
+	builder := fakectrlruntimeclient.NewClientBuilder()
+	for i := 0; i < 5; i++ {
+		builder.WithObjects(poolNode(fmt.Sprintf("node-%d", i), ClassTests, 10))
+		for _, pod := range poolPods(fmt.Sprintf("node-%d", i), ClassTests, i) {
+			builder.WithObjects(pod)
+		}
+	}
+	p := &Prioritization{Client: builder.Build()}
+	terms, err := p.PackingPreferredTerms(context.Background(), ClassTests, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(terms) != 1 {
+		t.Fatalf("expected only the one non-candidate packed node, got %+v", terms)
+	}
+	if values := terms[0].Preference.MatchExpressions[0].Values; len(values) != 1 || values[0] != "node-4" {
+		t.Errorf("expected the most-packed node to be preferred, got %v", values)
+	}
+	if terms[0].Weight != 100 {
+		t.Errorf("expected the strongest weight first, got %d", terms[0].Weight)
+	}
+}
+
+func TestProfileForProviderID(t *testing.T) {
+	var testCases = []struct {
+		providerID string
+		expected   string
+	}{
+		{providerID: "aws:///us-east-1a/i-0abc", expected: "aws"},
+		{providerID: "gce://project/us-central1-a/instance", expected: "gcp"},
+		{providerID: "azure:///subscriptions/abc", expected: "azure"},
+		{providerID: "vsphere://4201e8d9", expected: "vsphere"},
+		{providerID: "", expected: "generic"},
+	}
+	for _, testCase := range testCases {
+		if got := ProfileForProviderID(testCase.providerID); got.Name != testCase.expected {
+			t.Errorf("%s: expected profile %s, got %s", testCase.providerID, testCase.expected, got.Name)
+		}
+	}
+}
+
+func TestPoolCPUCapacityMilli(t *testing.T) {
+	tabled := poolNode("node-0", ClassTests, 10)
+	tabled.Spec.ProviderID = "aws:///us-east-1a/i-0abc"
+	tabled.Labels[InstanceTypeLabel] = "m5.2xlarge"
+	untabled := poolNode("node-1", ClassTests, 10)
+	untabled.Spec.ProviderID = "aws:///us-east-1a/i-0def"
+	untabled.Labels[InstanceTypeLabel] = "m5.17xlarge"
+	untabled.Status.Allocatable[corev1.ResourceCPU] = resource.MustParse("6")
+
+	p := &Prioritization{Client: fakectrlruntimeclient.NewClientBuilder().WithObjects(tabled, untabled).Build()}
+	capacity, profile, err := p.PoolCPUCapacityMilli(context.Background(), ClassTests)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if profile.Name != "aws" {
+		t.Errorf("expected aws profile, got %s", profile.Name)
+	}
+	if capacity != 8000 {
+		t.Errorf("expected pool capacity of 8000m from the instance table, got %dm", capacity)
+	}
+}
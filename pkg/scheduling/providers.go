@@ -0,0 +1,122 @@
+package scheduling
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// InstanceTypeLabel is the well-known node label carrying the cloud instance
+// type.
+const InstanceTypeLabel = "node.kubernetes.io/instance-type"
+
+// ProviderProfile captures the provider-specific behaviors of a build farm:
+// instance capacity tables for oversize rejection, how spot capacity is
+// recognized, and how individual machines are marked for deletion.
+type ProviderProfile struct {
+	Name string
+
+	// InstanceCPUMilli maps instance types to their nominal CPU capacity, for
+	// rejecting pods that could never schedule on the pool.
+	InstanceCPUMilli map[string]int64
+
+	// InstanceHourlyCost maps instance types to approximate on-demand USD
+	// per hour, for draining the most expensive idle nodes first. Static
+	// list-price snapshots are plenty: only the ordering matters.
+	InstanceHourlyCost map[string]float64
+
+	// SpotNodeLabel marks nodes backed by interruptible capacity.
+	SpotNodeLabel string
+
+	// MachineDeletionAnnotation, set on a machine, asks the machine API to
+	// remove that specific machine on the next scale-down.
+	MachineDeletionAnnotation string
+
+	// ZoneLabel carries the topology zone on nodes.
+	ZoneLabel string
+}
+
+var (
+	awsProfile = ProviderProfile{
+		Name: "aws",
+		InstanceCPUMilli: map[string]int64{
+			"m5.xlarge":   4000,
+			"m5.2xlarge":  8000,
+			"m5.4xlarge":  16000,
+			"m6a.2xlarge": 8000,
+			"m6a.4xlarge": 16000,
+			"m6i.4xlarge": 16000,
+		},
+		InstanceHourlyCost: map[string]float64{
+			"m5.xlarge":   0.192,
+			"m5.2xlarge":  0.384,
+			"m5.4xlarge":  0.768,
+			"m6a.2xlarge": 0.3456,
+			"m6a.4xlarge": 0.6912,
+			"m6i.4xlarge": 0.768,
+		},
+		SpotNodeLabel:             "machine.openshift.io/interruptible-instance",
+		MachineDeletionAnnotation: "machine.openshift.io/delete-machine",
+		ZoneLabel:                 corev1.LabelTopologyZone,
+	}
+	gcpProfile = ProviderProfile{
+		Name: "gcp",
+		InstanceCPUMilli: map[string]int64{
+			"n1-standard-4":  4000,
+			"n1-standard-8":  8000,
+			"n1-standard-16": 16000,
+			"n2-standard-8":  8000,
+			"n2-standard-16": 16000,
+		},
+		InstanceHourlyCost: map[string]float64{
+			"n1-standard-4":  0.19,
+			"n1-standard-8":  0.38,
+			"n1-standard-16": 0.76,
+			"n2-standard-8":  0.3885,
+			"n2-standard-16": 0.777,
+		},
+		SpotNodeLabel:             "machine.openshift.io/interruptible-instance",
+		MachineDeletionAnnotation: "machine.openshift.io/delete-machine",
+		ZoneLabel:                 corev1.LabelTopologyZone,
+	}
+	azureProfile = ProviderProfile{
+		Name: "azure",
+		InstanceCPUMilli: map[string]int64{
+			"Standard_D4s_v3":  4000,
+			"Standard_D8s_v3":  8000,
+			"Standard_D16s_v3": 16000,
+		},
+		InstanceHourlyCost: map[string]float64{
+			"Standard_D4s_v3":  0.192,
+			"Standard_D8s_v3":  0.384,
+			"Standard_D16s_v3": 0.768,
+		},
+		SpotNodeLabel:             "machine.openshift.io/interruptible-instance",
+		MachineDeletionAnnotation: "machine.openshift.io/delete-machine",
+		ZoneLabel:                 corev1.LabelTopologyZone,
+	}
+	vsphereProfile = ProviderProfile{
+		Name: "vsphere",
+		// vSphere farms size VMs freely; capacity comes from allocatable.
+		MachineDeletionAnnotation: "machine.openshift.io/delete-machine",
+		ZoneLabel:                 corev1.LabelTopologyZone,
+	}
+	genericProfile = ProviderProfile{Name: "generic", ZoneLabel: corev1.LabelTopologyZone}
+)
+
+// ProfileForProviderID selects the provider profile from a node's providerID
+// (e.g. aws:///us-east-1a/i-0abc...).
+func ProfileForProviderID(providerID string) *ProviderProfile {
+	switch {
+	case strings.HasPrefix(providerID, "aws://"):
+		return &awsProfile
+	case strings.HasPrefix(providerID, "gce://"):
+		return &gcpProfile
+	case strings.HasPrefix(providerID, "azure://"):
+		return &azureProfile
+	case strings.HasPrefix(providerID, "vsphere://"):
+		return &vsphereProfile
+	default:
+		return &genericProfile
+	}
+}
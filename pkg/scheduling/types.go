@@ -0,0 +1,168 @@
+// Package scheduling holds the classification, patch-building, and node
+// prioritization logic behind the ci-scheduling-webhook, exported so other
+// ci-tools commands (simulators, reporters, the dispatcher) can reuse the
+// exact production logic instead of reimplementing it.
+package scheduling
+
+// The well-known label and taint keys default to the ci-workload convention
+// but are variables so build farms with existing labeling conventions can
+// override them through ConfigureWellKnownNames at startup, before any
+// informers or webhooks run.
+var (
+	// LabelName marks nodes as dedicated to a class of CI workload and is
+	// injected into the nodeSelector of pods of that class.
+	LabelName = "ci-workload"
+
+	// NamespaceLabelName records, on a node, the namespace of the workload
+	// that most recently informed a scheduling decision. Purely
+	// informational; used when debugging packing decisions.
+	NamespaceLabelName = "ci-workload-namespace"
+
+	// AvoidanceStateLabelName marks precluded nodes in label-based avoidance
+	// mode; pods then carry a single DoesNotExist affinity term instead of
+	// an unbounded hostname NotIn list.
+	AvoidanceStateLabelName = "ci-workload-avoidance"
+
+	// AvoidanceTaintName is the soft taint applied to nodes we would like the
+	// autoscaler to scale down; pods of the matching class prefer to schedule
+	// elsewhere but can still land here when the pool is full.
+	AvoidanceTaintName = "ci-workload-avoiding"
+)
+
+// ConfigureWellKnownNames overrides the well-known label and taint keys;
+// empty arguments keep the defaults.
+func ConfigureWellKnownNames(label, namespaceLabel, avoidanceTaint, avoidanceStateLabel string) {
+	if label != "" {
+		LabelName = label
+	}
+	if namespaceLabel != "" {
+		NamespaceLabelName = namespaceLabel
+	}
+	if avoidanceTaint != "" {
+		AvoidanceTaintName = avoidanceTaint
+	}
+	if avoidanceStateLabel != "" {
+		AvoidanceStateLabelName = avoidanceStateLabel
+	}
+}
+
+const (
+	ClassBuilds   = "builds"
+	ClassTests    = "tests"
+	ClassProwjobs = "prowjobs"
+	// ClassArtifacts holds gcsupload/artifact-sync pods, which want
+	// network-optimized placement and unshrunk CPU so uploads are not
+	// throttled into missing artifacts on busy nodes.
+	ClassArtifacts = "artifacts"
+	// ClassIOIntensive routes IO-heavy jobs to a machineset backed by
+	// instance-store/local-SSD nodes.
+	ClassIOIntensive = "io-intensive"
+	// ClassLongTests holds tests known to run for hours, like cluster-bot
+	// launched clusters, which want stable nodes that are not aggressively
+	// scaled down underneath them.
+	ClassLongTests = "longtests"
+	// ClassHeavy routes pods whose aggregate memory requests exceed the
+	// configured threshold to large-memory machinesets, so huge builds and
+	// tests stop fragmenting the standard pools.
+	ClassHeavy = "heavy"
+	// ClassGPU routes pods requesting accelerator resources to the GPU
+	// machineset instead of leaving them un-managed.
+	ClassGPU = "gpu"
+	// ClassBuildsXL holds known-large builds, selecting bigger instance
+	// types instead of letting 16-CPU builds fragment the standard builds
+	// pool.
+	ClassBuildsXL = "builds-xl"
+	// ClassTestsHigh and ClassTestsLow are priority tiers within the test
+	// class: blocking presubmits land in the high tier, optional jobs in the
+	// low one, so required runs schedule first during capacity crunches.
+	ClassTestsHigh = "tests-high"
+	ClassTestsLow  = "tests-low"
+	// ClassLargeDisk routes pods with big ephemeral-storage appetites to
+	// machinesets backed by larger and faster disks, so etcd-heavy and
+	// image-building tests stop getting disk-evicted.
+	ClassLargeDisk = "large-disk"
+	// ClassMetal routes nested-virtualization jobs needing /dev/kvm to a
+	// bare-metal machineset instead of skipping them from management.
+	ClassMetal = "metal"
+	// ClassRelease holds release payload assembly pods, which must not be
+	// starved or precluded along with ordinary long e2e jobs.
+	ClassRelease = "release"
+	// ClassSpot routes interruption-tolerant jobs (typically periodics) to
+	// spot/preemptible machinesets, which cost a fraction of on-demand
+	// capacity.
+	ClassSpot = "spot"
+	// ClassOverflow absorbs test-pool bursts: when the primary test pool's
+	// pending queue grows past a threshold, new test pods route here (to a
+	// possibly spot-backed machineset) until the backlog drains.
+	ClassOverflow = "overflow"
+	// ClassNone marks pods we leave entirely alone.
+	ClassNone = "none"
+
+	// AvoidanceStateCordoned is the label value of precluded nodes.
+	AvoidanceStateCordoned = "cordoned"
+
+	// HostnameLabelName is the well-known node hostname label used in the
+	// preclusion affinity injected into pods.
+	HostnameLabelName = "kubernetes.io/hostname"
+
+	// PrePullAppPrefix prefixes the app label of the per-class image
+	// pre-pull DaemonSets. Their pods run on every pool node by design and
+	// must not count as class utilization, or drained nodes would never
+	// look empty to the avoidance and scale-down machinery.
+	PrePullAppPrefix = "ci-image-prepull-"
+)
+
+var (
+	// Architectures are the non-amd64 architectures with dedicated
+	// machinesets; amd64 stays the unsuffixed default.
+	Architectures = []string{"arm64", "ppc64le", "s390x"}
+
+	// archSuffixedClasses are the base classes that exist per architecture.
+	archSuffixedClasses = []string{ClassBuilds, ClassTests}
+
+	// PodClasses enumerates the classes we actively schedule for, in the
+	// order they are reconciled, including the per-architecture variants.
+	PodClasses = withArchVariants([]string{ClassBuilds, ClassTests, ClassProwjobs, ClassArtifacts, ClassIOIntensive, ClassLongTests, ClassBuildsXL, ClassTestsHigh, ClassTestsLow, ClassHeavy, ClassGPU, ClassLargeDisk, ClassMetal, ClassRelease, ClassSpot, ClassOverflow})
+)
+
+func withArchVariants(classes []string) []string {
+	suffixed := SetOf(archSuffixedClasses)
+	all := append([]string(nil), classes...)
+	for _, class := range classes {
+		if !suffixed[class] {
+			continue
+		}
+		for _, arch := range Architectures {
+			all = append(all, class+"-"+arch)
+		}
+	}
+	return all
+}
+
+// ClassForArch maps a base class to its per-architecture variant when one
+// exists; amd64 and unknown architectures keep the base class.
+func ClassForArch(class, arch string) string {
+	if arch == "" || arch == "amd64" {
+		return class
+	}
+	if !SetOf(archSuffixedClasses)[class] {
+		return class
+	}
+	for _, known := range Architectures {
+		if arch == known {
+			return class + "-" + arch
+		}
+	}
+	return class
+}
+
+// SetOf builds a membership set from a list of names.
+func SetOf(entries []string) map[string]bool {
+	set := map[string]bool{}
+	for _, entry := range entries {
+		if entry != "" {
+			set[entry] = true
+		}
+	}
+	return set
+}